@@ -0,0 +1,270 @@
+package dvlirclient
+
+import (
+	"context"
+	"io"
+)
+
+/*
+Adapter contains every operation of a DvLIR adapter that DvLIRClient offers with a context. DvLIRClient implements it,
+application code can depend on the interface to use decorators, mocks or alternate backends instead of the http client.
+*/
+type Adapter interface {
+	LoginContext(ctx context.Context) error
+	LogoutContext(ctx context.Context) error
+	GetDataFileContext(ctx context.Context, lines int) (DataLines, error)
+	GetDataFileStream(ctx context.Context, lines int, fn func(line DataLine, err error) error) error
+	GetMomentaryValuesContext(ctx context.Context) (MomentaryValues, error)
+	GetGeneralInformationContext(ctx context.Context) (GeneralInfo, error)
+	GetNetworkInformationContext(ctx context.Context) (NetworkInfo, error)
+	GetSystemInformationContext(ctx context.Context) (SystemInfo, error)
+	BlinkContext(ctx context.Context, blink int, pause int) (int, error)
+	NTPServerTestContext(ctx context.Context, ntpName string) (int, error)
+	ChangeNetworkSettingsContext(ctx context.Context, dhcp, ip, sub, gw, dns, ntpName, ntpServer, setDt string) (string, error)
+	ChangeSavingIntervalContext(ctx context.Context, interval SavingInterval) (string, error)
+	AllowResetWithPwdContext(ctx context.Context, allow string) (string, error)
+	ResetAllContext(ctx context.Context, rCode string) (string, error)
+	DeleteDataContext(ctx context.Context, code string) (string, error)
+	ChangePasswordContext(ctx context.Context, pw1, pw2, pw3 string) (string, error)
+	UploadFirmwareContext(ctx context.Context, filePath string) (string, error)
+	UploadFirmwareFrom(ctx context.Context, name string, r io.Reader, size int64, opts ...FirmwareOption) (*FirmwareUpgradeReport, error)
+	RestartContext(ctx context.Context) (string, error)
+	RestartAndWait(ctx context.Context, opts ReadyOptions) (*ReadyReport, error)
+	ResetAllAndWait(ctx context.Context, rCode string, opts ReadyOptions) (*ReadyReport, error)
+	Reconcile(ctx context.Context, desired DesiredState, opts ReconcileOptions) (*ReconcileReport, error)
+	ApplyNetworkSettings(ctx context.Context, s NetworkSettings) (string, error)
+	MigrateAddress(ctx context.Context, settings NetworkSettings, opts MigrateOptions) (*MigrationReport, error)
+	WaitReady(ctx context.Context, opts ReadyOptions) (*ReadyReport, error)
+	Backup(ctx context.Context) (*Snapshot, error)
+	Restore(ctx context.Context, snapshot *Snapshot, opts RestoreOptions) (*RestoreReport, error)
+}
+
+var _ Adapter = (*DvLIRClient)(nil)
+
+/*
+Middleware is called for every operation of an Adapter created with Wrap. It receives the name of the operation, e.g.
+"GetMomentaryValues", and has to call next to perform the operation on the wrapped Adapter.
+*/
+type Middleware func(ctx context.Context, operation string, next func(ctx context.Context) error) error
+
+/*
+Wrap returns an Adapter that passes every operation of next through the middleware
+*/
+func Wrap(next Adapter, middleware Middleware) Adapter {
+	return &wrappedAdapter{next: next, middleware: middleware}
+}
+
+type wrappedAdapter struct {
+	next       Adapter
+	middleware Middleware
+}
+
+func (w *wrappedAdapter) LoginContext(ctx context.Context) error {
+	return w.middleware(ctx, "Login", func(ctx context.Context) error {
+		return w.next.LoginContext(ctx)
+	})
+}
+
+func (w *wrappedAdapter) LogoutContext(ctx context.Context) error {
+	return w.middleware(ctx, "Logout", func(ctx context.Context) error {
+		return w.next.LogoutContext(ctx)
+	})
+}
+
+func (w *wrappedAdapter) GetDataFileContext(ctx context.Context, lines int) (res DataLines, err error) {
+	err = w.middleware(ctx, "GetDataFile", func(ctx context.Context) error {
+		res, err = w.next.GetDataFileContext(ctx, lines)
+		return err
+	})
+	return res, err
+}
+
+func (w *wrappedAdapter) GetDataFileStream(ctx context.Context, lines int, fn func(line DataLine, err error) error) error {
+	return w.middleware(ctx, "GetDataFileStream", func(ctx context.Context) error {
+		return w.next.GetDataFileStream(ctx, lines, fn)
+	})
+}
+
+func (w *wrappedAdapter) GetMomentaryValuesContext(ctx context.Context) (res MomentaryValues, err error) {
+	err = w.middleware(ctx, "GetMomentaryValues", func(ctx context.Context) error {
+		res, err = w.next.GetMomentaryValuesContext(ctx)
+		return err
+	})
+	return res, err
+}
+
+func (w *wrappedAdapter) GetGeneralInformationContext(ctx context.Context) (res GeneralInfo, err error) {
+	err = w.middleware(ctx, "GetGeneralInformation", func(ctx context.Context) error {
+		res, err = w.next.GetGeneralInformationContext(ctx)
+		return err
+	})
+	return res, err
+}
+
+func (w *wrappedAdapter) GetNetworkInformationContext(ctx context.Context) (res NetworkInfo, err error) {
+	err = w.middleware(ctx, "GetNetworkInformation", func(ctx context.Context) error {
+		res, err = w.next.GetNetworkInformationContext(ctx)
+		return err
+	})
+	return res, err
+}
+
+func (w *wrappedAdapter) GetSystemInformationContext(ctx context.Context) (res SystemInfo, err error) {
+	err = w.middleware(ctx, "GetSystemInformation", func(ctx context.Context) error {
+		res, err = w.next.GetSystemInformationContext(ctx)
+		return err
+	})
+	return res, err
+}
+
+func (w *wrappedAdapter) BlinkContext(ctx context.Context, blink int, pause int) (res int, err error) {
+	err = w.middleware(ctx, "Blink", func(ctx context.Context) error {
+		res, err = w.next.BlinkContext(ctx, blink, pause)
+		return err
+	})
+	return res, err
+}
+
+func (w *wrappedAdapter) NTPServerTestContext(ctx context.Context, ntpName string) (res int, err error) {
+	err = w.middleware(ctx, "NTPServerTest", func(ctx context.Context) error {
+		res, err = w.next.NTPServerTestContext(ctx, ntpName)
+		return err
+	})
+	return res, err
+}
+
+func (w *wrappedAdapter) ChangeNetworkSettingsContext(ctx context.Context, dhcp, ip, sub, gw, dns, ntpName, ntpServer, setDt string) (res string, err error) {
+	err = w.middleware(ctx, "ChangeNetworkSettings", func(ctx context.Context) error {
+		res, err = w.next.ChangeNetworkSettingsContext(ctx, dhcp, ip, sub, gw, dns, ntpName, ntpServer, setDt)
+		return err
+	})
+	return res, err
+}
+
+func (w *wrappedAdapter) ChangeSavingIntervalContext(ctx context.Context, interval SavingInterval) (res string, err error) {
+	err = w.middleware(ctx, "ChangeSavingInterval", func(ctx context.Context) error {
+		res, err = w.next.ChangeSavingIntervalContext(ctx, interval)
+		return err
+	})
+	return res, err
+}
+
+func (w *wrappedAdapter) AllowResetWithPwdContext(ctx context.Context, allow string) (res string, err error) {
+	err = w.middleware(ctx, "AllowResetWithPwd", func(ctx context.Context) error {
+		res, err = w.next.AllowResetWithPwdContext(ctx, allow)
+		return err
+	})
+	return res, err
+}
+
+func (w *wrappedAdapter) ResetAllContext(ctx context.Context, rCode string) (res string, err error) {
+	err = w.middleware(ctx, "ResetAll", func(ctx context.Context) error {
+		res, err = w.next.ResetAllContext(ctx, rCode)
+		return err
+	})
+	return res, err
+}
+
+func (w *wrappedAdapter) DeleteDataContext(ctx context.Context, code string) (res string, err error) {
+	err = w.middleware(ctx, "DeleteData", func(ctx context.Context) error {
+		res, err = w.next.DeleteDataContext(ctx, code)
+		return err
+	})
+	return res, err
+}
+
+func (w *wrappedAdapter) ChangePasswordContext(ctx context.Context, pw1, pw2, pw3 string) (res string, err error) {
+	err = w.middleware(ctx, "ChangePassword", func(ctx context.Context) error {
+		res, err = w.next.ChangePasswordContext(ctx, pw1, pw2, pw3)
+		return err
+	})
+	return res, err
+}
+
+func (w *wrappedAdapter) UploadFirmwareContext(ctx context.Context, filePath string) (res string, err error) {
+	err = w.middleware(ctx, "UploadFirmware", func(ctx context.Context) error {
+		res, err = w.next.UploadFirmwareContext(ctx, filePath)
+		return err
+	})
+	return res, err
+}
+
+func (w *wrappedAdapter) UploadFirmwareFrom(ctx context.Context, name string, r io.Reader, size int64, opts ...FirmwareOption) (res *FirmwareUpgradeReport, err error) {
+	err = w.middleware(ctx, "UploadFirmwareFrom", func(ctx context.Context) error {
+		res, err = w.next.UploadFirmwareFrom(ctx, name, r, size, opts...)
+		return err
+	})
+	return res, err
+}
+
+func (w *wrappedAdapter) RestartContext(ctx context.Context) (res string, err error) {
+	err = w.middleware(ctx, "Restart", func(ctx context.Context) error {
+		res, err = w.next.RestartContext(ctx)
+		return err
+	})
+	return res, err
+}
+
+func (w *wrappedAdapter) RestartAndWait(ctx context.Context, opts ReadyOptions) (res *ReadyReport, err error) {
+	err = w.middleware(ctx, "RestartAndWait", func(ctx context.Context) error {
+		res, err = w.next.RestartAndWait(ctx, opts)
+		return err
+	})
+	return res, err
+}
+
+func (w *wrappedAdapter) ResetAllAndWait(ctx context.Context, rCode string, opts ReadyOptions) (res *ReadyReport, err error) {
+	err = w.middleware(ctx, "ResetAllAndWait", func(ctx context.Context) error {
+		res, err = w.next.ResetAllAndWait(ctx, rCode, opts)
+		return err
+	})
+	return res, err
+}
+
+func (w *wrappedAdapter) Reconcile(ctx context.Context, desired DesiredState, opts ReconcileOptions) (res *ReconcileReport, err error) {
+	err = w.middleware(ctx, "Reconcile", func(ctx context.Context) error {
+		res, err = w.next.Reconcile(ctx, desired, opts)
+		return err
+	})
+	return res, err
+}
+
+func (w *wrappedAdapter) ApplyNetworkSettings(ctx context.Context, s NetworkSettings) (res string, err error) {
+	err = w.middleware(ctx, "ApplyNetworkSettings", func(ctx context.Context) error {
+		res, err = w.next.ApplyNetworkSettings(ctx, s)
+		return err
+	})
+	return res, err
+}
+
+func (w *wrappedAdapter) MigrateAddress(ctx context.Context, settings NetworkSettings, opts MigrateOptions) (res *MigrationReport, err error) {
+	err = w.middleware(ctx, "MigrateAddress", func(ctx context.Context) error {
+		res, err = w.next.MigrateAddress(ctx, settings, opts)
+		return err
+	})
+	return res, err
+}
+
+func (w *wrappedAdapter) WaitReady(ctx context.Context, opts ReadyOptions) (res *ReadyReport, err error) {
+	err = w.middleware(ctx, "WaitReady", func(ctx context.Context) error {
+		res, err = w.next.WaitReady(ctx, opts)
+		return err
+	})
+	return res, err
+}
+
+func (w *wrappedAdapter) Backup(ctx context.Context) (res *Snapshot, err error) {
+	err = w.middleware(ctx, "Backup", func(ctx context.Context) error {
+		res, err = w.next.Backup(ctx)
+		return err
+	})
+	return res, err
+}
+
+func (w *wrappedAdapter) Restore(ctx context.Context, snapshot *Snapshot, opts RestoreOptions) (res *RestoreReport, err error) {
+	err = w.middleware(ctx, "Restore", func(ctx context.Context) error {
+		res, err = w.next.Restore(ctx, snapshot, opts)
+		return err
+	})
+	return res, err
+}
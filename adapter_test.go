@@ -0,0 +1,140 @@
+package dvlirclient
+
+import (
+	"bytes"
+	"context"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+/*
+fakeAdapter is a mock of the Adapter interface that only implements GetMomentaryValuesContext
+*/
+type fakeAdapter struct {
+	Adapter
+	values MomentaryValues
+	err    error
+}
+
+func (f *fakeAdapter) GetMomentaryValuesContext(ctx context.Context) (MomentaryValues, error) {
+	return f.values, f.err
+}
+
+/*
+TestAdapter_Decorators covers:
+	- NewCachingAdapter with read operations and an operation that clears the cache
+	- NewCachingAdapter doesn't store a response fetched while the cache was cleared
+	- NewCachingAdapter clears the cache on ChangePassword and ApplyNetworkSettings
+	- NewMetricsAdapter
+	- NewLoggingAdapter with a successful and a failed operation
+	- Wrap with a mock
+*/
+func TestAdapter_Decorators(t *testing.T) {
+	dvlirClient, server := newSimulatedClient(t)
+	defer server.Close()
+
+	metrics := &OperationMetrics{}
+	var adapter Adapter = NewCachingAdapter(NewMetricsAdapter(dvlirClient, metrics), time.Minute)
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		_, err := adapter.GetMomentaryValuesContext(ctx)
+		if !assert.NoError(t, err, "Error during GetMomentaryValues request") {
+			return
+		}
+	}
+	assert.Equal(t, 1, metrics.Stats("GetMomentaryValues").Calls, "Response wasn't cached")
+
+	_, err := adapter.GetSystemInformationContext(ctx)
+	if !assert.NoError(t, err, "Error during GetSystemInformation request") {
+		return
+	}
+	_, err = adapter.ChangeSavingIntervalContext(ctx, "min")
+	if !assert.NoError(t, err, "Error during ChangeSavingInterval request") {
+		return
+	}
+	system, err := adapter.GetSystemInformationContext(ctx)
+	if assert.NoError(t, err, "Error during GetSystemInformation request") {
+		assert.Equal(t, SavingIntervalMinute, system.SavingInterval, "Cache wasn't cleared")
+	}
+	assert.Equal(t, 2, metrics.Stats("GetSystemInformation").Calls)
+	assert.Len(t, metrics.Snapshot(), 3)
+
+	//The cache is cleared while the general information is fetched
+	var cache *cachingAdapter
+	cache = NewCachingAdapter(Wrap(dvlirClient, func(ctx context.Context, operation string, next func(ctx context.Context) error) error {
+		err := next(ctx)
+		if operation == "GetGeneralInformation" {
+			cache.invalidate()
+		}
+		return err
+	}), time.Minute).(*cachingAdapter)
+	_, err = cache.GetGeneralInformationContext(ctx)
+	if assert.NoError(t, err, "Error during GetGeneralInformation request") {
+		assert.Empty(t, cache.entries, "Response fetched during invalidation was cached")
+	}
+
+	_, err = adapter.GetNetworkInformationContext(ctx)
+	if !assert.NoError(t, err, "Error during GetNetworkInformation request") {
+		return
+	}
+	_, err = adapter.ChangePasswordContext(ctx, server.Password(), "secret", "secret")
+	if !assert.NoError(t, err, "Error during ChangePassword request") {
+		return
+	}
+	_, err = adapter.GetNetworkInformationContext(ctx)
+	assert.NoError(t, err, "Error during GetNetworkInformation request")
+	assert.Equal(t, 2, metrics.Stats("GetNetworkInformation").Calls, "Cache wasn't cleared by ChangePassword")
+
+	settings := NetworkSettings{}
+	settings.SetNTP(true, "pool.ntp.org")
+	_, err = adapter.ApplyNetworkSettings(ctx, settings)
+	if !assert.NoError(t, err, "Error during ApplyNetworkSettings request") {
+		return
+	}
+	network, err := adapter.GetNetworkInformationContext(ctx)
+	if assert.NoError(t, err, "Error during GetNetworkInformation request") {
+		assert.Equal(t, "pool.ntp.org", network.NTPName, "Cache wasn't cleared by ApplyNetworkSettings")
+	}
+
+	var buf bytes.Buffer
+	mock := &fakeAdapter{err: errors.New("no connection")}
+	adapter = NewLoggingAdapter(mock, log.New(&buf, "", 0))
+	_, err = adapter.GetMomentaryValuesContext(ctx)
+	assert.EqualError(t, err, "no connection")
+	mock.err = nil
+	_, err = adapter.GetMomentaryValuesContext(ctx)
+	assert.NoError(t, err)
+
+	logs := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if assert.Len(t, logs, 2) {
+		assert.Contains(t, logs[0], "GetMomentaryValues failed after")
+		assert.Contains(t, logs[0], "no connection")
+		assert.Contains(t, logs[1], "GetMomentaryValues took")
+	}
+}
+
+/*
+TestAdapter_RateLimit covers:
+	- NewRateLimitedAdapter with consecutive operations
+	- NewRateLimitedAdapter with a canceled context
+*/
+func TestAdapter_RateLimit(t *testing.T) {
+	adapter := NewRateLimitedAdapter(&fakeAdapter{}, 20*time.Millisecond)
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		_, err := adapter.GetMomentaryValuesContext(context.Background())
+		assert.NoError(t, err)
+	}
+	assert.True(t, time.Since(start) >= 40*time.Millisecond, "Operations weren't rate limited")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := adapter.GetMomentaryValuesContext(ctx)
+	assert.True(t, errors.Is(err, context.Canceled), "Cancellation wasn't reported: %v", err)
+}
@@ -0,0 +1,229 @@
+package dvlirclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SnapshotVersion is the format version of the snapshots created by Backup
+const SnapshotVersion = 1
+
+/*
+Snapshot contains the settings and information of an adapter as read by Backup. It can be saved in YAML or JSON
+format and applied to an adapter again with Restore.
+*/
+type Snapshot struct {
+	//Version is the format version of the snapshot
+	Version int `json:"version" yaml:"version"`
+	//CreatedAt is the time the snapshot was taken
+	CreatedAt time.Time   `json:"created_at" yaml:"created_at"`
+	General   GeneralInfo `json:"general" yaml:"general"`
+	Network   NetworkInfo `json:"network" yaml:"network"`
+	System    SystemInfo  `json:"system" yaml:"system"`
+}
+
+/*
+Backup reads the general information, the network settings and the system settings of the adapter into a snapshot
+*/
+func (d *DvLIRClient) Backup(ctx context.Context) (*Snapshot, error) {
+	if !d.isValid() {
+		return nil, &NotValidError{}
+	}
+	snapshot := &Snapshot{Version: SnapshotVersion, CreatedAt: time.Now().UTC().Truncate(time.Second)}
+	var err error
+	if snapshot.General, err = d.GetGeneralInformationContext(ctx); err != nil {
+		return nil, errors.Wrap(err, "Error while reading general information")
+	}
+	if snapshot.Network, err = d.GetNetworkInformationContext(ctx); err != nil {
+		return nil, errors.Wrap(err, "Error while reading network information")
+	}
+	if snapshot.System, err = d.GetSystemInformationContext(ctx); err != nil {
+		return nil, errors.Wrap(err, "Error while reading system information")
+	}
+	return snapshot, nil
+}
+
+/*
+LoadSnapshot reads a snapshot from a YAML or JSON file, the format is chosen by the file extension like in Save
+*/
+func LoadSnapshot(path string) (*Snapshot, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error while reading snapshot")
+	}
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		return parseSnapshot(data, true)
+	}
+	return ParseSnapshot(data)
+}
+
+/*
+ParseSnapshot parses a snapshot in YAML or JSON format, data starting with '{' is parsed as JSON. Snapshots of an
+unsupported version are rejected.
+*/
+func ParseSnapshot(data []byte) (*Snapshot, error) {
+	return parseSnapshot(data, bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")))
+}
+
+func parseSnapshot(data []byte, isJSON bool) (*Snapshot, error) {
+	var snapshot Snapshot
+	var err error
+	if isJSON {
+		err = json.Unmarshal(data, &snapshot)
+	} else {
+		err = yaml.Unmarshal(data, &snapshot)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "Error while parsing snapshot")
+	}
+	if snapshot.Version != SnapshotVersion {
+		return nil, errors.New("Unsupported snapshot version " + strconv.Itoa(snapshot.Version) + ", expected " +
+			strconv.Itoa(SnapshotVersion))
+	}
+	return &snapshot, nil
+}
+
+/*
+Save writes the snapshot to a file, in JSON format if the file name ends with .json and in YAML format otherwise
+*/
+func (s *Snapshot) Save(path string) error {
+	var data []byte
+	var err error
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		data, err = json.MarshalIndent(s, "", "  ")
+	} else {
+		data, err = yaml.Marshal(s)
+	}
+	if err != nil {
+		return errors.Wrap(err, "Error while encoding snapshot")
+	}
+	return errors.Wrap(ioutil.WriteFile(path, data, 0600), "Error while writing snapshot")
+}
+
+/*
+RestoreOptions configures Restore
+*/
+type RestoreOptions struct {
+	//DryRun only reports the changes without applying them
+	DryRun bool
+	//KeepAddress leaves DHCP, IP address, subnet mask and gateway unchanged, e.g. to copy the settings of one adapter
+	//to another
+	KeepAddress bool
+	//Migrate configures how the adapter is followed to its new address if the snapshot changes its address
+	Migrate MigrateOptions
+}
+
+/*
+RestoreReport describes the outcome of Restore
+*/
+type RestoreReport struct {
+	//Changes are the fields that differ from the snapshot and were applied, unless DryRun was set
+	Changes []SettingChange
+	//ReadOnly are the fields that differ from the snapshot but can't be changed, e.g. the MAC address if the snapshot
+	//was taken from another adapter
+	ReadOnly []SettingChange
+	//Applied is true if the changes were applied
+	Applied bool
+	//Migration is the report of MigrateAddress if the address of the adapter was changed
+	Migration *MigrationReport
+}
+
+/*
+Restore applies the network settings, the saving interval and whether a reset with the default password is allowed
+from a snapshot to the adapter.
+
+The current settings are read first, fields that are identical or read-only are skipped. The changes are applied in a
+safe order: the system settings first, then the network settings that keep the address of the adapter and a change of
+the address last with MigrateAddress. Addresses of a snapshot with DHCP enabled were assigned by the DHCP server and
+aren't restored.
+*/
+func (d *DvLIRClient) Restore(ctx context.Context, snapshot *Snapshot, opts RestoreOptions) (*RestoreReport, error) {
+	if !d.isValid() {
+		return nil, &NotValidError{}
+	}
+	if snapshot.Version != SnapshotVersion {
+		return nil, errors.New("Unsupported snapshot version " + strconv.Itoa(snapshot.Version))
+	}
+	current, err := d.Backup(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &RestoreReport{}
+	for _, field := range snapshotFields {
+		have, want := field.value(current), field.value(snapshot)
+		if sameSetting(have, want) || field.skip(snapshot, opts) {
+			continue
+		}
+		change := SettingChange{Field: field.name, Current: have, Desired: want}
+		if field.readOnly {
+			report.ReadOnly = append(report.ReadOnly, change)
+		} else {
+			report.Changes = append(report.Changes, change)
+		}
+	}
+	if opts.DryRun || len(report.Changes) == 0 {
+		return report, nil
+	}
+
+	report.Migration, err = d.applySettingChanges(ctx, report.Changes, opts.Migrate)
+	if err != nil {
+		return report, err
+	}
+	report.Applied = true
+	return report, nil
+}
+
+/*
+snapshotField is a field of a snapshot that Restore compares
+*/
+type snapshotField struct {
+	name     string
+	value    func(*Snapshot) string
+	readOnly bool
+	//address fields are skipped if KeepAddress is set
+	address bool
+	//assigned fields are skipped if the snapshot uses DHCP
+	assigned bool
+}
+
+func (f snapshotField) skip(snapshot *Snapshot, opts RestoreOptions) bool {
+	return f.address && opts.KeepAddress || f.assigned && strings.EqualFold(snapshot.Network.DHCPServer, "yes")
+}
+
+/*
+snapshotFields are compared by Restore in this order. The date, time and the fields of info.txt that are also part of
+network.txt or system.txt are left out.
+*/
+var snapshotFields = []snapshotField{
+	{name: "general.server_id_meter", value: func(s *Snapshot) string { return s.General.ServerIDMeter }, readOnly: true},
+	{name: "general.meter_number", value: func(s *Snapshot) string { return s.General.MeterNumber }, readOnly: true},
+	{name: "general.manufacturer_code", value: func(s *Snapshot) string { return s.General.ManufacturerCode }, readOnly: true},
+	{name: "general.network_name", value: func(s *Snapshot) string { return s.General.NetworkName }, readOnly: true},
+	{name: "general.mac_address", value: func(s *Snapshot) string { return NormalizeMAC(s.General.MACAddress) }, readOnly: true},
+	{name: "general.device_sn", value: func(s *Snapshot) string { return s.General.DeviceSn }, readOnly: true},
+	{name: "general.firmware_version", value: func(s *Snapshot) string { return s.General.FirmwareVersion }, readOnly: true},
+	{name: SettingSavingInterval, value: func(s *Snapshot) string { return string(s.System.SavingInterval) }},
+	{name: SettingResetWithDefaultPwd, value: func(s *Snapshot) string { return s.System.ResetWithDefaultPwd }},
+	{name: "system.reset_code", value: func(s *Snapshot) string { return s.System.ResetCode }, readOnly: true},
+	{name: "system.delete_code", value: func(s *Snapshot) string { return s.System.DeleteCode }, readOnly: true},
+	{name: SettingDNSServer, value: func(s *Snapshot) string { return s.Network.DNSServer }, assigned: true},
+	{name: SettingNTPServer, value: func(s *Snapshot) string { return s.Network.NTPServer }},
+	{name: SettingNTPName, value: func(s *Snapshot) string { return s.Network.NTPName }},
+	{name: SettingDHCP, value: func(s *Snapshot) string { return s.Network.DHCPServer }, address: true},
+	{name: SettingIPAddress, value: func(s *Snapshot) string { return s.Network.IPAddress }, address: true, assigned: true},
+	{name: SettingSubnetMask, value: func(s *Snapshot) string { return s.Network.SubnetMask }, address: true, assigned: true},
+	{name: SettingGateway, value: func(s *Snapshot) string { return s.Network.Gateway }, address: true, assigned: true},
+}
+
+func sameSetting(a, b string) bool {
+	return strings.EqualFold(strings.TrimSpace(a), strings.TrimSpace(b))
+}
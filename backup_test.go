@@ -0,0 +1,171 @@
+package dvlirclient
+
+import (
+	"context"
+	"github.com/inexio/dvlir-restapi-go-client/dvlirtest"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+/*
+TestDvLIRClient_Backup covers:
+	- Backup
+	- Save and LoadSnapshot in YAML and JSON format
+	- ParseSnapshot with an unsupported version
+	- ParseSnapshot with JSON that isn't valid YAML
+*/
+func TestDvLIRClient_Backup(t *testing.T) {
+	dvlirClient, server := newSimulatedClient(t)
+	defer server.Close()
+
+	snapshot, err := dvlirClient.Backup(context.Background())
+	if !assert.NoError(t, err, "Error during Backup") {
+		return
+	}
+	assert.Equal(t, SnapshotVersion, snapshot.Version)
+	assert.Equal(t, server.State().MACAddress, snapshot.General.MACAddress)
+	assert.Equal(t, server.State().NTPName, snapshot.Network.NTPName)
+	assert.Equal(t, SavingInterval15Min, snapshot.System.SavingInterval)
+
+	dir, err := ioutil.TempDir("", "snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"snapshot.yaml", "snapshot.json"} {
+		path := filepath.Join(dir, name)
+		if !assert.NoError(t, snapshot.Save(path), "Error while saving "+name) {
+			continue
+		}
+		loaded, err := LoadSnapshot(path)
+		if assert.NoError(t, err, "Error while loading "+name) {
+			assert.True(t, snapshot.CreatedAt.Equal(loaded.CreatedAt), "CreatedAt of %s differs", name)
+			loaded.CreatedAt = snapshot.CreatedAt
+			assert.Equal(t, snapshot, loaded, name+" didn't round-trip")
+		}
+	}
+
+	_, err = ParseSnapshot([]byte(`{"version": 2, "network": {"ntp_name": "de.pool.ntp.org"}}`))
+	assert.EqualError(t, err, "Unsupported snapshot version 2, expected 1")
+
+	//The escaped slash is valid JSON, but not valid YAML
+	parsed, err := ParseSnapshot([]byte(` {"version": 1, "network": {"ntp_name": "ntp.example.com\/pool"}}`))
+	if assert.NoError(t, err, "Error while parsing JSON snapshot") {
+		assert.Equal(t, "ntp.example.com/pool", parsed.Network.NTPName)
+	}
+}
+
+/*
+TestDvLIRClient_Restore covers:
+	- Restore with a dry run
+	- Restore of changed settings
+	- Restore of a snapshot of another adapter with KeepAddress
+	- Restore of an unchanged adapter
+*/
+func TestDvLIRClient_Restore(t *testing.T) {
+	dvlirClient, server := newSimulatedClient(t)
+	defer server.Close()
+	ctx := context.Background()
+
+	snapshot, err := dvlirClient.Backup(ctx)
+	if !assert.NoError(t, err, "Error during Backup") {
+		return
+	}
+	server.Update(func(state *dvlirtest.State) {
+		state.SavingInterval = "sec"
+		state.ResetWithDefaultPwd = "yes"
+		state.NTPName = "pool.ntp.org"
+		state.DNSServer = "192.168.0.2"
+	})
+
+	report, err := dvlirClient.Restore(ctx, snapshot, RestoreOptions{DryRun: true})
+	if !assert.NoError(t, err, "Error during dry run") {
+		return
+	}
+	assert.Equal(t, []SettingChange{
+		{Field: SettingSavingInterval, Current: "sec", Desired: "15min"},
+		{Field: SettingResetWithDefaultPwd, Current: "yes", Desired: "no"},
+		{Field: SettingDNSServer, Current: "192.168.0.2", Desired: "192.168.0.1"},
+		{Field: SettingNTPName, Current: "pool.ntp.org", Desired: "de.pool.ntp.org"},
+	}, report.Changes)
+	assert.Empty(t, report.ReadOnly)
+	assert.False(t, report.Applied)
+	assert.Equal(t, "sec", server.State().SavingInterval, "Dry run changed the adapter")
+
+	report, err = dvlirClient.Restore(ctx, snapshot, RestoreOptions{})
+	if !assert.NoError(t, err, "Error during Restore") {
+		return
+	}
+	assert.True(t, report.Applied)
+	assert.Nil(t, report.Migration)
+	state := server.State()
+	assert.Equal(t, "15min", state.SavingInterval)
+	assert.Equal(t, "no", state.ResetWithDefaultPwd)
+	assert.Equal(t, "de.pool.ntp.org", state.NTPName)
+	assert.Equal(t, "192.168.0.1", state.DNSServer)
+
+	other := *snapshot
+	other.General.MACAddress = "00:50:C2:9F:10:02"
+	other.Network.IPAddress = "192.168.0.101"
+	other.Network.NTPServer = "no"
+	report, err = dvlirClient.Restore(ctx, &other, RestoreOptions{KeepAddress: true})
+	if !assert.NoError(t, err, "Error during Restore with KeepAddress") {
+		return
+	}
+	assert.Equal(t, []SettingChange{{Field: SettingNTPServer, Current: "yes", Desired: "no"}}, report.Changes)
+	assert.Equal(t, []SettingChange{{Field: "general.mac_address", Current: "00:50:c2:9f:10:01", Desired: "00:50:c2:9f:10:02"}}, report.ReadOnly)
+	assert.Equal(t, "no", server.State().NTPServer)
+	assert.Equal(t, "192.168.0.100", server.State().IPAddress, "Address wasn't kept")
+
+	report, err = dvlirClient.Restore(ctx, &other, RestoreOptions{KeepAddress: true})
+	if assert.NoError(t, err, "Error during Restore") {
+		assert.Empty(t, report.Changes)
+		assert.False(t, report.Applied)
+	}
+}
+
+/*
+TestDvLIRClient_RestoreAddress covers:
+	- Restore of a snapshot with another address
+*/
+func TestDvLIRClient_RestoreAddress(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.2:0")
+	if err != nil {
+		t.Skip("127.0.0.2 isn't available: ", err)
+	}
+	listener.Close()
+
+	dvlirClient, server := newSimulatedClient(t, dvlirtest.WithRebinding())
+	defer server.Close()
+	ctx := context.Background()
+
+	snapshot, err := dvlirClient.Backup(ctx)
+	if !assert.NoError(t, err, "Error during Backup") {
+		return
+	}
+	snapshot.Network.IPAddress = "127.0.0.2"
+	snapshot.Network.SubnetMask = "255.0.0.0"
+	snapshot.Network.Gateway = "127.0.0.1"
+	snapshot.Network.NTPName = "pool.ntp.org"
+
+	report, err := dvlirClient.Restore(ctx, snapshot, RestoreOptions{Migrate: MigrateOptions{Timeout: 5 * time.Second, PollInterval: 20 * time.Millisecond}})
+	if !assert.NoError(t, err, "Error during Restore") {
+		return
+	}
+	assert.Len(t, report.Changes, 4)
+	if assert.NotNil(t, report.Migration, "Address wasn't migrated") {
+		assert.Equal(t, "http://"+server.Address(), report.Migration.NewAddress)
+	}
+	assert.Equal(t, "pool.ntp.org", server.State().NTPName)
+
+	info, err := dvlirClient.GetNetworkInformation()
+	if assert.NoError(t, err, "Client didn't follow the adapter") {
+		assert.Equal(t, "127.0.0.2", info.IPAddress)
+		assert.Equal(t, "255.0.0.0", info.SubnetMask)
+	}
+}
@@ -0,0 +1,139 @@
+package dvlirclient
+
+import (
+	"encoding/json"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+/*
+Checkpoint is the last line of daten.csv that was synchronized from an adapter
+*/
+type Checkpoint struct {
+	Index     int       `json:"index"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+/*
+CheckpointStore persists the checkpoints of a Syncer per device
+*/
+type CheckpointStore interface {
+	//LoadCheckpoint returns the checkpoint of the device, ok is false if no checkpoint was saved yet
+	LoadCheckpoint(device string) (checkpoint Checkpoint, ok bool, err error)
+	//SaveCheckpoint stores the checkpoint of the device
+	SaveCheckpoint(device string, checkpoint Checkpoint) error
+}
+
+/*
+MemoryCheckpointStore keeps the checkpoints in memory, they are lost when the process ends
+*/
+type MemoryCheckpointStore struct {
+	mu          sync.Mutex
+	checkpoints map[string]Checkpoint
+}
+
+/*
+LoadCheckpoint returns the checkpoint of the device
+*/
+func (m *MemoryCheckpointStore) LoadCheckpoint(device string) (Checkpoint, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	checkpoint, ok := m.checkpoints[device]
+	return checkpoint, ok, nil
+}
+
+/*
+SaveCheckpoint stores the checkpoint of the device
+*/
+func (m *MemoryCheckpointStore) SaveCheckpoint(device string, checkpoint Checkpoint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.checkpoints == nil {
+		m.checkpoints = make(map[string]Checkpoint)
+	}
+	m.checkpoints[device] = checkpoint
+	return nil
+}
+
+/*
+FileCheckpointStore keeps the checkpoints of all devices in a JSON file. The file is replaced atomically on every save,
+so a crash never leaves a partially written file behind.
+*/
+type FileCheckpointStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+/*
+NewFileCheckpointStore returns a checkpoint store that uses the file at path. The file is created on the first save.
+*/
+func NewFileCheckpointStore(path string) *FileCheckpointStore {
+	return &FileCheckpointStore{path: path}
+}
+
+/*
+LoadCheckpoint returns the checkpoint of the device
+*/
+func (f *FileCheckpointStore) LoadCheckpoint(device string) (Checkpoint, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	checkpoints, err := f.read()
+	if err != nil {
+		return Checkpoint{}, false, err
+	}
+	checkpoint, ok := checkpoints[device]
+	return checkpoint, ok, nil
+}
+
+/*
+SaveCheckpoint stores the checkpoint of the device
+*/
+func (f *FileCheckpointStore) SaveCheckpoint(device string, checkpoint Checkpoint) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	checkpoints, err := f.read()
+	if err != nil {
+		return err
+	}
+	checkpoints[device] = checkpoint
+
+	data, err := json.MarshalIndent(checkpoints, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "Error while encoding checkpoints")
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(f.path), filepath.Base(f.path)+".*.tmp")
+	if err != nil {
+		return errors.Wrap(err, "Error while writing checkpoints")
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return errors.Wrap(err, "Error while writing checkpoints")
+	}
+	if err = os.Rename(tmp.Name(), f.path); err != nil {
+		return errors.Wrap(err, "Error while writing checkpoints")
+	}
+	return nil
+}
+
+func (f *FileCheckpointStore) read() (map[string]Checkpoint, error) {
+	checkpoints := make(map[string]Checkpoint)
+	data, err := ioutil.ReadFile(f.path)
+	if os.IsNotExist(err) {
+		return checkpoints, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "Error while reading checkpoints")
+	}
+	if err = json.Unmarshal(data, &checkpoints); err != nil {
+		return nil, errors.Wrap(err, "Error while decoding checkpoints")
+	}
+	return checkpoints, nil
+}
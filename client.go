@@ -1,11 +1,13 @@
 package dvlirclient
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"github.com/go-resty/resty/v2"
 	"github.com/pkg/errors"
-	"github.com/spf13/viper"
-	"log"
+	"io"
+	"io/ioutil"
 	"strconv"
 	"strings"
 )
@@ -18,10 +20,11 @@ type client struct {
 clientData - Contains data of a client
 */
 type clientData struct {
-	ipAddress string
-	password  string
-	sessionID string
-	resty     *resty.Client
+	ipAddress    string
+	baseURL      string
+	session      *session
+	renewSession bool
+	resty        *resty.Client
 }
 
 /*
@@ -40,12 +43,13 @@ func (c *client) isValid() bool {
 	return c.clientData != nil
 }
 
-func (d *DvLIRClient) get(path string, body string, pwd string) (*resty.Response, error) {
+func (d *DvLIRClient) get(ctx context.Context, path string, body string, pwd string) (*resty.Response, error) {
 	request := d.resty.R()
+	request.SetContext(ctx)
 	if body != "" {
 		request.SetBody(body)
 	}
-	response, err := request.Get("http://" + d.ipAddress + path + pwd)
+	response, err := request.Get(d.baseURL + path + pwd)
 	if err != nil {
 		return nil, errors.Wrap(err, "error during http request")
 	}
@@ -57,31 +61,77 @@ func (d *DvLIRClient) get(path string, body string, pwd string) (*resty.Response
 	return response, nil
 }
 
-func (d *DvLIRClient) post(path string, body string, header, queryParams map[string]string, file bool) (*resty.Response, error) {
+/*
+getStream performs a get request without reading the response body. The returned body has to be closed by the caller.
+*/
+func (d *DvLIRClient) getStream(ctx context.Context, path string) (io.ReadCloser, error) {
 	request := d.resty.R()
-	request.SetHeader("Content-Type", "application/json")
-
-	if header != nil {
-		request.SetHeaders(header)
+	request.SetContext(ctx)
+	request.SetDoNotParseResponse(true)
+	response, err := request.Get(d.baseURL + path)
+	if err != nil {
+		return nil, errors.Wrap(err, "error during http request")
 	}
 
-	if queryParams != nil {
-		request.SetQueryParams(queryParams)
+	body := response.RawBody()
+	if response.StatusCode() != 200 {
+		defer body.Close()
+		errorBody, _ := ioutil.ReadAll(io.LimitReader(body, 64*1024))
+		return nil, errors.Wrap(newHTTPError(response.StatusCode(), response.Status(), errorBody), "http status code != 200")
 	}
 
-	if file {
-		request.SetFile("firmware", body)
-	} else if body != "" {
-		request.SetBody(body)
-	}
+	return body, nil
+}
 
-	var response *resty.Response
-	var err error
-	response, err = request.Post(path)
+/*
+postFile posts data as a file in the multipart field with the given name through the resty client, so its headers,
+hooks and retries apply. progress is called with the number of bytes of data read into the request so far.
+*/
+func (d *DvLIRClient) postFile(ctx context.Context, path, field, fileName string, data []byte, progress func(sent, total int64)) (string, error) {
+	var reader io.Reader = bytes.NewReader(data)
+	if progress != nil {
+		reader = &progressReader{data: bytes.NewReader(data), total: int64(len(data)), progress: progress}
+	}
+	response, err := d.resty.R().
+		SetContext(ctx).
+		SetMultipartField(field, fileName, "application/octet-stream", reader).
+		Post(path)
 	if err != nil {
-		return nil, errors.Wrap(err, "error during http request")
+		return "", errors.Wrap(err, "error during http request")
 	}
-	return response, nil
+	if response.StatusCode() != 200 {
+		return "", errors.Wrap(getHTTPError(response), "http status code != 200")
+	}
+	return strings.TrimSpace(response.String()), nil
+}
+
+/*
+progressReader reports how many bytes of a file were read. resty reads the file again for every retry of the
+request, so the reader starts over once it was read completely.
+*/
+type progressReader struct {
+	//data isn't embedded, so io.Copy can't bypass Read with bytes.Reader.WriteTo
+	data     *bytes.Reader
+	read     int64
+	total    int64
+	eof      bool
+	progress func(sent, total int64)
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	if p.eof {
+		if _, err := p.data.Seek(0, io.SeekStart); err != nil {
+			return 0, err
+		}
+		p.read, p.eof = 0, false
+	}
+	n, err := p.data.Read(b)
+	if n > 0 {
+		p.read += int64(n)
+		p.progress(p.read, p.total)
+	}
+	p.eof = err == io.EOF
+	return n, err
 }
 
 //Http error handling
@@ -112,12 +162,16 @@ func (h HTTPError) Error() string {
 }
 
 func getHTTPError(response *resty.Response) error {
+	return newHTTPError(response.StatusCode(), response.Status(), response.Body())
+}
+
+func newHTTPError(statusCode int, status string, body []byte) error {
 	httpError := HTTPError{
-		StatusCode: response.StatusCode(),
-		Status:     response.Status(),
+		StatusCode: statusCode,
+		Status:     status,
 	}
 	var errorResponse ErrorResponse
-	err := json.Unmarshal(response.Body(), &errorResponse)
+	err := json.Unmarshal(body, &errorResponse)
 	if err != nil {
 		return httpError
 	}
@@ -125,28 +179,6 @@ func getHTTPError(response *resty.Response) error {
 	return httpError
 }
 
-/*
-init is used for initialising the config file
-*/
-func init() {
-	// Search config in home directory with name "eve-ng-api" (without extension).
-	viper.AddConfigPath("config/")
-	viper.SetConfigType("yaml")
-	viper.SetConfigName("dvlir-api")
-
-	//Set env var prefix to only match certain vars
-	viper.SetEnvPrefix("DvLIR_API")
-
-	// read in environment variables that match
-	viper.AutomaticEnv()
-
-	// If a config file is found, read it in.
-	err := viper.ReadInConfig()
-	if err != nil {
-		log.Printf("Couldn't read config")
-	}
-}
-
 func switchCase(e string) bool {
 	switch e {
 	case "Yes", "yes", "No", "no":
@@ -157,32 +189,13 @@ func switchCase(e string) bool {
 }
 
 /*
-DataLineConversion converts a string into a DataLine struct
+DataLineConversion converts a string into a DataLine struct. An empty DataLine is returned if the string isn't a
+valid line of the daten.csv file.
+
+Deprecated: Use ParseDataLine, which reports invalid lines.
 */
 func (d *DvLIRClient) DataLineConversion(input string) DataLine {
-	var line DataLine
-
-	inputSlice := strings.Split(input, ";")
-
-	line.Index = inputSlice[0]
-	line.Date = inputSlice[1]
-	line.Time = inputSlice[2]
-	line.DvLIRSn = inputSlice[3]
-
-	for ns := 8 - len(inputSlice[4]); ns > 0; ns-- {
-		inputSlice[4] = "0" + inputSlice[4]
-	}
-
-	line.MeterNumber = inputSlice[4]
-	line.OneEightZero = inputSlice[5]
-	line.OneEightOne = inputSlice[6]
-	line.OneEightTwo = inputSlice[7]
-	line.TwoEightZero = inputSlice[8]
-	line.TwoEightOne = inputSlice[9]
-	line.TwoEightTwo = inputSlice[10]
-	line.Power = inputSlice[11]
-	line.Status = inputSlice[12]
-
+	line, _ := ParseDataLine(input)
 	return line
 }
 
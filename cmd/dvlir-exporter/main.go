@@ -0,0 +1,80 @@
+/*
+Command dvlir-exporter serves the readings of DvLIR adapters in the Prometheus text exposition format.
+
+Usage:
+
+	dvlir-exporter [-listen :9761] [-inventory inventory.yaml] [-allow 192.168.0.0/24] [-password pw] [-timeout 10s]
+
+/metrics scrapes every device of the inventory, /probe?target=<device name> scrapes a single device of the inventory.
+Addresses outside the inventory are only probed if they are part of a network given with -allow. The password for
+these addresses can also be set with the DVLIR_PASSWORD environment variable.
+*/
+package main
+
+import (
+	"context"
+	"flag"
+	"github.com/inexio/dvlir-restapi-go-client/exporter"
+	"github.com/inexio/dvlir-restapi-go-client/fleet"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+func main() {
+	listen := flag.String("listen", ":9761", "address to listen on")
+	inventoryPath := flag.String("inventory", "", "inventory file (YAML or JSON) with the devices scraped by /metrics")
+	allow := flag.String("allow", "", "comma separated networks, e.g. 192.168.0.0/24, whose addresses may be probed although they are not part of the inventory")
+	password := flag.String("password", os.Getenv("DVLIR_PASSWORD"), "password for probe targets in the allowed networks")
+	concurrency := flag.Int("concurrency", 16, "number of adapters scraped in parallel by /metrics")
+	timeout := flag.Duration("timeout", 10*time.Second, "timeout of a single scrape")
+	flag.Parse()
+
+	opts := exporter.Options{Password: *password, Concurrency: *concurrency, Timeout: *timeout}
+	for _, cidr := range strings.Split(*allow, ",") {
+		if cidr = strings.TrimSpace(cidr); cidr == "" {
+			continue
+		}
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		opts.AllowedNetworks = append(opts.AllowedNetworks, network)
+	}
+	if *inventoryPath != "" {
+		inventory, err := fleet.LoadInventory(*inventoryPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		opts.Inventory = inventory
+	}
+	exp, err := exporter.New(opts)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	server := &http.Server{Addr: *listen, Handler: exp.Handler()}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		<-signals
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = server.Shutdown(ctx)
+		exp.Close(ctx)
+	}()
+
+	log.Printf("Listening on %s", *listen)
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+	<-done
+}
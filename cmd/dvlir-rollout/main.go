@@ -0,0 +1,113 @@
+/*
+Command dvlir-rollout upgrades the firmware of the adapters of an inventory in stages.
+
+Usage:
+
+	dvlir-rollout -inventory inventory.yaml -image dvlir-1.22.bin -version 1.22 [-state rollout.json] [flags]
+
+The rollout upgrades a canary batch first and the remaining adapters in waves. It stops if the failure rate exceeds
+-max-failure-rate. The state is saved in the -state file, running the command again resumes an interrupted rollout.
+A report of every adapter is printed at the end. The exit code is 0 if all adapters run the target version.
+*/
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/inexio/dvlir-restapi-go-client/fleet"
+	"github.com/inexio/dvlir-restapi-go-client/rollout"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"text/tabwriter"
+	"time"
+)
+
+func main() {
+	inventoryPath := flag.String("inventory", "", "inventory file (YAML or JSON)")
+	imagePath := flag.String("image", "", "firmware image")
+	version := flag.String("version", "", "firmware version of the image")
+	statePath := flag.String("state", "rollout.json", "file the state of the rollout is saved in")
+	selector := flag.String("selector", "", "devices to upgrade, e.g. site=berlin")
+	canary := flag.Int("canary", 1, "number of devices in the canary batch")
+	waveSize := flag.Int("wave", 10, "number of devices per wave")
+	concurrency := flag.Int("concurrency", 0, "number of devices upgraded in parallel (default: wave size)")
+	maxFailureRate := flag.Float64("max-failure-rate", 0, "highest tolerated share of failed devices (0..1)")
+	timeout := flag.Duration("timeout", 10*time.Minute, "timeout of the upgrade of a single device")
+	retryFailed := flag.Bool("retry-failed", false, "upgrade devices again which failed in an earlier run")
+	asJSON := flag.Bool("json", false, "print the report as JSON")
+	flag.Parse()
+
+	if *inventoryPath == "" || *imagePath == "" || *version == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	inventory, err := fleet.LoadInventory(*inventoryPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	image, err := ioutil.ReadFile(*imagePath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	selected, err := fleet.ParseSelector(*selector)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	r, err := rollout.New(inventory, filepath.Base(*imagePath), image, *version, rollout.Options{
+		Selector:       selected,
+		Canary:         *canary,
+		WaveSize:       *waveSize,
+		Concurrency:    *concurrency,
+		MaxFailureRate: *maxFailureRate,
+		Timeout:        *timeout,
+		Store:          rollout.NewFileStateStore(*statePath),
+		RetryFailed:    *retryFailed,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	//an interrupted rollout stops after the running upgrades and can be resumed later
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		<-signals
+		cancel()
+	}()
+	report, err := r.Run(ctx)
+	if report != nil {
+		printReport(report, *asJSON)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if report.Count(rollout.StatusSucceeded)+report.Count(rollout.StatusSkipped) != len(report.Devices) {
+		os.Exit(1)
+	}
+}
+
+func printReport(report *rollout.Report, asJSON bool) {
+	if asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		_ = encoder.Encode(report)
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "DEVICE\tSTATUS\tWAVE\tATTEMPTS\tPREVIOUS\tVERSION\tDURATION\tERROR")
+	for _, device := range report.Devices {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\t%s\t%s\t%s\n", device.Name, device.Status, device.Wave, device.Attempts,
+			device.PreviousVersion, device.Version, device.Duration.Round(time.Millisecond), device.Error)
+	}
+	_ = w.Flush()
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	dvlirclient "github.com/inexio/dvlir-restapi-go-client"
+	"github.com/pkg/errors"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// Exit codes by error class
+const (
+	exitOK          = 0
+	exitError       = 1
+	exitUsage       = 2
+	exitAuth        = 3
+	exitUnreachable = 4
+	exitRejected    = 5
+	exitAborted     = 6
+)
+
+// Errors of the command line tool
+var (
+	errUsage   = errors.New("invalid usage")
+	errAborted = errors.New("aborted")
+)
+
+/*
+app contains the global flags and the streams of a single invocation
+*/
+type app struct {
+	address  string
+	password string
+	config   string
+	device   string
+	output   string
+	yes      bool
+	timeout  time.Duration
+
+	stdin  *bufio.Reader
+	stdout io.Writer
+	stderr io.Writer
+	client *dvlirclient.DvLIRClient
+}
+
+/*
+register adds the global flags to a flag set, so they can be given before and after the command
+*/
+func (a *app) register(fs *flag.FlagSet) {
+	fs.StringVar(&a.address, "address", a.address, "address of the adapter (env DVLIR_ADDRESS)")
+	fs.StringVar(&a.password, "password", a.password, "password of the adapter (env DVLIR_PASSWORD)")
+	fs.StringVar(&a.config, "config", a.config, "YAML or JSON config file containing IPAddress and Password")
+	fs.StringVar(&a.device, "device", a.device, "name of the device in the config file")
+	fs.StringVar(&a.output, "output", a.output, "output format: table, json or csv")
+	fs.BoolVar(&a.yes, "yes", a.yes, "don't ask for confirmation")
+	fs.DurationVar(&a.timeout, "timeout", a.timeout, "timeout of the command")
+}
+
+func (a *app) flagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(a.stderr)
+	a.register(fs)
+	return fs
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	a := &app{output: "table", timeout: time.Minute, stdin: bufio.NewReader(stdin), stdout: stdout, stderr: stderr}
+
+	fs := a.flagSet("dvlirctl")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: dvlirctl [flags] <command> [command flags] [arguments]")
+		fmt.Fprintln(stderr, "Commands: "+strings.Join(commandNames(), ", "))
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	cmd, ok := commands[fs.Arg(0)]
+	if !ok {
+		fmt.Fprintln(stderr, "Unknown command: "+fs.Arg(0))
+		fs.Usage()
+		return exitUsage
+	}
+
+	err := a.execute(cmd, fs.Arg(0), fs.Args()[1:])
+	code := exitCode(err)
+	if err != nil && code != exitAborted {
+		fmt.Fprintln(stderr, "Error: "+err.Error())
+	}
+	return code
+}
+
+/*
+execute parses the flags of the command, logs in and runs the command. Fleet commands log in to the adapters
+themselves.
+*/
+func (a *app) execute(cmd command, name string, args []string) error {
+	fs := a.flagSet("dvlirctl " + name)
+	run := cmd.setup(fs)
+	args, err := parse(fs, args)
+	if err != nil {
+		return errUsage
+	}
+	switch a.output {
+	case "table", "json", "csv":
+	default:
+		return errors.Wrap(errUsage, "unknown output format "+a.output)
+	}
+	if cmd.fleet {
+		return run(context.Background(), a, args)
+	}
+	if err := a.loadCredentials(); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), a.timeout)
+	defer cancel()
+
+	a.client, err = dvlirclient.NewDvLIRClient(a.address, a.password, dvlirclient.WithSessionRenewal())
+	if err != nil {
+		return err
+	}
+	if err := a.client.LoginContext(ctx); err != nil {
+		return err
+	}
+	defer func() {
+		//A restart or reset already ended the session, so the logout must not block
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = a.client.LogoutContext(ctx)
+	}()
+
+	return run(ctx, a, args)
+}
+
+/*
+parse parses the flags of a command, which may follow its arguments like in "network set -dhcp yes"
+*/
+func parse(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+/*
+loadCredentials fills the address and the password from the environment or the config file if they weren't given
+as flags
+*/
+func (a *app) loadCredentials() error {
+	if a.address == "" {
+		a.address = os.Getenv("DVLIR_ADDRESS")
+	}
+	if a.password == "" {
+		a.password = os.Getenv("DVLIR_PASSWORD")
+	}
+	if a.config != "" && (a.address == "" || a.password == "") {
+		config, err := dvlirclient.LoadConfig(a.config)
+		if err != nil {
+			return err
+		}
+		device, err := config.Device(a.device)
+		if err != nil {
+			return errors.Wrap(errUsage, err.Error())
+		}
+		if a.address == "" {
+			a.address = device.IPAddress
+		}
+		if a.password == "" {
+			a.password = device.Password
+		}
+	}
+	if a.address == "" {
+		return errors.Wrap(errUsage, "no address given")
+	}
+	return nil
+}
+
+/*
+confirm asks the user to confirm a destructive command
+*/
+func (a *app) confirm(question string) error {
+	if a.yes {
+		return nil
+	}
+	fmt.Fprint(a.stderr, question+" [y/N] ")
+	answer, err := a.stdin.ReadString('\n')
+	if err != nil && answer == "" {
+		return errAborted
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return nil
+	}
+	return errAborted
+}
+
+/*
+progress returns a function which prints the upload progress to stderr in steps of 10 percent
+*/
+func (a *app) progress() func(sent, total int64) {
+	last := int64(-1)
+	return func(sent, total int64) {
+		if total <= 0 {
+			return
+		}
+		percent := sent * 100 / total / 10 * 10
+		if percent != last {
+			last = percent
+			fmt.Fprintf(a.stderr, "Uploading: %d%%\n", percent)
+		}
+	}
+}
+
+/*
+exitCode returns the exit code of the error class
+*/
+func exitCode(err error) int {
+	if err == nil {
+		return exitOK
+	}
+
+	var httpError dvlirclient.HTTPError
+	var firmwareError *dvlirclient.FirmwareUploadError
+	var fieldError *dvlirclient.FieldError
+	var netError net.Error
+	switch {
+	case errors.Is(err, errAborted):
+		return exitAborted
+	case errors.Is(err, errUsage), errors.As(err, &fieldError), errors.Is(err, dvlirclient.ErrInvalidFirmware):
+		return exitUsage
+	case errors.Is(err, dvlirclient.ErrWrongPassword), errors.Is(err, dvlirclient.ErrSessionExpired):
+		return exitAuth
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netError), errors.Is(err, dvlirclient.ErrNotReady):
+		return exitUnreachable
+	case errors.As(err, &httpError), errors.As(err, &firmwareError),
+		errors.Is(err, dvlirclient.ErrNTPUnreachable), errors.Is(err, dvlirclient.ErrNTPRateLimited),
+		errors.Is(err, dvlirclient.ErrNTPInvalidName), errors.Is(err, dvlirclient.ErrPasswordMismatch),
+		errors.Is(err, dvlirclient.ErrIllegalPasswordChar), errors.Is(err, dvlirclient.ErrNoParameters),
+		errors.Is(err, dvlirclient.ErrFirmwareVersionMismatch):
+		return exitRejected
+	}
+	return exitError
+}
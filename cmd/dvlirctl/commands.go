@@ -0,0 +1,504 @@
+package main
+
+import (
+	"context"
+	"flag"
+	dvlirclient "github.com/inexio/dvlir-restapi-go-client"
+	"github.com/pkg/errors"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+/*
+command registers its flags and returns the function which runs it with the remaining arguments
+*/
+type command struct {
+	setup func(fs *flag.FlagSet) func(ctx context.Context, a *app, args []string) error
+	//fleet commands work on the adapters of an inventory, so no single adapter is logged in to
+	fleet bool
+}
+
+var commands = map[string]command{
+	"login-test":  {setup: noFlags(loginTest)},
+	"data":        {setup: data},
+	"momentary":   {setup: noFlags(momentary)},
+	"info":        {setup: noFlags(info)},
+	"network":     {setup: network},
+	"system":      {setup: system},
+	"blink":       {setup: blink},
+	"ntp-test":    {setup: noFlags(ntpTest)},
+	"password":    {setup: password},
+	"firmware":    {setup: firmware},
+	"restart":     {setup: restart},
+	"reset-all":   {setup: resetAll},
+	"backup":      {setup: noFlags(backup)},
+	"restore":     {setup: restore},
+	"delete-data": {setup: deleteData},
+	"plan":        {setup: plan, fleet: true},
+	"apply":       {setup: apply, fleet: true},
+}
+
+func commandNames() []string {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func noFlags(run func(ctx context.Context, a *app, args []string) error) func(*flag.FlagSet) func(context.Context, *app, []string) error {
+	return func(*flag.FlagSet) func(context.Context, *app, []string) error {
+		return run
+	}
+}
+
+/*
+result is printed for commands which only return the response of the adapter
+*/
+type result struct {
+	Result string `json:"result"`
+}
+
+func argCount(args []string, n int, usage string) error {
+	if len(args) != n {
+		return errors.Wrap(errUsage, "usage: dvlirctl "+usage)
+	}
+	return nil
+}
+
+func loginTest(ctx context.Context, a *app, args []string) error {
+	if err := argCount(args, 0, "login-test"); err != nil {
+		return err
+	}
+	//The login already succeeded
+	return a.print(result{Result: "OK"})
+}
+
+func data(fs *flag.FlagSet) func(context.Context, *app, []string) error {
+	lines := fs.Int("lines", 96, "number of lines (1..14400)")
+	return func(ctx context.Context, a *app, args []string) error {
+		if err := argCount(args, 0, "data [-lines n]"); err != nil {
+			return err
+		}
+		file, err := a.client.GetDataFileContext(ctx, *lines)
+		if err != nil {
+			return err
+		}
+		return a.print(file)
+	}
+}
+
+func momentary(ctx context.Context, a *app, args []string) error {
+	if err := argCount(args, 0, "momentary"); err != nil {
+		return err
+	}
+	values, err := a.client.GetMomentaryValuesContext(ctx)
+	if err != nil {
+		return err
+	}
+	return a.print(values)
+}
+
+func info(ctx context.Context, a *app, args []string) error {
+	if err := argCount(args, 0, "info"); err != nil {
+		return err
+	}
+	general, err := a.client.GetGeneralInformationContext(ctx)
+	if err != nil {
+		return err
+	}
+	return a.print(general)
+}
+
+func network(fs *flag.FlagSet) func(context.Context, *app, []string) error {
+	dhcp := fs.String("dhcp", "", "use DHCP: yes or no")
+	ip := fs.String("ip", "", "static IP address")
+	mask := fs.String("mask", "", "subnet mask, e.g. 255.255.255.0")
+	gateway := fs.String("gateway", "", "gateway")
+	dns := fs.String("dns", "", "DNS server")
+	ntp := fs.String("ntp", "", "use an NTP server: yes or no")
+	ntpName := fs.String("ntp-name", "", "name of the NTP server")
+	dateTime := fs.String("datetime", "", "date and time, e.g. 2020-01-01T12:00:00, or \"now\"")
+
+	return func(ctx context.Context, a *app, args []string) error {
+		usage := "network get | network set [-dhcp yes|no] [-ip ip -mask mask -gateway gw] [-dns ip] " +
+			"[-ntp yes|no] [-ntp-name name] [-datetime time]"
+		if err := argCount(args, 1, usage); err != nil {
+			return err
+		}
+		switch args[0] {
+		case "get":
+			information, err := a.client.GetNetworkInformationContext(ctx)
+			if err != nil {
+				return err
+			}
+			return a.print(information)
+		case "set":
+			var settings dvlirclient.NetworkSettings
+			var err error
+			if settings.DHCP, err = parseYesNo("dhcp", *dhcp); err != nil {
+				return err
+			}
+			if settings.NTPServer, err = parseYesNo("ntp", *ntp); err != nil {
+				return err
+			}
+			for _, address := range []struct {
+				name  string
+				value string
+				ip    *net.IP
+			}{{"ip", *ip, &settings.IPAddress}, {"gateway", *gateway, &settings.Gateway}, {"dns", *dns, &settings.DNSServer}} {
+				if address.value == "" {
+					continue
+				}
+				if *address.ip = net.ParseIP(address.value).To4(); *address.ip == nil {
+					return errors.Wrap(errUsage, "invalid "+address.name+" "+address.value)
+				}
+			}
+			if *mask != "" {
+				m := net.ParseIP(*mask).To4()
+				if m == nil {
+					return errors.Wrap(errUsage, "invalid mask "+*mask)
+				}
+				settings.SubnetMask = net.IPMask(m)
+			}
+			settings.NTPName = *ntpName
+			switch *dateTime {
+			case "":
+			case "now":
+				settings.DateTime = time.Now()
+			default:
+				if settings.DateTime, err = time.ParseInLocation("2006-01-02T15:04:05", *dateTime, time.Local); err != nil {
+					return errors.Wrap(errUsage, "invalid datetime "+*dateTime)
+				}
+			}
+
+			if err := settings.Validate(); err != nil {
+				return err
+			}
+			if err := a.confirm("Change the network settings of " + a.address + "?"); err != nil {
+				return err
+			}
+			response, err := a.client.ApplyNetworkSettings(ctx, settings)
+			if err != nil {
+				return err
+			}
+			return a.print(result{Result: response})
+		}
+		return errors.Wrap(errUsage, "usage: dvlirctl "+usage)
+	}
+}
+
+func system(fs *flag.FlagSet) func(context.Context, *app, []string) error {
+	interval := fs.String("interval", "", "saving interval: 15min, min or sec")
+	allowReset := fs.String("allow-reset", "", "allow a reset with the default password: yes or no")
+
+	return func(ctx context.Context, a *app, args []string) error {
+		usage := "system get | system set [-interval 15min|min|sec] [-allow-reset yes|no]"
+		if err := argCount(args, 1, usage); err != nil {
+			return err
+		}
+		switch args[0] {
+		case "get":
+			information, err := a.client.GetSystemInformationContext(ctx)
+			if err != nil {
+				return err
+			}
+			return a.print(information)
+		case "set":
+			if *interval == "" && *allowReset == "" {
+				return errors.Wrap(errUsage, "usage: dvlirctl "+usage)
+			}
+			savingInterval := dvlirclient.SavingInterval(*interval)
+			if *interval != "" && !savingInterval.Valid() {
+				return errors.Wrap(errUsage, "invalid saving interval "+*interval)
+			}
+			if _, err := parseYesNo("allow-reset", *allowReset); err != nil {
+				return err
+			}
+
+			var responses []result
+			if *interval != "" {
+				response, err := a.client.ChangeSavingIntervalContext(ctx, savingInterval)
+				if err != nil {
+					return err
+				}
+				responses = append(responses, result{Result: response})
+			}
+			if *allowReset != "" {
+				response, err := a.client.AllowResetWithPwdContext(ctx, *allowReset)
+				if err != nil {
+					return err
+				}
+				responses = append(responses, result{Result: response})
+			}
+			return a.print(responses)
+		}
+		return errors.Wrap(errUsage, "usage: dvlirctl "+usage)
+	}
+}
+
+func blink(fs *flag.FlagSet) func(context.Context, *app, []string) error {
+	count := fs.Int("count", 10, "number of blinks (1..10000)")
+	pause := fs.Int("pause", 500, "pause between the blinks in ms (1..1000)")
+	return func(ctx context.Context, a *app, args []string) error {
+		if err := argCount(args, 0, "blink [-count n] [-pause ms]"); err != nil {
+			return err
+		}
+		if *count < 1 || *count > 10000 || *pause < 1 || *pause > 1000 {
+			return errors.Wrap(errUsage, "count or pause out of range")
+		}
+		response, err := a.client.BlinkContext(ctx, *count, *pause)
+		if err != nil {
+			return err
+		}
+		return a.print(struct {
+			Response int `json:"response"`
+		}{response})
+	}
+}
+
+func ntpTest(ctx context.Context, a *app, args []string) error {
+	if err := argCount(args, 1, "ntp-test <name>"); err != nil {
+		return err
+	}
+	if _, err := a.client.NTPServerTestContext(ctx, args[0]); err != nil {
+		return err
+	}
+	return a.print(result{Result: "NTP-server " + args[0] + " is reachable"})
+}
+
+func password(fs *flag.FlagSet) func(context.Context, *app, []string) error {
+	newPassword := fs.String("new", "", "new password (env DVLIR_NEW_PASSWORD)")
+	return func(ctx context.Context, a *app, args []string) error {
+		if err := argCount(args, 0, "password -new <password>"); err != nil {
+			return err
+		}
+		if *newPassword == "" {
+			*newPassword = os.Getenv("DVLIR_NEW_PASSWORD")
+		}
+		if *newPassword == "" {
+			return errors.Wrap(errUsage, "no new password given")
+		}
+		if err := a.confirm("Change the password of " + a.address + "?"); err != nil {
+			return err
+		}
+		response, err := a.client.ChangePasswordContext(ctx, a.password, *newPassword, *newPassword)
+		if err != nil {
+			return err
+		}
+		return a.print(result{Result: response})
+	}
+}
+
+func firmware(fs *flag.FlagSet) func(context.Context, *app, []string) error {
+	manifestPath := fs.String("manifest", "", "JSON manifest with the size, checksum and version of the image")
+	version := fs.String("version", "", "firmware version expected after the upgrade")
+	return func(ctx context.Context, a *app, args []string) error {
+		if len(args) != 2 || args[0] != "upload" {
+			return errors.Wrap(errUsage, "usage: dvlirctl firmware upload [-manifest file] [-version v] <file>")
+		}
+		opts := []dvlirclient.FirmwareOption{dvlirclient.WithUploadProgress(a.progress())}
+		if *manifestPath != "" {
+			file, err := os.Open(*manifestPath)
+			if err != nil {
+				return errors.Wrap(errUsage, err.Error())
+			}
+			manifest, err := dvlirclient.ParseFirmwareManifest(file)
+			file.Close()
+			if err != nil {
+				return errors.Wrap(errUsage, err.Error())
+			}
+			opts = append(opts, dvlirclient.WithFirmwareManifest(manifest))
+		}
+		if *version != "" {
+			opts = append(opts, dvlirclient.WithExpectedFirmwareVersion(*version))
+		}
+
+		image, err := os.Open(args[1])
+		if err != nil {
+			return errors.Wrap(errUsage, err.Error())
+		}
+		defer image.Close()
+		stat, err := image.Stat()
+		if err != nil {
+			return err
+		}
+		if err := a.confirm("Upload " + args[1] + " to " + a.address + "?"); err != nil {
+			return err
+		}
+		report, err := a.client.UploadFirmwareFrom(ctx, filepath.Base(args[1]), image, stat.Size(), opts...)
+		if report != nil {
+			if printErr := a.print(*report); printErr != nil && err == nil {
+				err = printErr
+			}
+		}
+		return err
+	}
+}
+
+func restart(fs *flag.FlagSet) func(context.Context, *app, []string) error {
+	wait := fs.Bool("wait", false, "wait until the adapter is ready again")
+	return func(ctx context.Context, a *app, args []string) error {
+		if err := argCount(args, 0, "restart [-wait]"); err != nil {
+			return err
+		}
+		if err := a.confirm("Restart " + a.address + "?"); err != nil {
+			return err
+		}
+		response, err := a.client.RestartContext(ctx)
+		if err != nil {
+			return err
+		}
+		return a.printReady(ctx, response, *wait)
+	}
+}
+
+/*
+readyResult is printed for restarting commands with -wait
+*/
+type readyResult struct {
+	Result   string        `json:"result"`
+	Downtime time.Duration `json:"downtime"`
+	Attempts int           `json:"attempts"`
+}
+
+/*
+printReady prints the response of a restarting command, with wait after the adapter is ready again
+*/
+func (a *app) printReady(ctx context.Context, response string, wait bool) error {
+	if !wait {
+		return a.print(result{Result: response})
+	}
+	report, err := a.client.WaitReady(ctx, dvlirclient.ReadyOptions{Timeout: a.timeout})
+	if err != nil {
+		return err
+	}
+	return a.print(readyResult{Result: response, Downtime: report.Downtime, Attempts: report.Attempts})
+}
+
+func resetAll(fs *flag.FlagSet) func(context.Context, *app, []string) error {
+	code := fs.String("code", "", "reset code of the adapter, see system get")
+	wait := fs.Bool("wait", false, "wait until the adapter is ready again")
+	return func(ctx context.Context, a *app, args []string) error {
+		if err := argCount(args, 0, "reset-all -code <code> [-wait]"); err != nil {
+			return err
+		}
+		if *code == "" {
+			return errors.Wrap(errUsage, "no reset code given")
+		}
+		if err := a.confirm("Reset " + a.address + " to factory settings?"); err != nil {
+			return err
+		}
+		response, err := a.client.ResetAllContext(ctx, *code)
+		if err != nil {
+			return err
+		}
+		return a.printReady(ctx, response, *wait)
+	}
+}
+
+func deleteData(fs *flag.FlagSet) func(context.Context, *app, []string) error {
+	code := fs.String("code", "", "delete code of the adapter, see system get")
+	return func(ctx context.Context, a *app, args []string) error {
+		if err := argCount(args, 0, "delete-data -code <code>"); err != nil {
+			return err
+		}
+		if *code == "" {
+			return errors.Wrap(errUsage, "no delete code given")
+		}
+		if err := a.confirm("Delete all saved data of " + a.address + "?"); err != nil {
+			return err
+		}
+		response, err := a.client.DeleteDataContext(ctx, *code)
+		if err != nil {
+			return err
+		}
+		return a.print(result{Result: response})
+	}
+}
+
+/*
+parseYesNo parses an optional yes/no flag
+*/
+func parseYesNo(name, value string) (*bool, error) {
+	switch value {
+	case "":
+		return nil, nil
+	case "yes":
+		b := true
+		return &b, nil
+	case "no":
+		b := false
+		return &b, nil
+	}
+	return nil, errors.Wrap(errUsage, "-"+name+" must be yes or no")
+}
+
+func backup(ctx context.Context, a *app, args []string) error {
+	if err := argCount(args, 1, "backup <file>"); err != nil {
+		return err
+	}
+	snapshot, err := a.client.Backup(ctx)
+	if err != nil {
+		return err
+	}
+	if err := snapshot.Save(args[0]); err != nil {
+		return err
+	}
+	return a.print(result{Result: args[0]})
+}
+
+/*
+restoreChange is printed for every setting that differs from the snapshot
+*/
+type restoreChange struct {
+	Field   string `json:"field"`
+	Current string `json:"current"`
+	Desired string `json:"desired"`
+	Status  string `json:"status"`
+}
+
+func restore(fs *flag.FlagSet) func(context.Context, *app, []string) error {
+	dryRun := fs.Bool("dry-run", false, "only print the changes")
+	keepAddress := fs.Bool("keep-address", false, "leave DHCP, IP address, subnet mask and gateway unchanged")
+	return func(ctx context.Context, a *app, args []string) error {
+		if err := argCount(args, 1, "restore [-dry-run] [-keep-address] <file>"); err != nil {
+			return err
+		}
+		snapshot, err := dvlirclient.LoadSnapshot(args[0])
+		if err != nil {
+			return errors.Wrap(errUsage, err.Error())
+		}
+		opts := dvlirclient.RestoreOptions{DryRun: true, KeepAddress: *keepAddress}
+		report, err := a.client.Restore(ctx, snapshot, opts)
+		if err != nil {
+			return err
+		}
+		if !*dryRun && len(report.Changes) > 0 {
+			if err := a.confirm("Restore " + args[0] + " to " + a.address + "?"); err != nil {
+				return err
+			}
+			opts.DryRun = false
+			if report, err = a.client.Restore(ctx, snapshot, opts); err != nil {
+				return err
+			}
+		}
+
+		changes := make([]restoreChange, 0, len(report.Changes)+len(report.ReadOnly))
+		for _, change := range report.Changes {
+			status := "planned"
+			if report.Applied {
+				status = "applied"
+			}
+			changes = append(changes, restoreChange{change.Field, change.Current, change.Desired, status})
+		}
+		for _, change := range report.ReadOnly {
+			changes = append(changes, restoreChange{change.Field, change.Current, change.Desired, "read-only"})
+		}
+		return a.print(changes)
+	}
+}
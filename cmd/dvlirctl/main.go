@@ -0,0 +1,57 @@
+/*
+Command dvlirctl reads and changes the settings of a DvLIR adapter.
+
+Usage:
+
+	dvlirctl [flags] <command> [command flags] [arguments]
+
+Commands:
+
+	login-test          check the address and the password
+	data                print the data file
+	momentary           print the momentary values
+	info                print the general information
+	network get|set     print or change the network settings
+	system get|set      print or change the system settings
+	blink               let the LED blink
+	ntp-test <name>     test an NTP server
+	password            change the password
+	firmware upload <f> upload a firmware file
+	restart [-wait]     restart the adapter, -wait waits until it is ready again
+	reset-all           reset the adapter to factory settings
+	delete-data         delete all saved data
+	backup <file>       save the settings to a YAML or JSON file
+	restore <file>      restore the settings of a backup, -dry-run only prints the changes
+	plan                print the changes needed to bring the adapters of an inventory into their desired state
+	apply               print the changes, ask for confirmation and apply them
+
+The address and the password are taken from the -address and -password flags, the DVLIR_ADDRESS and DVLIR_PASSWORD
+environment variables or the config file given by -config, in this order. -device selects an adapter of a config file
+with several devices. Destructive commands ask for confirmation
+unless -yes is given.
+
+plan and apply work on the adapters of the inventory given by -inventory, whose credentials are used instead. The
+desired state is described in the YAML or JSON file given by -desired, see fleet.DesiredStates. -selector limits them
+to some adapters, e.g. site=berlin, and -timeout applies to every adapter. apply searches for an adapter that
+switches to DHCP in the range given by -scan, e.g. 192.168.0.0/24, without it the adapter is skipped because its new
+address would be unknown.
+
+Exit codes:
+
+	0  success
+	1  unexpected error
+	2  invalid usage or arguments, inventory or desired state
+	3  wrong password or expired session
+	4  adapter unreachable or timeout
+	5  request rejected by the adapter
+	6  aborted by the user
+*/
+package main
+
+import (
+	"os"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
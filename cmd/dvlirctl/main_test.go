@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"github.com/inexio/dvlir-restapi-go-client/dvlirtest"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func dvlirctl(stdin string, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+/*
+TestRun covers:
+	- info in table, json and csv format
+	- data with a command flag
+	- system set with an invalid saving interval
+	- restart with a declined and a confirmed prompt
+	- restart with -wait
+	- credentials from a config file
+	- backup and restore with a dry run
+	- exit codes of a wrong password, an unreachable adapter and an unknown command
+*/
+func TestRun(t *testing.T) {
+	server := dvlirtest.NewServer()
+	defer server.Close()
+	credentials := []string{"-address", server.Address(), "-password", server.Password()}
+
+	code, stdout, stderr := dvlirctl("", append(credentials, "info")...)
+	if assert.Equal(t, exitOK, code, stderr) {
+		assert.Regexp(t, `(?m)^DeviceSn\s+`+server.State().DeviceSn+`$`, stdout)
+	}
+	code, stdout, _ = dvlirctl("", append(credentials, "-output", "json", "info")...)
+	if assert.Equal(t, exitOK, code) {
+		assert.Contains(t, stdout, `"device_sn": "`+server.State().DeviceSn+`"`)
+	}
+	code, stdout, _ = dvlirctl("", append(credentials, "momentary", "-output", "csv")...)
+	if assert.Equal(t, exitOK, code) {
+		assert.True(t, strings.HasPrefix(stdout, "MeterNumber,OBISNum,MomentaryPower,"), stdout)
+		assert.Contains(t, stdout, "MeterReadingsAP[9]")
+	}
+	code, stdout, _ = dvlirctl("", append(credentials, "data", "-lines", "3", "-output", "csv")...)
+	if assert.Equal(t, exitOK, code) {
+		assert.Len(t, strings.Split(strings.TrimSpace(stdout), "\n"), 4)
+	}
+
+	code, _, stderr = dvlirctl("", append(credentials, "system", "set", "-interval", "hourly")...)
+	assert.Equal(t, exitUsage, code)
+	assert.Contains(t, stderr, "invalid saving interval hourly")
+
+	reboots := server.Reboots()
+	code, _, _ = dvlirctl("n\n", append(credentials, "restart")...)
+	assert.Equal(t, exitAborted, code)
+	assert.Equal(t, reboots, server.Reboots(), "Adapter was restarted without confirmation")
+	code, _, _ = dvlirctl("yes\n", append(credentials, "restart")...)
+	assert.Equal(t, exitOK, code)
+	assert.Equal(t, reboots+1, server.Reboots(), "Adapter wasn't restarted")
+	code, stdout, _ = dvlirctl("", append(credentials, "-yes", "-output", "json", "restart", "-wait")...)
+	if assert.Equal(t, exitOK, code) {
+		assert.Contains(t, stdout, `"attempts": 1`)
+	}
+	assert.Equal(t, reboots+2, server.Reboots(), "Adapter wasn't restarted")
+
+	dir, err := ioutil.TempDir("", "dvlirctl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	config := filepath.Join(dir, "dvlirctl.yaml")
+	err = ioutil.WriteFile(config, []byte("IPAddress: "+server.Address()+"\nPassword: "+server.Password()+"\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, _, stderr = dvlirctl("", "-config", config, "login-test")
+	assert.Equal(t, exitOK, code, stderr)
+
+	backup := filepath.Join(dir, "backup.yaml")
+	code, _, stderr = dvlirctl("", append(credentials, "backup", backup)...)
+	assert.Equal(t, exitOK, code, stderr)
+	server.Update(func(state *dvlirtest.State) { state.NTPName = "pool.ntp.org" })
+	code, stdout, _ = dvlirctl("", append(credentials, "-output", "csv", "restore", "-dry-run", backup)...)
+	if assert.Equal(t, exitOK, code) {
+		assert.Equal(t, "Field,Current,Desired,Status\nnetwork.ntp_name,pool.ntp.org,de.pool.ntp.org,planned\n", stdout)
+	}
+	assert.Equal(t, "pool.ntp.org", server.State().NTPName, "Dry run changed the adapter")
+	code, _, _ = dvlirctl("", append(credentials, "-yes", "restore", backup)...)
+	assert.Equal(t, exitOK, code)
+	assert.Equal(t, "de.pool.ntp.org", server.State().NTPName, "Backup wasn't restored")
+
+	code, _, _ = dvlirctl("", "-address", server.Address(), "-password", "wrong", "login-test")
+	assert.Equal(t, exitAuth, code)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unreachable := listener.Addr().String()
+	listener.Close()
+	code, _, _ = dvlirctl("", "-address", unreachable, "-password", "pw", "login-test")
+	assert.Equal(t, exitUnreachable, code)
+
+	code, _, _ = dvlirctl("", "unknown")
+	assert.Equal(t, exitUsage, code)
+}
+
+/*
+TestRun_Reconcile covers:
+	- plan with a changed and an unchanged adapter
+	- apply with a declined and a confirmed prompt
+	- plan after apply
+	- plan of a desired state that enables DHCP
+	- apply refusing only the adapter that switches to DHCP without -scan
+	- apply with -scan
+	- desired state of an unknown adapter
+	- missing flags
+*/
+func TestRun_Reconcile(t *testing.T) {
+	first, second := dvlirtest.NewServer(), dvlirtest.NewServer()
+	defer first.Close()
+	defer second.Close()
+
+	dir, err := ioutil.TempDir("", "dvlirctl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	inventory := filepath.Join(dir, "inventory.yaml")
+	desired := filepath.Join(dir, "desired.yaml")
+	write := func(path, content string) {
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(inventory, "devices:\n"+
+		"  - {name: berlin-1, address: \""+first.Address()+"\", password: "+first.Password()+"}\n"+
+		"  - {name: berlin-2, address: \""+second.Address()+"\", password: "+second.Password()+"}\n")
+	write(desired, `
+defaults:
+  ntp_server: true
+  ntp_name: de.pool.ntp.org
+devices:
+  berlin-2:
+    ntp_name: pool.ntp.org
+    saving_interval: min
+`)
+	files := []string{"-inventory", inventory, "-desired", desired}
+
+	code, stdout, stderr := dvlirctl("", append([]string{"-output", "csv", "plan"}, files...)...)
+	assert.Equal(t, exitOK, code, stderr)
+	assert.Equal(t, "Device,Field,Current,Desired,Status\n"+
+		"berlin-2,system.saving_interval,15min,min,planned\n"+
+		"berlin-2,network.ntp_name,de.pool.ntp.org,pool.ntp.org,planned\n", stdout)
+	assert.Contains(t, stderr, "Plan: 2 changes on 1 of 2 adapters, 0 failed\n")
+
+	code, _, _ = dvlirctl("n\n", append([]string{"apply"}, files...)...)
+	assert.Equal(t, exitAborted, code)
+	assert.Equal(t, "de.pool.ntp.org", second.State().NTPName, "Changes were applied without confirmation")
+
+	code, stdout, stderr = dvlirctl("y\n", append([]string{"-output", "csv", "apply"}, files...)...)
+	assert.Equal(t, exitOK, code, stderr)
+	assert.Contains(t, stdout, "berlin-2,network.ntp_name,de.pool.ntp.org,pool.ntp.org,applied\n")
+	assert.Contains(t, stderr, "Apply: 2 changes on 1 of 1 adapters, 0 failed\n")
+	assert.Equal(t, "pool.ntp.org", second.State().NTPName)
+	assert.Equal(t, "min", second.State().SavingInterval)
+
+	code, _, stderr = dvlirctl("", append([]string{"plan", "-selector", "berlin-2"}, files...)...)
+	assert.Equal(t, exitOK, code)
+	assert.Contains(t, stderr, "Plan: 0 changes on 0 of 1 adapters, 0 failed\n")
+
+	write(desired, "devices:\n  berlin-1:\n    dhcp: true\n  berlin-2:\n    ntp_name: ntp.example.org\n")
+	code, stdout, stderr = dvlirctl("", append([]string{"-output", "csv", "plan"}, files...)...)
+	assert.Equal(t, exitOK, code, stderr)
+	assert.Contains(t, stdout, "berlin-1,network.dhcp_server,no,yes,planned\n")
+	assert.Contains(t, stderr, "berlin-1: the desired state enables DHCP, apply needs -scan")
+
+	code, _, stderr = dvlirctl("", append([]string{"-yes", "apply"}, files...)...)
+	assert.Equal(t, exitUsage, code)
+	assert.Contains(t, stderr, "Apply: 1 changes on 1 of 1 adapters, 0 failed\n")
+	assert.Contains(t, stderr, "Nothing was applied to berlin-1")
+	assert.Equal(t, "no", first.State().DHCPServer, "DHCP was enabled")
+	assert.Equal(t, "ntp.example.org", second.State().NTPName)
+
+	//Without a lease the simulated adapter keeps its address, where the scan finds it
+	code, _, stderr = dvlirctl("", append([]string{"-yes", "apply", "-scan", "127.0.0.1/32"}, files...)...)
+	assert.Equal(t, exitOK, code, stderr)
+	assert.Contains(t, stderr, "Apply: 1 changes on 1 of 1 adapters, 0 failed\n")
+	assert.Equal(t, "yes", first.State().DHCPServer, "DHCP wasn't enabled")
+
+	write(desired, "devices:\n  hamburg-1:\n    ntp_name: pool.ntp.org\n")
+	code, _, stderr = dvlirctl("", append([]string{"plan"}, files...)...)
+	assert.Equal(t, exitUsage, code)
+	assert.Contains(t, stderr, "Desired state of unknown devices: hamburg-1")
+
+	code, _, _ = dvlirctl("", "plan")
+	assert.Equal(t, exitUsage, code)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+/*
+print writes a struct or a slice of structs in the selected output format
+*/
+func (a *app) print(v interface{}) error {
+	if a.output == "json" {
+		encoder := json.NewEncoder(a.stdout)
+		encoder.SetIndent("", "  ")
+		return errors.Wrap(encoder.Encode(v), "Error while writing output")
+	}
+
+	header, rows := tabulate(v)
+	if a.output == "csv" {
+		w := csv.NewWriter(a.stdout)
+		_ = w.Write(header)
+		_ = w.WriteAll(rows)
+		return errors.Wrap(w.Error(), "Error while writing output")
+	}
+
+	w := tabwriter.NewWriter(a.stdout, 0, 4, 2, ' ', 0)
+	if len(rows) == 1 {
+		//A single struct is printed as a list of fields
+		for i, name := range header {
+			fmt.Fprintf(w, "%s\t%s\n", name, rows[0][i])
+		}
+	} else {
+		fmt.Fprintln(w, strings.Join(header, "\t"))
+		for _, row := range rows {
+			fmt.Fprintln(w, strings.Join(row, "\t"))
+		}
+	}
+	return errors.Wrap(w.Flush(), "Error while writing output")
+}
+
+/*
+tabulate converts a struct or a slice of structs into a header and rows. Array fields are split into one column per
+element, e.g. MeterReadingsAP[1] to MeterReadingsAP[9].
+*/
+func tabulate(v interface{}) ([]string, [][]string) {
+	value := reflect.ValueOf(v)
+	if value.Kind() != reflect.Slice {
+		return columns(value.Type()), [][]string{row(value)}
+	}
+
+	header := columns(value.Type().Elem())
+	rows := make([][]string, 0, value.Len())
+	for i := 0; i < value.Len(); i++ {
+		rows = append(rows, row(value.Index(i)))
+	}
+	return header, rows
+}
+
+func row(element reflect.Value) []string {
+	var row []string
+	for i := 0; i < element.NumField(); i++ {
+		field := element.Field(i)
+		if field.Kind() == reflect.Array {
+			for j := 0; j < field.Len(); j++ {
+				row = append(row, fmt.Sprint(field.Index(j).Interface()))
+			}
+			continue
+		}
+		row = append(row, fmt.Sprint(field.Interface()))
+	}
+	return row
+}
+
+func columns(t reflect.Type) []string {
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Type.Kind() == reflect.Array {
+			for j := 1; j <= field.Type.Len(); j++ {
+				names = append(names, field.Name+"["+strconv.Itoa(j)+"]")
+			}
+			continue
+		}
+		names = append(names, field.Name)
+	}
+	return names
+}
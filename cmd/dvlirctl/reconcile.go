@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	dvlirclient "github.com/inexio/dvlir-restapi-go-client"
+	"github.com/inexio/dvlir-restapi-go-client/discovery"
+	"github.com/inexio/dvlir-restapi-go-client/fleet"
+	"github.com/pkg/errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+planChange is printed for every change of plan and apply
+*/
+type planChange struct {
+	Device  string `json:"device"`
+	Field   string `json:"field"`
+	Current string `json:"current"`
+	Desired string `json:"desired"`
+	Status  string `json:"status"`
+}
+
+func plan(fs *flag.FlagSet) func(context.Context, *app, []string) error {
+	return reconcile(fs, "plan", false)
+}
+
+func apply(fs *flag.FlagSet) func(context.Context, *app, []string) error {
+	return reconcile(fs, "apply", true)
+}
+
+/*
+reconcile plans the changes of the selected adapters of an inventory and applies them if apply is set
+*/
+func reconcile(fs *flag.FlagSet, name string, apply bool) func(context.Context, *app, []string) error {
+	inventoryPath := fs.String("inventory", "", "inventory file (YAML or JSON)")
+	desiredPath := fs.String("desired", "", "desired state file (YAML or JSON)")
+	selector := fs.String("selector", "", "adapters to reconcile, e.g. site=berlin")
+	concurrency := fs.Int("concurrency", 16, "number of adapters reconciled in parallel")
+	scan := new(string)
+	if apply {
+		fs.StringVar(scan, "scan", "", "CIDR range searched for adapters that switch to DHCP, e.g. 10.0.0.0/24")
+	}
+
+	return func(ctx context.Context, a *app, args []string) error {
+		usage := name + " -inventory <file> -desired <file> [-selector s] [-concurrency n]"
+		if apply {
+			usage += " [-scan cidr]"
+		}
+		if err := argCount(args, 0, usage); err != nil {
+			return err
+		}
+		if *inventoryPath == "" || *desiredPath == "" {
+			return errors.Wrap(errUsage, "usage: dvlirctl "+usage)
+		}
+		inventory, err := fleet.LoadInventory(*inventoryPath)
+		if err != nil {
+			return errors.Wrap(errUsage, err.Error())
+		}
+		states, err := fleet.LoadDesiredStates(*desiredPath)
+		if err != nil {
+			return errors.Wrap(errUsage, err.Error())
+		}
+		if err := states.Validate(inventory); err != nil {
+			return errors.Wrap(errUsage, err.Error())
+		}
+		selected, err := fleet.ParseSelector(*selector)
+		if err != nil {
+			return errors.Wrap(errUsage, err.Error())
+		}
+		//-timeout limits every adapter instead of the whole command
+		adapters, err := fleet.New(inventory, fleet.Options{Concurrency: *concurrency, Timeout: a.timeout})
+		if err != nil {
+			return errors.Wrap(errUsage, err.Error())
+		}
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			_ = adapters.Close(ctx)
+		}()
+
+		report := adapters.Run(ctx, selected, fleet.Reconcile(states, dvlirclient.ReconcileOptions{DryRun: true}))
+		planned, changed, changes := a.collect(report, "planned")
+		fmt.Fprintf(a.stderr, "Plan: %d changes on %d of %d adapters, %d failed\n", changes, len(changed),
+			len(report.Results), len(report.Failed()))
+		if err := a.print(planned); err != nil {
+			return err
+		}
+		if err := report.Err(); err != nil {
+			if apply {
+				return errors.Wrap(err, "Nothing was applied")
+			}
+			return err
+		}
+		//MigrateAddress can't follow an adapter to the address leased by the DHCP server without searching for it
+		var refused []string
+		if *scan == "" {
+			refused = enablesDHCP(report)
+		}
+		for _, device := range refused {
+			fmt.Fprintf(a.stderr, "%s: the desired state enables DHCP, apply needs -scan to find the adapter at its new "+
+				"address\n", device)
+		}
+		if !apply {
+			return nil
+		}
+		changed, changes = without(planned, changed, refused)
+		if changes > 0 {
+			if err := a.confirm("Apply " + strconv.Itoa(changes) + " changes to " + strconv.Itoa(len(changed)) + " adapters?"); err != nil {
+				return err
+			}
+			report = adapters.Run(ctx, fleet.Names(changed...), reconcileOperation(states, *scan))
+			applied, changed, changes := a.collect(report, "applied")
+			fmt.Fprintf(a.stderr, "Apply: %d changes on %d of %d adapters, %d failed\n", changes, len(changed),
+				len(report.Results), len(report.Failed()))
+			if err := a.print(applied); err != nil {
+				return err
+			}
+			if err := report.Err(); err != nil {
+				return err
+			}
+		}
+		if len(refused) > 0 {
+			return errors.Wrap(errUsage, "Nothing was applied to "+strings.Join(refused, ", ")+
+				", the desired state enables DHCP and -scan wasn't given")
+		}
+		return nil
+	}
+}
+
+/*
+reconcileOperation returns the operation which applies the desired states. If scan is set, an adapter which switches to
+DHCP is searched for in the CIDR range with its password and port.
+*/
+func reconcileOperation(states *fleet.DesiredStates, scan string) fleet.Operation {
+	return func(ctx context.Context, device fleet.Device, adapter dvlirclient.Adapter) (interface{}, error) {
+		var opts dvlirclient.ReconcileOptions
+		if scan != "" {
+			opts.Migrate.Locate = discovery.Locator(scan, discovery.Options{
+				Password: device.Credentials(),
+				Port:     addressPort(device.Address),
+			})
+		}
+		return fleet.Reconcile(states, opts)(ctx, device, adapter)
+	}
+}
+
+/*
+addressPort returns the port of an address like "10.0.0.1:8080" or "http://10.0.0.1", 0 if it has none
+*/
+func addressPort(address string) int {
+	if !strings.Contains(address, "://") {
+		address = "http://" + address
+	}
+	u, err := url.Parse(address)
+	if err != nil {
+		return 0
+	}
+	p, _ := strconv.Atoi(u.Port())
+	return p
+}
+
+/*
+collect returns the changes of every adapter with the given status, the names of the changed adapters and the number
+of changes. Errors and moved adapters are reported on stderr.
+*/
+func (a *app) collect(report *fleet.Report, status string) ([]planChange, []string, int) {
+	var changes []planChange
+	var changed []string
+	for _, result := range report.Results {
+		name := result.Device.Name
+		reconciled, _ := result.Value.(*dvlirclient.ReconcileReport)
+		if result.Err != nil {
+			fmt.Fprintf(a.stderr, "%s: error: %v\n", name, result.Err)
+		}
+		if reconciled == nil {
+			continue
+		}
+
+		state := status
+		if result.Err != nil {
+			state = "failed"
+		}
+		for _, change := range reconciled.Plan {
+			changes = append(changes, planChange{name, change.Field, change.Current, change.Desired, state})
+		}
+		if reconciled.Migration != nil && result.Err == nil {
+			fmt.Fprintf(a.stderr, "%s: adapter moved to %s, the inventory has to be updated\n", name,
+				reconciled.Migration.NewAddress)
+		}
+		if len(reconciled.Plan) > 0 && result.Err == nil {
+			changed = append(changed, name)
+		}
+	}
+	count := 0
+	for _, change := range changes {
+		if change.Status == status {
+			count++
+		}
+	}
+	return changes, changed, count
+}
+
+/*
+without returns the changed adapters and the number of their planned changes without the excluded adapters
+*/
+func without(planned []planChange, changed, excluded []string) ([]string, int) {
+	skip := make(map[string]bool)
+	for _, name := range excluded {
+		skip[name] = true
+	}
+	var names []string
+	for _, name := range changed {
+		if !skip[name] {
+			names = append(names, name)
+		}
+	}
+	count := 0
+	for _, change := range planned {
+		if change.Status == "planned" && !skip[change.Device] {
+			count++
+		}
+	}
+	return names, count
+}
+
+/*
+enablesDHCP returns the names of the adapters whose plan switches DHCP on
+*/
+func enablesDHCP(report *fleet.Report) []string {
+	var names []string
+	for _, result := range report.Results {
+		reconciled, _ := result.Value.(*dvlirclient.ReconcileReport)
+		if reconciled == nil {
+			continue
+		}
+		for _, change := range reconciled.Plan {
+			if change.Field == dvlirclient.SettingDHCP && strings.EqualFold(change.Desired, "yes") {
+				names = append(names, result.Device.Name)
+			}
+		}
+	}
+	return names
+}
@@ -0,0 +1,134 @@
+package dvlirclient
+
+import (
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Prefix of the environment variables read by LoadConfig, e.g. DVLIR_API_IPADDRESS
+const configEnvPrefix = "DVLIR_API"
+
+/*
+DeviceConfig contains the settings of a single adapter
+*/
+type DeviceConfig struct {
+	//Name identifies the adapter in a config file with several devices
+	Name string `mapstructure:"name" json:"name" yaml:"name"`
+	//IPAddress is the address of the adapter as expected by NewDvLIRClient
+	IPAddress string `mapstructure:"ipaddress" json:"IPAddress" yaml:"IPAddress"`
+	//Password is the password of the adapter
+	Password string `mapstructure:"password" json:"Password" yaml:"Password"`
+	//BaseURL is passed to WithBaseURL if set
+	BaseURL string `mapstructure:"baseurl" json:"BaseURL,omitempty" yaml:"BaseURL,omitempty"`
+	//Timeout is passed to WithTimeout if set, e.g. "10s"
+	Timeout time.Duration `mapstructure:"timeout" json:"Timeout,omitempty" yaml:"Timeout,omitempty"`
+}
+
+/*
+Config is the configuration of one or more adapters. A single adapter is configured at the top level, further adapters
+are listed in Devices:
+
+	IPAddress: 192.168.0.100
+	Password: secret
+	Devices:
+	  - Name: berlin-1
+	    IPAddress: 192.168.1.100
+	    Password: secret
+*/
+type Config struct {
+	DeviceConfig `mapstructure:",squash"`
+	//Firmware is the name of a firmware file, it is used by the tests against a real adapter
+	Firmware string `mapstructure:"firmware" json:"Firmware,omitempty" yaml:"Firmware,omitempty"`
+	//Devices are further adapters
+	Devices []DeviceConfig `mapstructure:"devices" json:"Devices,omitempty" yaml:"Devices,omitempty"`
+}
+
+/*
+LoadConfig reads a config file in YAML or JSON format, the format is chosen by the file extension. If path is empty
+only the environment is read.
+
+The settings of the top level adapter can be overridden with the environment variables DVLIR_API_IPADDRESS,
+DVLIR_API_PASSWORD, DVLIR_API_BASEURL, DVLIR_API_TIMEOUT and DVLIR_API_FIRMWARE. LoadConfig doesn't change the global
+viper instance.
+*/
+func LoadConfig(path string) (*Config, error) {
+	v := viper.New()
+	v.SetEnvPrefix(configEnvPrefix)
+	for _, key := range []string{"ipaddress", "password", "baseurl", "timeout", "firmware"} {
+		if err := v.BindEnv(key); err != nil {
+			return nil, errors.Wrap(err, "Error while binding environment variable")
+		}
+	}
+
+	if path != "" {
+		v.SetConfigFile(path)
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".json":
+			v.SetConfigType("json")
+		default:
+			v.SetConfigType("yaml")
+		}
+		if err := v.ReadInConfig(); err != nil {
+			return nil, errors.Wrap(err, "Error while reading config file")
+		}
+	}
+
+	var config Config
+	if err := v.Unmarshal(&config); err != nil {
+		return nil, errors.Wrap(err, "Error while parsing config")
+	}
+	return &config, nil
+}
+
+/*
+Device returns the settings of the adapter with the given name. An empty name returns the top level adapter, or the
+only listed adapter if no adapter is configured at the top level.
+*/
+func (c *Config) Device(name string) (DeviceConfig, error) {
+	if name == "" {
+		if c.IPAddress != "" {
+			return c.DeviceConfig, nil
+		}
+		if len(c.Devices) == 1 {
+			return c.Devices[0], nil
+		}
+		return DeviceConfig{}, errors.New("No device name given")
+	}
+	if c.Name == name {
+		return c.DeviceConfig, nil
+	}
+	for _, device := range c.Devices {
+		if device.Name == name {
+			return device, nil
+		}
+	}
+	return DeviceConfig{}, errors.New("Device " + name + " isn't configured")
+}
+
+/*
+NewClient creates a client for the adapter with the given name, see Device
+*/
+func (c *Config) NewClient(name string, opts ...Option) (*DvLIRClient, error) {
+	device, err := c.Device(name)
+	if err != nil {
+		return nil, err
+	}
+	return device.NewClient(opts...)
+}
+
+/*
+NewClient creates a client for the adapter. The options are applied after the options of the config.
+*/
+func (d DeviceConfig) NewClient(opts ...Option) (*DvLIRClient, error) {
+	var configured []Option
+	if d.BaseURL != "" {
+		configured = append(configured, WithBaseURL(d.BaseURL))
+	}
+	if d.Timeout > 0 {
+		configured = append(configured, WithTimeout(d.Timeout))
+	}
+	return NewDvLIRClient(d.IPAddress, d.Password, append(configured, opts...)...)
+}
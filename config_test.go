@@ -0,0 +1,89 @@
+package dvlirclient
+
+import (
+	"github.com/inexio/dvlir-restapi-go-client/dvlirtest"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+/*
+TestLoadConfig covers:
+	- LoadConfig with a YAML file with several devices
+	- LoadConfig with a JSON file
+	- LoadConfig with environment variables
+	- Config.Device with an unknown device
+	- Config.NewClient
+	- Untouched global viper instance
+*/
+func TestLoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dvlir-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	server := dvlirtest.NewServer()
+	defer server.Close()
+
+	yamlPath := filepath.Join(dir, "dvlir-api.yaml")
+	err = ioutil.WriteFile(yamlPath, []byte(`
+IPAddress: 192.168.0.100
+Password: secret
+Timeout: 10s
+Devices:
+  - Name: simulator
+    IPAddress: `+server.Address()+`
+    Password: `+server.Password()+`
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := LoadConfig(yamlPath)
+	if !assert.NoError(t, err, "Error while loading YAML config") {
+		return
+	}
+	assert.Equal(t, "192.168.0.100", config.IPAddress)
+	assert.Equal(t, 10*time.Second, config.Timeout)
+	if assert.Len(t, config.Devices, 1) {
+		assert.Equal(t, server.Address(), config.Devices[0].IPAddress)
+	}
+	_, err = config.Device("unknown")
+	assert.EqualError(t, err, "Device unknown isn't configured")
+
+	client, err := config.NewClient("simulator")
+	if assert.NoError(t, err, "Error while creating client from config") {
+		assert.NoError(t, client.Login(), "Error during Login")
+	}
+
+	jsonPath := filepath.Join(dir, "dvlir-api.json")
+	err = ioutil.WriteFile(jsonPath, []byte(`{"Devices": [{"Name": "a", "IPAddress": "192.168.0.101", "Password": "pw"}]}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err = LoadConfig(jsonPath)
+	if assert.NoError(t, err, "Error while loading JSON config") {
+		device, err := config.Device("")
+		if assert.NoError(t, err, "Only device wasn't returned") {
+			assert.Equal(t, "192.168.0.101", device.IPAddress)
+		}
+	}
+
+	os.Setenv("DVLIR_API_PASSWORD", "from-env")
+	defer os.Unsetenv("DVLIR_API_PASSWORD")
+	config, err = LoadConfig(yamlPath)
+	if assert.NoError(t, err, "Error while loading config with environment") {
+		assert.Equal(t, "from-env", config.Password)
+	}
+	config, err = LoadConfig("")
+	if assert.NoError(t, err, "Error while loading environment") {
+		assert.Equal(t, "from-env", config.Password)
+	}
+
+	assert.Empty(t, viper.ConfigFileUsed(), "Global viper instance was changed")
+	assert.Empty(t, viper.GetString("Password"), "Global viper instance was changed")
+}
@@ -0,0 +1,42 @@
+package dvlirclient
+
+import (
+	"context"
+	"github.com/inexio/dvlir-restapi-go-client/dvlirtest"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+/*
+TestDvLIRClient_Context covers:
+	- GetMomentaryValuesContext with a deadline
+	- ChangePasswordContext with a canceled context
+	- UploadFirmwareContext with a canceled context
+	- LoginContext
+*/
+func TestDvLIRClient_Context(t *testing.T) {
+	dvlirClient, server := newSimulatedClient(t)
+	defer server.Close()
+
+	server.Inject(dvlirtest.Rule{Endpoint: "/data.txt", Fault: dvlirtest.Latency(time.Second)})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := dvlirClient.GetMomentaryValuesContext(ctx)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "Deadline wasn't reported: %v", err)
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = dvlirClient.ChangePasswordContext(canceled, server.Password(), "secret", "secret")
+	assert.True(t, errors.Is(err, context.Canceled), "Cancellation wasn't reported: %v", err)
+	assert.Equal(t, "dvlir", server.Password(), "Password was changed")
+
+	_, err = dvlirClient.UploadFirmwareContext(canceled, "LICENSE")
+	assert.True(t, errors.Is(err, context.Canceled), "Cancellation wasn't reported: %v", err)
+
+	err = dvlirClient.LoginContext(context.Background())
+	assert.NoError(t, err, "Error during Login")
+}
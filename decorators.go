@@ -0,0 +1,331 @@
+package dvlirclient
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"io"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+)
+
+/*
+NewLoggingAdapter returns an Adapter that logs every operation of next with its duration and error. If logger is nil,
+the standard logger is used.
+*/
+func NewLoggingAdapter(next Adapter, logger *log.Logger) Adapter {
+	printf := log.Printf
+	if logger != nil {
+		printf = logger.Printf
+	}
+	return Wrap(next, func(ctx context.Context, operation string, call func(ctx context.Context) error) error {
+		start := time.Now()
+		err := call(ctx)
+		if err != nil {
+			printf("dvlir: %s failed after %v: %v", operation, time.Since(start), err)
+		} else {
+			printf("dvlir: %s took %v", operation, time.Since(start))
+		}
+		return err
+	})
+}
+
+/*
+MetricsRecorder records the outcome of the operations of an Adapter
+*/
+type MetricsRecorder interface {
+	ObserveOperation(operation string, duration time.Duration, err error)
+}
+
+/*
+NewMetricsAdapter returns an Adapter that reports the duration and error of every operation of next to the recorder
+*/
+func NewMetricsAdapter(next Adapter, recorder MetricsRecorder) Adapter {
+	return Wrap(next, func(ctx context.Context, operation string, call func(ctx context.Context) error) error {
+		start := time.Now()
+		err := call(ctx)
+		recorder.ObserveOperation(operation, time.Since(start), err)
+		return err
+	})
+}
+
+/*
+OperationStats contains the metrics of a single operation
+*/
+type OperationStats struct {
+	Calls    int
+	Errors   int
+	Duration time.Duration
+}
+
+/*
+OperationMetrics is a MetricsRecorder that keeps the metrics of every operation in memory
+*/
+type OperationMetrics struct {
+	mu    sync.Mutex
+	stats map[string]OperationStats
+}
+
+/*
+ObserveOperation records a single call of the operation
+*/
+func (o *OperationMetrics) ObserveOperation(operation string, duration time.Duration, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.stats == nil {
+		o.stats = make(map[string]OperationStats)
+	}
+	stats := o.stats[operation]
+	stats.Calls++
+	if err != nil {
+		stats.Errors++
+	}
+	stats.Duration += duration
+	o.stats[operation] = stats
+}
+
+/*
+Stats returns the metrics of the operation, e.g. "GetMomentaryValues"
+*/
+func (o *OperationMetrics) Stats(operation string) OperationStats {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.stats[operation]
+}
+
+/*
+Snapshot returns the metrics of all operations that have been called
+*/
+func (o *OperationMetrics) Snapshot() map[string]OperationStats {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	snapshot := make(map[string]OperationStats, len(o.stats))
+	for operation, stats := range o.stats {
+		snapshot[operation] = stats
+	}
+	return snapshot
+}
+
+/*
+NewRateLimitedAdapter returns an Adapter that starts the operations of next at most once per interval. Operations wait
+for their turn until ctx is done.
+*/
+func NewRateLimitedAdapter(next Adapter, interval time.Duration) Adapter {
+	var mu sync.Mutex
+	var slot time.Time
+	return Wrap(next, func(ctx context.Context, operation string, call func(ctx context.Context) error) error {
+		mu.Lock()
+		start := time.Now()
+		if slot.After(start) {
+			start = slot
+		}
+		slot = start.Add(interval)
+		mu.Unlock()
+
+		if wait := time.Until(start); wait > 0 {
+			timer := time.NewTimer(wait)
+			defer timer.Stop()
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				return errors.Wrap(ctx.Err(), "Error while waiting for "+operation)
+			}
+		}
+		return call(ctx)
+	})
+}
+
+/*
+NewCachingAdapter returns an Adapter that caches the responses of the read operations of next for the given duration.
+Operations that change the adapter, e.g. ChangeNetworkSettings or Restart, clear the cache. Errors are not cached.
+*/
+func NewCachingAdapter(next Adapter, ttl time.Duration) Adapter {
+	return &cachingAdapter{Adapter: next, ttl: ttl, entries: make(map[string]cacheEntry)}
+}
+
+type cacheEntry struct {
+	value   interface{}
+	expires time.Time
+}
+
+type cachingAdapter struct {
+	Adapter
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+	//generation is incremented by invalidate, a fetch that started before is not stored
+	generation uint64
+}
+
+/*
+cached returns the cached value for the key or stores the value returned by fetch
+*/
+func (c *cachingAdapter) cached(key string, fetch func() (interface{}, error)) (interface{}, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	generation := c.generation
+	c.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.value, nil
+	}
+
+	value, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	//The value may be outdated if the cache was invalidated during the fetch
+	if c.generation == generation {
+		c.entries[key] = cacheEntry{value: value, expires: time.Now().Add(c.ttl)}
+	}
+	c.mu.Unlock()
+	return value, nil
+}
+
+/*
+invalidate clears the cache
+*/
+func (c *cachingAdapter) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]cacheEntry)
+	c.generation++
+}
+
+func (c *cachingAdapter) GetDataFileContext(ctx context.Context, lines int) (DataLines, error) {
+	value, err := c.cached("GetDataFile/"+strconv.Itoa(lines), func() (interface{}, error) {
+		return c.Adapter.GetDataFileContext(ctx, lines)
+	})
+	if err != nil {
+		return nil, err
+	}
+	//Copy the lines, so the caller can't modify the cache
+	return append(DataLines(nil), value.(DataLines)...), nil
+}
+
+func (c *cachingAdapter) GetMomentaryValuesContext(ctx context.Context) (MomentaryValues, error) {
+	value, err := c.cached("GetMomentaryValues", func() (interface{}, error) {
+		return c.Adapter.GetMomentaryValuesContext(ctx)
+	})
+	if err != nil {
+		return MomentaryValues{}, err
+	}
+	return value.(MomentaryValues), nil
+}
+
+func (c *cachingAdapter) GetGeneralInformationContext(ctx context.Context) (GeneralInfo, error) {
+	value, err := c.cached("GetGeneralInformation", func() (interface{}, error) {
+		return c.Adapter.GetGeneralInformationContext(ctx)
+	})
+	if err != nil {
+		return GeneralInfo{}, err
+	}
+	return value.(GeneralInfo), nil
+}
+
+func (c *cachingAdapter) GetNetworkInformationContext(ctx context.Context) (NetworkInfo, error) {
+	value, err := c.cached("GetNetworkInformation", func() (interface{}, error) {
+		return c.Adapter.GetNetworkInformationContext(ctx)
+	})
+	if err != nil {
+		return NetworkInfo{}, err
+	}
+	return value.(NetworkInfo), nil
+}
+
+func (c *cachingAdapter) GetSystemInformationContext(ctx context.Context) (SystemInfo, error) {
+	value, err := c.cached("GetSystemInformation", func() (interface{}, error) {
+		return c.Adapter.GetSystemInformationContext(ctx)
+	})
+	if err != nil {
+		return SystemInfo{}, err
+	}
+	return value.(SystemInfo), nil
+}
+
+func (c *cachingAdapter) LogoutContext(ctx context.Context) error {
+	defer c.invalidate()
+	return c.Adapter.LogoutContext(ctx)
+}
+
+func (c *cachingAdapter) ChangeNetworkSettingsContext(ctx context.Context, dhcp, ip, sub, gw, dns, ntpName, ntpServer, setDt string) (string, error) {
+	defer c.invalidate()
+	return c.Adapter.ChangeNetworkSettingsContext(ctx, dhcp, ip, sub, gw, dns, ntpName, ntpServer, setDt)
+}
+
+func (c *cachingAdapter) ChangeSavingIntervalContext(ctx context.Context, interval SavingInterval) (string, error) {
+	defer c.invalidate()
+	return c.Adapter.ChangeSavingIntervalContext(ctx, interval)
+}
+
+func (c *cachingAdapter) AllowResetWithPwdContext(ctx context.Context, allow string) (string, error) {
+	defer c.invalidate()
+	return c.Adapter.AllowResetWithPwdContext(ctx, allow)
+}
+
+func (c *cachingAdapter) ResetAllContext(ctx context.Context, rCode string) (string, error) {
+	defer c.invalidate()
+	return c.Adapter.ResetAllContext(ctx, rCode)
+}
+
+func (c *cachingAdapter) DeleteDataContext(ctx context.Context, code string) (string, error) {
+	defer c.invalidate()
+	return c.Adapter.DeleteDataContext(ctx, code)
+}
+
+func (c *cachingAdapter) ChangePasswordContext(ctx context.Context, pw1, pw2, pw3 string) (string, error) {
+	defer c.invalidate()
+	return c.Adapter.ChangePasswordContext(ctx, pw1, pw2, pw3)
+}
+
+func (c *cachingAdapter) UploadFirmwareContext(ctx context.Context, filePath string) (string, error) {
+	defer c.invalidate()
+	return c.Adapter.UploadFirmwareContext(ctx, filePath)
+}
+
+func (c *cachingAdapter) UploadFirmwareFrom(ctx context.Context, name string, r io.Reader, size int64, opts ...FirmwareOption) (*FirmwareUpgradeReport, error) {
+	defer c.invalidate()
+	return c.Adapter.UploadFirmwareFrom(ctx, name, r, size, opts...)
+}
+
+func (c *cachingAdapter) RestartContext(ctx context.Context) (string, error) {
+	defer c.invalidate()
+	return c.Adapter.RestartContext(ctx)
+}
+
+func (c *cachingAdapter) RestartAndWait(ctx context.Context, opts ReadyOptions) (*ReadyReport, error) {
+	defer c.invalidate()
+	return c.Adapter.RestartAndWait(ctx, opts)
+}
+
+func (c *cachingAdapter) ResetAllAndWait(ctx context.Context, rCode string, opts ReadyOptions) (*ReadyReport, error) {
+	defer c.invalidate()
+	return c.Adapter.ResetAllAndWait(ctx, rCode, opts)
+}
+
+func (c *cachingAdapter) Reconcile(ctx context.Context, desired DesiredState, opts ReconcileOptions) (*ReconcileReport, error) {
+	defer c.invalidate()
+	return c.Adapter.Reconcile(ctx, desired, opts)
+}
+
+func (c *cachingAdapter) ApplyNetworkSettings(ctx context.Context, s NetworkSettings) (string, error) {
+	defer c.invalidate()
+	return c.Adapter.ApplyNetworkSettings(ctx, s)
+}
+
+func (c *cachingAdapter) MigrateAddress(ctx context.Context, settings NetworkSettings, opts MigrateOptions) (*MigrationReport, error) {
+	defer c.invalidate()
+	return c.Adapter.MigrateAddress(ctx, settings, opts)
+}
+
+func (c *cachingAdapter) WaitReady(ctx context.Context, opts ReadyOptions) (*ReadyReport, error) {
+	defer c.invalidate()
+	return c.Adapter.WaitReady(ctx, opts)
+}
+
+func (c *cachingAdapter) Restore(ctx context.Context, snapshot *Snapshot, opts RestoreOptions) (*RestoreReport, error) {
+	defer c.invalidate()
+	return c.Adapter.Restore(ctx, snapshot, opts)
+}
@@ -0,0 +1,260 @@
+/*
+Package discovery finds DvLIR adapters in a network, e.g. after an adapter switched to DHCP and its new address is
+unknown.
+
+Every address of a CIDR range is probed concurrently without a login. Endpoints that answer /getSID.txt with the login
+page of a DvLIR adapter are reported as candidates. The adapter accepts only one session, so a login ends the session
+of any other client. Candidates are therefore only identified by logging in and reading /info.txt on request, Find
+identifies them one after another and stops at the adapter with the MAC address.
+*/
+package discovery
+
+import (
+	"context"
+	dvlirclient "github.com/inexio/dvlir-restapi-go-client"
+	"github.com/pkg/errors"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxHosts limits the size of a scanned range
+const maxHosts = 65536
+
+/*
+ErrNotFound is returned by Find if no adapter with the MAC address was found
+*/
+var ErrNotFound = errors.New("No adapter with the MAC address was found")
+
+/*
+Options configures a scan
+*/
+type Options struct {
+	//Password is used by Identify and Find to log in to the candidates, Scan doesn't log in
+	Password string
+	//Port is the http port of the adapters, the default is 80
+	Port int
+	//Concurrency limits the number of addresses probed in parallel, the default is 32
+	Concurrency int
+	//Timeout limits the probing of a single address, the default is 2 seconds
+	Timeout time.Duration
+	//ClientOptions configure the requests of the probes and the client used to identify a candidate, e.g. its
+	//transport, TLS configuration or proxy
+	ClientOptions []dvlirclient.Option
+}
+
+/*
+Candidate is an endpoint that answered like a DvLIR adapter
+*/
+type Candidate struct {
+	//Address is the host:port of the adapter as expected by NewDvLIRClient
+	Address string
+	//Identified is true if the candidate was identified by Identify and the following fields are set
+	Identified bool
+	//Err is the reason why the candidate couldn't be identified
+	Err error
+
+	MACAddress      string
+	DeviceSn        string
+	MeterNumber     string
+	FirmwareVersion string
+}
+
+/*
+Scan probes every address of the CIDR range, e.g. "192.168.0.0/24", and returns the candidates sorted by address.
+Network and broadcast addresses are skipped. The candidates aren't identified, so the sessions of the adapters are kept.
+*/
+func Scan(ctx context.Context, cidr string, opts Options) ([]Candidate, error) {
+	return scan(ctx, cidr, opts)
+}
+
+/*
+Identify logs in to the candidate, reads its general information and logs out again. The login ends the session of any
+other client of the adapter. Candidate.Err is set if the candidate couldn't be identified.
+*/
+func Identify(ctx context.Context, candidate *Candidate, opts Options) {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 2 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	client, err := dvlirclient.NewDvLIRClient(candidate.Address, opts.Password, opts.ClientOptions...)
+	if err == nil {
+		err = client.LoginContext(ctx)
+	}
+	if err != nil {
+		candidate.Err = err
+		return
+	}
+	defer func() { _ = client.LogoutContext(ctx) }()
+
+	info, err := client.GetGeneralInformationContext(ctx)
+	if err != nil {
+		candidate.Err = err
+		return
+	}
+	candidate.Identified = true
+	candidate.Err = nil
+	candidate.MACAddress = info.MACAddress
+	candidate.DeviceSn = info.DeviceSn
+	candidate.MeterNumber = info.MeterNumber
+	candidate.FirmwareVersion = info.FirmwareVersion
+}
+
+/*
+Find scans the CIDR range for the adapter with the given MAC address. The candidates are identified one after another
+and the search stops as soon as the adapter was found, so only the adapters checked before it are logged in to. If no
+adapter matches, an error wrapping ErrNotFound is returned. A password has to be set to identify the adapters.
+*/
+func Find(ctx context.Context, cidr, mac string, opts Options) (*Candidate, error) {
+	if opts.Password == "" {
+		return nil, errors.New("A password is required to identify the adapters")
+	}
+	candidates, err := Scan(ctx, cidr, opts)
+	if err != nil {
+		return nil, err
+	}
+	for i := range candidates {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		Identify(ctx, &candidates[i], opts)
+		if candidates[i].Identified && dvlirclient.NormalizeMAC(candidates[i].MACAddress) == dvlirclient.NormalizeMAC(mac) {
+			return &candidates[i], nil
+		}
+	}
+	return nil, errors.Wrap(ErrNotFound, "Adapter "+mac+" wasn't found in "+cidr)
+}
+
+/*
+Locator returns a function that finds the adapter with a MAC address in the CIDR range, which can be used as
+MigrateOptions.Locate
+*/
+func Locator(cidr string, opts Options) func(ctx context.Context, mac string) (string, error) {
+	return func(ctx context.Context, mac string) (string, error) {
+		candidate, err := Find(ctx, cidr, mac, opts)
+		if err != nil {
+			return "", err
+		}
+		return candidate.Address, nil
+	}
+}
+
+func scan(ctx context.Context, cidr string, opts Options) ([]Candidate, error) {
+	if opts.Port <= 0 {
+		opts.Port = 80
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 32
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 2 * time.Second
+	}
+
+	hosts, err := Hosts(cidr)
+	if err != nil {
+		return nil, err
+	}
+
+	var mu sync.Mutex
+	var candidates []Candidate
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, opts.Concurrency)
+
+	for _, host := range hosts {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(address string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			if !probe(ctx, address, opts) {
+				return
+			}
+			mu.Lock()
+			candidates = append(candidates, Candidate{Address: address})
+			mu.Unlock()
+		}(net.JoinHostPort(host.String(), strconv.Itoa(opts.Port)))
+	}
+	wg.Wait()
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return compareAddresses(candidates[i].Address, candidates[j].Address) < 0
+	})
+	return candidates, ctx.Err()
+}
+
+/*
+probe checks without a login whether a DvLIR adapter answers at the address
+*/
+func probe(ctx context.Context, address string, opts Options) bool {
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+	return dvlirclient.Probe(ctx, address, opts.ClientOptions...) == nil
+}
+
+/*
+Hosts returns the IPv4 host addresses of the CIDR range. Network and broadcast addresses are skipped for ranges with
+more than two addresses.
+*/
+func Hosts(cidr string) ([]net.IP, error) {
+	ip, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid range")
+	}
+	if ip.To4() == nil {
+		return nil, errors.New("invalid range: only IPv4 ranges can be scanned")
+	}
+	ones, bits := network.Mask.Size()
+	if bits-ones > 16 {
+		return nil, errors.New("invalid range: more than " + strconv.Itoa(maxHosts) + " addresses")
+	}
+
+	size := 1 << uint(bits-ones)
+	start := ipToInt(network.IP.To4())
+	hosts := make([]net.IP, 0, size)
+	for i := 0; i < size; i++ {
+		if size > 2 && (i == 0 || i == size-1) {
+			continue
+		}
+		hosts = append(hosts, intToIP(start+uint32(i)))
+	}
+	return hosts, nil
+}
+
+func ipToInt(ip net.IP) uint32 {
+	return uint32(ip[0])<<24 | uint32(ip[1])<<16 | uint32(ip[2])<<8 | uint32(ip[3])
+}
+
+func intToIP(n uint32) net.IP {
+	return net.IPv4(byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+}
+
+/*
+compareAddresses compares two host:port addresses by their IP address
+*/
+func compareAddresses(a, b string) int {
+	hostA, _, _ := net.SplitHostPort(a)
+	hostB, _, _ := net.SplitHostPort(b)
+	ipA, ipB := net.ParseIP(hostA).To4(), net.ParseIP(hostB).To4()
+	if ipA == nil || ipB == nil {
+		return strings.Compare(a, b)
+	}
+	switch x, y := ipToInt(ipA), ipToInt(ipB); {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	default:
+		return 0
+	}
+}
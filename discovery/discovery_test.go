@@ -0,0 +1,196 @@
+package discovery
+
+import (
+	"context"
+	dvlirclient "github.com/inexio/dvlir-restapi-go-client"
+	"github.com/inexio/dvlir-restapi-go-client/dvlirtest"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+/*
+startAdapters starts simulated adapters on 127.0.0.2 to 127.0.0.4 and a different http server on 127.0.0.5, all on
+the same port
+*/
+func startAdapters(t *testing.T) ([]*dvlirtest.Server, int, func()) {
+	listener, err := net.Listen("tcp", "127.0.0.2:0")
+	if err != nil {
+		t.Skip("127.0.0.2 isn't available: ", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	var servers []*dvlirtest.Server
+	for i := 2; i <= 4; i++ {
+		state := dvlirtest.DefaultState()
+		state.MACAddress = "00:50:C2:9F:10:0" + strconv.Itoa(i)
+		state.DeviceSn = "2019000" + strconv.Itoa(i)
+		servers = append(servers, dvlirtest.NewServer(
+			dvlirtest.WithState(state),
+			dvlirtest.WithAddress("127.0.0."+strconv.Itoa(i)+":"+strconv.Itoa(port)),
+		))
+	}
+
+	other := httptest.NewUnstartedServer(http.NotFoundHandler())
+	other.Listener.Close()
+	other.Listener, err = net.Listen("tcp", "127.0.0.5:"+strconv.Itoa(port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other.Start()
+
+	return servers, port, func() {
+		for _, server := range servers {
+			server.Close()
+		}
+		other.Close()
+	}
+}
+
+/*
+countingTransport counts the requests sent through it
+*/
+type countingTransport struct {
+	mu       sync.Mutex
+	requests int
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.mu.Lock()
+	c.requests++
+	c.mu.Unlock()
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+/*
+TestScan covers:
+	- Hosts
+	- Scan through the transport of the client options
+	- Scan keeps the sessions of the adapters
+	- Identify
+	- Find with a known and an unknown MAC address
+*/
+func TestScan(t *testing.T) {
+	hosts, err := Hosts("192.168.0.0/30")
+	if assert.NoError(t, err) {
+		assert.Equal(t, []net.IP{net.ParseIP("192.168.0.1"), net.ParseIP("192.168.0.2")}, hosts)
+	}
+	_, err = Hosts("10.0.0.0/8")
+	assert.Error(t, err, "Too large range wasn't rejected")
+
+	servers, port, done := startAdapters(t)
+	defer done()
+
+	//Sessions of other clients have to survive the scan
+	var sessions []*dvlirclient.DvLIRClient
+	for _, server := range servers {
+		dvlirClient, err := dvlirclient.NewDvLIRClient(server.Address(), server.Password())
+		if err == nil {
+			err = dvlirClient.Login()
+		}
+		if !assert.NoError(t, err, "Error during Login") {
+			return
+		}
+		sessions = append(sessions, dvlirClient)
+	}
+
+	ctx := context.Background()
+	transport := &countingTransport{}
+	opts := Options{
+		Password:      servers[0].Password(),
+		Port:          port,
+		Concurrency:   2,
+		Timeout:       time.Second,
+		ClientOptions: []dvlirclient.Option{dvlirclient.WithTransport(transport)},
+	}
+	candidates, err := Scan(ctx, "127.0.0.0/29", opts)
+	if !assert.NoError(t, err, "Error during Scan") {
+		return
+	}
+	assert.Equal(t, 6, transport.requests, "Probes weren't sent through the transport of the client options")
+	if assert.Len(t, candidates, 3) {
+		for i, candidate := range candidates {
+			assert.Equal(t, servers[i].Address(), candidate.Address)
+			assert.False(t, candidate.Identified, "Scan identified a candidate")
+		}
+	}
+	for i, dvlirClient := range sessions {
+		_, err := dvlirClient.GetGeneralInformation()
+		assert.NoError(t, err, "Scan ended the session of adapter %d", i)
+	}
+
+	if assert.Len(t, candidates, 3) {
+		candidate := candidates[0]
+		Identify(ctx, &candidate, opts)
+		assert.True(t, candidate.Identified, "Candidate wasn't identified: %v", candidate.Err)
+		assert.Equal(t, servers[0].State().MACAddress, candidate.MACAddress)
+		assert.Equal(t, servers[0].State().DeviceSn, candidate.DeviceSn)
+		assert.Equal(t, "01234567", candidate.MeterNumber)
+		assert.Equal(t, "1.21", candidate.FirmwareVersion)
+
+		candidate = candidates[1]
+		Identify(ctx, &candidate, Options{Password: "wrong"})
+		assert.False(t, candidate.Identified)
+		assert.True(t, errors.Is(candidate.Err, dvlirclient.ErrWrongPassword), "ErrWrongPassword wasn't set: %v",
+			candidate.Err)
+	}
+
+	candidate, err := Find(ctx, "127.0.0.0/29", "00-50-c2-9f-10-03", opts)
+	if assert.NoError(t, err, "Error during Find") {
+		assert.Equal(t, servers[1].Address(), candidate.Address)
+	}
+	_, err = sessions[2].GetGeneralInformation()
+	assert.NoError(t, err, "Find logged in to an adapter after the match")
+
+	_, err = Find(ctx, "127.0.0.0/29", "00:50:C2:9F:10:FF", opts)
+	assert.True(t, errors.Is(err, ErrNotFound), "ErrNotFound wasn't returned: %v", err)
+}
+
+/*
+TestLocator covers:
+	- MigrateAddress after switching to DHCP with Locator
+*/
+func TestLocator(t *testing.T) {
+	servers, port, done := startAdapters(t)
+	defer done()
+
+	server := servers[0]
+	server.Close()
+	server = dvlirtest.NewServer(
+		dvlirtest.WithAddress("127.0.0.2:"+strconv.Itoa(port)),
+		dvlirtest.WithDHCPLease("127.0.0.6"),
+	)
+	defer server.Close()
+
+	dvlirClient, err := dvlirclient.NewDvLIRClient(server.Address(), server.Password())
+	if err == nil {
+		err = dvlirClient.Login()
+	}
+	if !assert.NoError(t, err, "Error during Login") {
+		return
+	}
+
+	settings := dvlirclient.NetworkSettings{}
+	settings.SetDHCP(true)
+	report, err := dvlirClient.MigrateAddress(context.Background(), settings, dvlirclient.MigrateOptions{
+		Timeout:      5 * time.Second,
+		PollInterval: 20 * time.Millisecond,
+		Locate:       Locator("127.0.0.0/29", Options{Password: server.Password(), Port: port}),
+	})
+	if !assert.NoError(t, err, "Error during MigrateAddress") {
+		return
+	}
+	assert.Equal(t, "http://127.0.0.6:"+strconv.Itoa(port), report.NewAddress)
+
+	info, err := dvlirClient.GetGeneralInformation()
+	if assert.NoError(t, err, "Client didn't follow the adapter") {
+		assert.Equal(t, "127.0.0.6", info.IPAddress)
+	}
+}
@@ -2,19 +2,80 @@ package dvlirclient
 
 import (
 	"fmt"
-	"github.com/spf13/viper"
+	"github.com/inexio/dvlir-restapi-go-client/dvlirtest"
 	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
+/*
+testConfig returns the adapter configured in config/dvlir-api.yaml or the DVLIR_API_* environment variables
+*/
+func testConfig() *Config {
+	config, err := LoadConfig("config/dvlir-api.yaml")
+	if err != nil {
+		return &Config{}
+	}
+	return config
+}
+
+/*
+deviceConfigured returns true if a real adapter was configured
+*/
+func deviceConfigured() bool {
+	ip := testConfig().IPAddress
+	return ip != "" && !strings.HasPrefix(ip, "<")
+}
+
+/*
+testDevice returns the address and password of the adapter the tests run against.
+
+If no adapter is configured a simulated adapter is started, which is shut down again by the returned function.
+*/
+func testDevice(t *testing.T) (string, string, func()) {
+	if deviceConfigured() {
+		config := testConfig()
+		return config.IPAddress, config.Password, func() {}
+	}
+
+	server := dvlirtest.NewServer()
+	return server.Address(), server.Password(), server.Close
+}
+
+/*
+testFirmware returns the path of the firmware file to upload.
+
+If no adapter is configured a dummy firmware file is created in a temporary directory, which is removed again by the
+returned function.
+*/
+func testFirmware(t *testing.T) (string, func()) {
+	if deviceConfigured() {
+		return testConfig().Firmware, func() {}
+	}
+
+	dir, err := ioutil.TempDir("", "firmware")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "firmware.bin")
+	if err = ioutil.WriteFile(path, []byte("dvlirtest firmware image"), 0600); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return path, func() { os.RemoveAll(dir) }
+}
+
 /*
 TestDvLIRClient_LoginLogout covers:
 	- Login
 	- Logout
 */
 func TestDvLIRClient_LoginLogout(t *testing.T) {
-	ip := viper.GetString("IPAddress")
-	pw := viper.GetString("Password")
+	ip, pw, done := testDevice(t)
+	defer done()
 	dvlirClient, err := NewDvLIRClient(ip, pw)
 	if !assert.NoError(t, err, "Error while creating Api client") {
 		return
@@ -40,8 +101,8 @@ TestDvLIRClient_GetDataFile covers:
 	- Logout
 */
 func TestDvLIRClient_GetDataFile(t *testing.T) {
-	ip := viper.GetString("IPAddress")
-	pw := viper.GetString("Password")
+	ip, pw, done := testDevice(t)
+	defer done()
 
 	dvlirClient, err := NewDvLIRClient(ip, pw)
 	if !assert.NoError(t, err, "Error while creating Api client") {
@@ -80,8 +141,8 @@ TestDvLIRClient_GetMomentaryValue covers:
 	- Logout
 */
 func TestDvLIRClient_GetMomentaryValues(t *testing.T) {
-	ip := viper.GetString("IPAddress")
-	pw := viper.GetString("Password")
+	ip, pw, done := testDevice(t)
+	defer done()
 
 	dvlirClient, err := NewDvLIRClient(ip, pw)
 	if !assert.NoError(t, err, "Error while creating Api client") {
@@ -121,8 +182,8 @@ TestDvLIRClient_GetGeneralInformation covers:
 	- Logout
 */
 func TestDvLIRClient_GetGeneralInformation(t *testing.T) {
-	ip := viper.GetString("IPAddress")
-	pw := viper.GetString("Password")
+	ip, pw, done := testDevice(t)
+	defer done()
 
 	dvlirClient, err := NewDvLIRClient(ip, pw)
 	if !assert.NoError(t, err, "Error while creating Api client") {
@@ -162,8 +223,8 @@ TestDvLIRClient_GetNetworkInformation covers:
 	- Logout
 */
 func TestDvLIRClient_GetNetworkInformation(t *testing.T) {
-	ip := viper.GetString("IPAddress")
-	pw := viper.GetString("Password")
+	ip, pw, done := testDevice(t)
+	defer done()
 
 	dvlirClient, err := NewDvLIRClient(ip, pw)
 	if !assert.NoError(t, err, "Error while creating Api client") {
@@ -203,8 +264,8 @@ TestDvLIRClient_GetSystemInformation covers:
 	- Logout
 */
 func TestDvLIRClient_GetSystemInformation(t *testing.T) {
-	ip := viper.GetString("IPAddress")
-	pw := viper.GetString("Password")
+	ip, pw, done := testDevice(t)
+	defer done()
 
 	dvlirClient, err := NewDvLIRClient(ip, pw)
 	if !assert.NoError(t, err, "Error while creating Api client") {
@@ -244,8 +305,8 @@ TestDvLIRClient_Blink covers:
 	- Logout
 */
 func TestDvLIRClient_Blink(t *testing.T) {
-	ip := viper.GetString("IPAddress")
-	pw := viper.GetString("Password")
+	ip, pw, done := testDevice(t)
+	defer done()
 
 	dvlirClient, err := NewDvLIRClient(ip, pw)
 	if !assert.NoError(t, err, "Error while creating Api client") {
@@ -282,8 +343,8 @@ TestDvLIRClient_NTPServerTest covers:
 	- Logout
 */
 func TestDvLIRClient_NTPServerTest(t *testing.T) {
-	ip := viper.GetString("IPAddress")
-	pw := viper.GetString("Password")
+	ip, pw, done := testDevice(t)
+	defer done()
 
 	dvlirClient, err := NewDvLIRClient(ip, pw)
 	if !assert.NoError(t, err, "Error while creating Api client") {
@@ -323,8 +384,8 @@ TestDvLIRClient_ChangeNetworkSettings covers:
 	- Logout
 */
 func TestDvLIRClient_ChangeNetworkSettings(t *testing.T) {
-	ip := viper.GetString("IPAddress")
-	pw := viper.GetString("Password")
+	ip, pw, done := testDevice(t)
+	defer done()
 
 	dvlirClient, err := NewDvLIRClient(ip, pw)
 	if !assert.NoError(t, err, "Error while creating Api client") {
@@ -358,8 +419,8 @@ TestDvLIRClient_ChangeSavingInterval covers:
 	- Logout
 */
 func TestDvLIRClient_ChangeSavingInterval(t *testing.T) {
-	ip := viper.GetString("IPAddress")
-	pw := viper.GetString("Password")
+	ip, pw, done := testDevice(t)
+	defer done()
 
 	dvlirClient, err := NewDvLIRClient(ip, pw)
 	if !assert.NoError(t, err, "Error while creating Api client") {
@@ -393,8 +454,8 @@ TestDvLIRClient_AllowResetWithPwd covers:
 	- Logout
 */
 func TestDvLIRClient_AllowResetWithPwd(t *testing.T) {
-	ip := viper.GetString("IPAddress")
-	pw := viper.GetString("Password")
+	ip, pw, done := testDevice(t)
+	defer done()
 
 	dvlirClient, err := NewDvLIRClient(ip, pw)
 	if !assert.NoError(t, err, "Error while creating Api client") {
@@ -429,8 +490,8 @@ TestDvLIRClient_ResetAll covers:
 	- Logout
 */
 func TestDvLIRClient_ResetAll(t *testing.T) {
-	ip := viper.GetString("IPAddress")
-	pw := viper.GetString("Password")
+	ip, pw, done := testDevice(t)
+	defer done()
 
 	dvlirClient, err := NewDvLIRClient(ip, pw)
 	if !assert.NoError(t, err, "Error while creating Api client") {
@@ -474,8 +535,8 @@ TestDvLIRClient_DeleteData covers:
 	- Logout
 */
 func TestDvLIRClient_DeleteData(t *testing.T) {
-	ip := viper.GetString("IPAddress")
-	pw := viper.GetString("Password")
+	ip, pw, done := testDevice(t)
+	defer done()
 
 	dvlirClient, err := NewDvLIRClient(ip, pw)
 	if !assert.NoError(t, err, "Error while creating Api client") {
@@ -519,8 +580,8 @@ TestDvLIRClient_ChangePassword covers:
 	- Logout
 */
 func TestDvLIRClient_ChangePassword(t *testing.T) {
-	ip := viper.GetString("IPAddress")
-	pw := viper.GetString("Password")
+	ip, pw, done := testDevice(t)
+	defer done()
 
 	dvlirClient, err := NewDvLIRClient(ip, pw)
 	if !assert.NoError(t, err, "Error while creating Api client") {
@@ -532,7 +593,7 @@ func TestDvLIRClient_ChangePassword(t *testing.T) {
 		return
 	}
 
-	res, err := dvlirClient.ChangePassword("<Old password>", "<New password>", "<New password>")
+	res, err := dvlirClient.ChangePassword(pw, pw, pw)
 	if !assert.NoError(t, err, "Error during ChangePassword request") {
 		return
 	}
@@ -553,9 +614,10 @@ TestDvLIRClient_UploadFirmware covers:
 	- Logout
 */
 func TestDvLIRClient_UploadFirmware(t *testing.T) {
-	ip := viper.GetString("IPAddress")
-	pw := viper.GetString("Password")
-	file := viper.GetString("Firmware")
+	ip, pw, done := testDevice(t)
+	defer done()
+	file, remove := testFirmware(t)
+	defer remove()
 
 	dvlirClient, err := NewDvLIRClient(ip, pw)
 	if !assert.NoError(t, err, "Error while creating Api client") {
@@ -589,8 +651,8 @@ TestDvLIRClient_Restart covers:
 	- Logout
 */
 func TestDvLIRClient_Restart(t *testing.T) {
-	ip := viper.GetString("IPAddress")
-	pw := viper.GetString("Password")
+	ip, pw, done := testDevice(t)
+	defer done()
 
 	dvlirClient, err := NewDvLIRClient(ip, pw)
 	if !assert.NoError(t, err, "Error while creating Api client") {
@@ -629,8 +691,8 @@ TestDvLIRClient_Restart covers:
 	- Logout
 */
 func TestDvLIRClient_Setup(t *testing.T) {
-	ip := viper.GetString("IPAddress")
-	pw := viper.GetString("Password")
+	ip, pw, done := testDevice(t)
+	defer done()
 
 	dvlirClient, err := NewDvLIRClient(ip, pw)
 	if !assert.NoError(t, err, "Error while creating Api client") {
@@ -673,3 +735,68 @@ func TestDvLIRClient_Setup(t *testing.T) {
 		}
 	}()
 }
+
+/*
+TestDvLIRClient_SimulatorState covers:
+	- Login
+	- ChangeSavingInterval
+	- GetSystemInformation
+	- Restart
+	- GetGeneralInformation
+*/
+func TestDvLIRClient_SimulatorState(t *testing.T) {
+	server := dvlirtest.NewServer()
+	defer server.Close()
+
+	dvlirClient, err := NewDvLIRClient(server.Address(), server.Password())
+	if !assert.NoError(t, err, "Error while creating Api client") {
+		return
+	}
+
+	err = dvlirClient.Login()
+	if !assert.NoError(t, err, "Error during Login") {
+		return
+	}
+
+	_, err = dvlirClient.ChangeSavingInterval("sec")
+	if !assert.NoError(t, err, "Error while changing saving interval") {
+		return
+	}
+
+	sysinfo, err := dvlirClient.GetSystemInformation()
+	if !assert.NoError(t, err, "Error during GetSystemInformation request") {
+		return
+	}
+	if !assert.Equal(t, SavingIntervalSecond, sysinfo.SavingInterval, "Changed saving interval wasn't persisted") {
+		return
+	}
+
+	_, err = dvlirClient.Restart()
+	if !assert.NoError(t, err, "Error during Restart request") {
+		return
+	}
+	if !assert.Equal(t, 1, server.Reboots(), "Adapter wasn't restarted") {
+		return
+	}
+
+	_, err = dvlirClient.GetGeneralInformation()
+	if !assert.Error(t, err, "Session is still valid after a restart") {
+		return
+	}
+
+	err = dvlirClient.Login()
+	if !assert.NoError(t, err, "Error during Login") {
+		return
+	}
+
+	info, err := dvlirClient.GetGeneralInformation()
+	if !assert.NoError(t, err, "Error during GetGeneralInformation request") {
+		return
+	}
+	if !assert.Equal(t, "01234567", info.MeterNumber, "Meter number wasn't padded") {
+		return
+	}
+	if !assert.Equal(t, SavingIntervalSecond, info.SavingInterval, "Changed saving interval wasn't persisted") {
+		return
+	}
+}
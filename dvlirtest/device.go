@@ -0,0 +1,171 @@
+package dvlirtest
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	//DateFormat is the date layout used by the adapter in info.txt and daten.csv
+	DateFormat = "02.01.2006"
+	//TimeFormat is the time layout used by the adapter in info.txt and daten.csv
+	TimeFormat = "15:04:05"
+
+	//MaxFirmwareSize is the size of the largest firmware image the simulator accepts. It is a limit of the simulator
+	//to test the rejection of an image, not a documented limit of the adapter.
+	MaxFirmwareSize = 512 * 1024
+
+	//maxRecords is the size of the ring buffer behind daten.csv
+	maxRecords = 14400
+)
+
+/*
+State contains the identity and the settings of a simulated DvLIR adapter.
+
+All values are kept in the representation the adapter uses in its responses.
+*/
+type State struct {
+	Password string
+
+	ServerIDMeter    string
+	MeterNumber      string
+	ManufacturerCode string
+	NetworkName      string
+	MACAddress       string
+	DeviceSn         string
+	FirmwareVersion  string
+
+	DHCPServer string
+	IPAddress  string
+	SubnetMask string
+	Gateway    string
+	DNSServer  string
+	NTPServer  string
+	NTPName    string
+
+	SavingInterval      string
+	ResetCode           string
+	DeleteCode          string
+	ResetWithDefaultPwd string
+}
+
+/*
+DefaultState returns the state of a freshly unpacked adapter
+*/
+func DefaultState() State {
+	return State{
+		Password: "dvlir",
+
+		ServerIDMeter:    "1ESY1160712345",
+		MeterNumber:      "1234567",
+		ManufacturerCode: "ESY",
+		NetworkName:      "DvLIR",
+		MACAddress:       "00:50:C2:9F:10:01",
+		DeviceSn:         "20190001",
+		FirmwareVersion:  "1.21",
+
+		DHCPServer: "no",
+		IPAddress:  "192.168.0.100",
+		SubnetMask: "255.255.255.0",
+		Gateway:    "192.168.0.1",
+		DNSServer:  "192.168.0.1",
+		NTPServer:  "yes",
+		NTPName:    "de.pool.ntp.org",
+
+		SavingInterval:      "15min",
+		ResetCode:           "4711",
+		DeleteCode:          "0815",
+		ResetWithDefaultPwd: "no",
+	}
+}
+
+/*
+record is a single entry of the daten.csv ring buffer.
+
+Energy registers are kept in units of 0.1 Wh which is the resolution the adapter reports in kWh with four decimals.
+*/
+type record struct {
+	index     int
+	at        time.Time
+	consumed  [3]int64
+	delivered [3]int64
+	power     int64
+	status    string
+}
+
+func (r record) line(state State) string {
+	return strings.Join([]string{
+		fmt.Sprint(r.index),
+		r.at.Format(DateFormat),
+		r.at.Format(TimeFormat),
+		state.DeviceSn,
+		state.MeterNumber,
+		formatEnergy(r.consumed[0]),
+		formatEnergy(r.consumed[1]),
+		formatEnergy(r.consumed[2]),
+		formatEnergy(r.delivered[0]),
+		formatEnergy(r.delivered[1]),
+		formatEnergy(r.delivered[2]),
+		fmt.Sprint(r.power),
+		r.status,
+	}, ";")
+}
+
+/*
+next returns the record following r at the given point in time.
+
+The simulated meter draws a load that varies with the time of day and feeds in a little around noon.
+*/
+func (r record) next(at time.Time) record {
+	hour := at.Hour()
+	power := int64(150 + 40*(hour%12))
+	tariff := 1
+	if hour < 6 || hour >= 22 {
+		tariff = 2
+	}
+
+	n := record{
+		index:     r.index + 1,
+		at:        at,
+		consumed:  r.consumed,
+		delivered: r.delivered,
+		power:     power,
+		status:    "0000",
+	}
+
+	//power in W over the elapsed time in 0.1 Wh
+	elapsed := at.Sub(r.at)
+	delta := power * int64(elapsed/time.Second) / 360
+	n.consumed[0] += delta
+	n.consumed[tariff] += delta
+	if hour >= 11 && hour < 14 {
+		n.delivered[0] += delta / 4
+		n.delivered[1] += delta / 4
+	}
+	return n
+}
+
+func formatEnergy(e int64) string {
+	return fmt.Sprintf("%d.%04d", e/10000, e%10000)
+}
+
+func intervalDuration(interval string) time.Duration {
+	switch interval {
+	case "sec":
+		return time.Second
+	case "min":
+		return time.Minute
+	default:
+		return 15 * time.Minute
+	}
+}
+
+func isYesNo(e string) bool {
+	switch e {
+	case "Yes", "yes", "No", "no":
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,256 @@
+package dvlirtest
+
+import (
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+)
+
+/*
+Fault describes a misbehaviour of the simulated adapter. Faults are created with the functions below and can be
+combined with And.
+*/
+type Fault struct {
+	latency   time.Duration
+	drop      bool
+	dropReply bool
+	reboot    bool
+	loginPage bool
+	truncate  int
+	truncated bool
+	fields    int
+	status    int
+	message   string
+}
+
+/*
+Latency delays the response by the given duration
+*/
+func Latency(d time.Duration) Fault {
+	return Fault{latency: d}
+}
+
+/*
+Drop closes the connection before the request is executed. The client receives an incomplete response header.
+*/
+func Drop() Fault {
+	return Fault{drop: true}
+}
+
+/*
+DropResponse executes the request, but closes the connection instead of sending the response, like an adapter that
+applied a change before the connection broke
+*/
+func DropResponse() Fault {
+	return Fault{dropReply: true}
+}
+
+/*
+Reboot restarts the adapter while it handles the request. The request is not executed, the connection is closed
+and the current session is invalidated.
+*/
+func Reboot() Fault {
+	return Fault{reboot: true}
+}
+
+/*
+ServeLoginPage returns the login page instead of the requested data
+*/
+func ServeLoginPage() Fault {
+	return Fault{loginPage: true}
+}
+
+/*
+Truncate cuts the response body after n bytes
+*/
+func Truncate(n int) Fault {
+	return Fault{truncate: n, truncated: true}
+}
+
+/*
+TooFewFields only returns the first n '#' separated fields of the response. In daten.csv every line is cut after n
+';' separated fields.
+*/
+func TooFewFields(n int) Fault {
+	return Fault{fields: n}
+}
+
+/*
+Status answers with the given http status code and an error response body containing the message
+*/
+func Status(code int, message string) Fault {
+	return Fault{status: code, message: message}
+}
+
+/*
+And combines two faults
+*/
+func (f Fault) And(other Fault) Fault {
+	f.latency += other.latency
+	f.drop = f.drop || other.drop
+	f.dropReply = f.dropReply || other.dropReply
+	f.reboot = f.reboot || other.reboot
+	f.loginPage = f.loginPage || other.loginPage
+	if other.truncated {
+		f.truncate, f.truncated = other.truncate, true
+	}
+	if other.fields > 0 {
+		f.fields = other.fields
+	}
+	if other.status != 0 {
+		f.status, f.message = other.status, other.message
+	}
+	return f
+}
+
+/*
+Rule schedules a fault.
+
+A rule matches every request to its endpoint, or every request at all if no endpoint is set. Whether a matching
+request is affected is decided by Nth or Probability, if neither is set every matching request is affected.
+*/
+type Rule struct {
+	//Endpoint is the path of the affected endpoint, e.g. "/data.txt"
+	Endpoint string
+	//Nth only affects the nth matching request (starting at 1)
+	Nth int
+	//Probability affects a matching request with the given probability (0 < p <= 1)
+	Probability float64
+	//Times limits how often the fault is injected (0 means unlimited)
+	Times int
+	//Fault is the injected fault
+	Fault Fault
+
+	matched  int
+	injected int
+}
+
+/*
+WithSeed sets the seed used for faults that are injected by probability
+*/
+func WithSeed(seed int64) Option {
+	return func(s *Server) {
+		s.random = rand.New(rand.NewSource(seed))
+	}
+}
+
+/*
+Inject adds rules to the fault schedule of the simulated adapter. Rules are evaluated in the order they were added,
+the first rule that affects a request wins.
+*/
+func (s *Server) Inject(rules ...Rule) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, rule := range rules {
+		rule := rule
+		s.rules = append(s.rules, &rule)
+	}
+}
+
+/*
+ClearFaults removes all rules from the fault schedule
+*/
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rules = nil
+}
+
+/*
+Injected returns how many faults have been injected so far
+*/
+func (s *Server) Injected() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	injected := 0
+	for _, rule := range s.rules {
+		injected += rule.injected
+	}
+	return injected
+}
+
+/*
+fault returns the fault for a request to the given endpoint
+*/
+func (s *Server) fault(endpoint string) (Fault, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var fault Fault
+	affected := false
+	for _, rule := range s.rules {
+		if rule.Endpoint != "" && rule.Endpoint != endpoint {
+			continue
+		}
+		rule.matched++
+		if affected || (rule.Times > 0 && rule.injected >= rule.Times) {
+			continue
+		}
+		if rule.Nth > 0 && rule.matched != rule.Nth {
+			continue
+		}
+		if rule.Probability > 0 && s.random.Float64() >= rule.Probability {
+			continue
+		}
+		rule.injected++
+		fault, affected = rule.Fault, true
+	}
+	return fault, affected
+}
+
+/*
+apply changes a response according to the fault
+*/
+func (f Fault) apply(status int, body string) (int, string) {
+	if f.loginPage {
+		body = LoginPage
+	}
+	if f.fields > 0 {
+		body = cutFields(body, f.fields)
+	}
+	if f.truncated && f.truncate < len(body) {
+		body = body[:f.truncate]
+	}
+	if f.status != 0 {
+		status, body = errorResponse(f.status, f.message)
+	}
+	return status, body
+}
+
+func cutFields(body string, n int) string {
+	if !strings.Contains(body, ";") {
+		fields := strings.Split(body, "#")
+		if len(fields) > n {
+			fields = fields[:n]
+		}
+		return strings.Join(fields, "#")
+	}
+
+	lines := strings.Split(body, "\r\n")
+	for i, line := range lines {
+		fields := strings.Split(line, ";")
+		if len(fields) > n {
+			lines[i] = strings.Join(fields[:n], ";")
+		}
+	}
+	return strings.Join(lines, "\r\n")
+}
+
+/*
+abort closes the connection after sending an incomplete response, so the client can't mistake it for a closed idle
+connection and silently retry the request
+*/
+func abort(w http.ResponseWriter) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		panic(http.ErrAbortHandler)
+	}
+	conn, buf, err := hijacker.Hijack()
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+	_, _ = buf.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 4096\r\n\r\n")
+	_ = buf.Flush()
+	_ = conn.Close()
+}
@@ -0,0 +1,34 @@
+package dvlirtest
+
+import (
+	"bytes"
+)
+
+/*
+FirmwareMagic starts every firmware image of the simulated adapter. An image consists of the magic, the version and a
+line break followed by the payload.
+*/
+const FirmwareMagic = "DVLIRFW1\n"
+
+/*
+FirmwareImage returns a firmware image which updates the simulated adapter to the given version
+*/
+func FirmwareImage(version string, payload []byte) []byte {
+	return append([]byte(FirmwareMagic+version+"\n"), payload...)
+}
+
+/*
+firmwareVersion returns the version of a firmware image. Images without the magic are accepted for compatibility but
+don't change the version.
+*/
+func firmwareVersion(image []byte) (string, bool) {
+	if !bytes.HasPrefix(image, []byte(FirmwareMagic)) {
+		return "", false
+	}
+	header := image[len(FirmwareMagic):]
+	end := bytes.IndexByte(header, '\n')
+	if end <= 0 {
+		return "", false
+	}
+	return string(header[:end]), true
+}
@@ -0,0 +1,756 @@
+/*
+Package dvlirtest provides an in-process simulation of a DvLIR network readout adapter.
+
+The simulated adapter serves the same endpoints and response formats as the real device and keeps its state between
+requests, so the whole api-client can be tested without any hardware.
+*/
+package dvlirtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/inexio/dvlir-restapi-go-client/internal/hostname"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+LoginPage is returned by the adapter instead of the requested data whenever the session ID is missing or invalid
+*/
+const LoginPage = `<!DOCTYPE html>
+<html>
+<head><title>DvLIR Login</title></head>
+<body><form action="index.htm" method="get"><input type="password" name="pwd"><input type="submit" value="Login"></form></body>
+</html>`
+
+/*
+Server is a simulated DvLIR adapter listening on a loopback address
+*/
+type Server struct {
+	//URL is the base url of the simulated adapter, e.g. http://127.0.0.1:53211
+	URL string
+
+	server *httptest.Server
+
+	mu          sync.Mutex
+	state       State
+	factory     State
+	now         time.Time
+	history     int
+	meter       record
+	records     []record
+	sessionID   string
+	sessions    int
+	reboots     int
+	lastNTPTest time.Time
+	rules       []*Rule
+	random      *rand.Rand
+	rebind      bool
+	address     string
+	lease       string
+	rebootTime  time.Duration
+	downUntil   time.Time
+}
+
+/*
+Option configures a Server on creation
+*/
+type Option func(*Server)
+
+/*
+WithState sets the initial (and factory) state of the simulated adapter
+*/
+func WithState(state State) Option {
+	return func(s *Server) {
+		s.state = state
+		s.factory = state
+	}
+}
+
+/*
+WithAddress lets the simulated adapter listen at the given host:port, e.g. "127.0.0.2:8080", instead of a random port
+on 127.0.0.1. The IP address of the adapter's state is set accordingly. NewServer panics if the address isn't
+available.
+*/
+func WithAddress(address string) Option {
+	return func(s *Server) {
+		s.address = address
+	}
+}
+
+/*
+WithDHCPLease sets the IP address a DHCP server assigns to the simulated adapter. If DHCP is enabled via network.cmd,
+the adapter moves to this address as described for WithRebinding.
+*/
+func WithDHCPLease(ip string) Option {
+	return func(s *Server) {
+		s.lease = ip
+	}
+}
+
+/*
+WithRebinding lets the simulated adapter move to its new IP address when it is changed via network.cmd, like the real
+adapter does. The simulated adapter keeps its port and stops answering at the old address. The new address has to be
+a loopback address the host can bind to, e.g. 127.0.0.2 on Linux, otherwise the simulated adapter stays where it is.
+*/
+func WithRebinding() Option {
+	return func(s *Server) {
+		s.rebind = true
+	}
+}
+
+/*
+WithRebootDuration lets the simulated adapter stay unreachable for the given (wall clock) duration after a restart.
+Requests during the reboot are aborted like those to a real adapter that is down. By default the simulated adapter
+restarts instantly.
+*/
+func WithRebootDuration(d time.Duration) Option {
+	return func(s *Server) {
+		s.rebootTime = d
+	}
+}
+
+/*
+WithStartTime sets the time of the simulated adapter's clock
+*/
+func WithStartTime(t time.Time) Option {
+	return func(s *Server) {
+		s.now = t
+	}
+}
+
+/*
+WithHistory sets the number of records the simulated adapter has already saved to daten.csv
+*/
+func WithHistory(records int) Option {
+	return func(s *Server) {
+		s.history = records
+	}
+}
+
+/*
+NewServer starts a new simulated DvLIR adapter. It has to be closed with Close after use.
+*/
+func NewServer(opts ...Option) *Server {
+	s := &Server{
+		state:   DefaultState(),
+		factory: DefaultState(),
+		now:     time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC),
+		history: 96,
+		random:  rand.New(rand.NewSource(1)),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.seedRecords()
+
+	if s.address == "" {
+		s.server = httptest.NewServer(s)
+	} else {
+		listener, err := net.Listen("tcp", s.address)
+		if err != nil {
+			panic("dvlirtest: failed to listen on " + s.address + ": " + err.Error())
+		}
+		s.server = httptest.NewUnstartedServer(s)
+		s.server.Listener.Close()
+		s.server.Listener = listener
+		s.server.Start()
+		if host, _, err := net.SplitHostPort(listener.Addr().String()); err == nil {
+			s.state.IPAddress = host
+		}
+	}
+	s.URL = s.server.URL
+	return s
+}
+
+/*
+Close shuts the simulated adapter down
+*/
+func (s *Server) Close() {
+	s.mu.Lock()
+	server := s.server
+	s.mu.Unlock()
+	server.Close()
+}
+
+/*
+Address returns the host:port of the simulated adapter as expected by NewDvLIRClient
+*/
+func (s *Server) Address() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return strings.TrimPrefix(s.URL, "http://")
+}
+
+/*
+Password returns the current password of the simulated adapter
+*/
+func (s *Server) Password() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state.Password
+}
+
+/*
+State returns a copy of the current state of the simulated adapter
+*/
+func (s *Server) State() State {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state
+}
+
+/*
+Update changes the state of the simulated adapter
+*/
+func (s *Server) Update(fn func(*State)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(&s.state)
+}
+
+/*
+SessionID returns the currently valid session ID or an empty string if no one is logged in
+*/
+func (s *Server) SessionID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sessionID
+}
+
+/*
+ExpireSession ends the current session as the adapter does after a period of inactivity. Subsequent requests with the
+old session ID are answered with the login page.
+*/
+func (s *Server) ExpireSession() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessionID = ""
+}
+
+/*
+Logins returns how many sessions have been started with a valid password
+*/
+func (s *Server) Logins() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sessions
+}
+
+/*
+Reboots returns how often the simulated adapter has been restarted
+*/
+func (s *Server) Reboots() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.reboots
+}
+
+/*
+Now returns the time of the simulated adapter's clock
+*/
+func (s *Server) Now() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.now
+}
+
+/*
+Advance moves the simulated adapter's clock forward and saves a record for every elapsed saving interval
+*/
+func (s *Server) Advance(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.advance(d)
+}
+
+/*
+Records returns the number of records currently held in daten.csv
+*/
+func (s *Server) Records() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.records)
+}
+
+func (s *Server) advance(d time.Duration) {
+	end := s.now.Add(d)
+	step := intervalDuration(s.state.SavingInterval)
+	for next := s.now.Truncate(step).Add(step); !next.After(end); next = next.Add(step) {
+		s.save(next)
+	}
+	s.now = end
+}
+
+func (s *Server) save(at time.Time) {
+	s.meter = s.meter.next(at)
+	s.records = append(s.records, s.meter)
+	if len(s.records) > maxRecords {
+		s.records = s.records[len(s.records)-maxRecords:]
+	}
+}
+
+/*
+seedRecords fills daten.csv with the configured number of records up to the current time
+*/
+func (s *Server) seedRecords() {
+	step := intervalDuration(s.state.SavingInterval)
+	start := s.now.Truncate(step).Add(-time.Duration(s.history) * step)
+	s.meter = record{
+		at:        start,
+		consumed:  [3]int64{158734521, 121002874, 37731647},
+		delivered: [3]int64{4213370, 4213370, 0},
+		status:    "0000",
+	}
+	for at := start.Add(step); !at.After(s.now); at = at.Add(step) {
+		s.save(at)
+	}
+}
+
+/*
+reboot restarts the simulated adapter, which invalidates the current session
+*/
+func (s *Server) reboot() {
+	s.sessionID = ""
+	s.reboots++
+	s.downUntil = time.Now().Add(s.rebootTime)
+}
+
+/*
+down returns true while the simulated adapter reboots
+*/
+func (s *Server) down() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return time.Now().Before(s.downUntil)
+}
+
+/*
+ServeHTTP dispatches a request to the simulated adapter
+*/
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.down() {
+		abort(w)
+		return
+	}
+	fault, faulty := s.fault(r.URL.Path)
+	if faulty && fault.latency > 0 {
+		select {
+		case <-time.After(fault.latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	if faulty && fault.reboot {
+		s.mu.Lock()
+		s.reboot()
+		s.mu.Unlock()
+		abort(w)
+		return
+	}
+	if faulty && fault.drop {
+		abort(w)
+		return
+	}
+
+	status, body := s.handle(r)
+	if faulty && fault.dropReply {
+		abort(w)
+		return
+	}
+	if faulty {
+		status, body = fault.apply(status, body)
+	}
+	w.Header().Set("Content-Type", contentType(r.URL.Path, status, body))
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte(body))
+}
+
+func (s *Server) handle(r *http.Request) (int, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	query := r.URL.Query()
+	switch r.URL.Path {
+	case "/getSID.txt":
+		return s.login(query)
+	case "/doReset.cmd":
+		return s.restart(query)
+	}
+
+	handler, ok := map[string]func(*http.Request, url.Values) (int, string){
+		"/daten.csv":    s.dataFile,
+		"/data.txt":     s.momentaryValues,
+		"/info.txt":     s.generalInformation,
+		"/network.txt":  s.networkInformation,
+		"/system.txt":   s.systemInformation,
+		"/blink.cmd":    s.blink,
+		"/ntpTest.cmd":  s.ntpTest,
+		"/network.cmd":  s.changeNetwork,
+		"/system.cmd":   s.changeSystem,
+		"/password.cmd": s.changePassword,
+		"/upload.cmd":   s.uploadFirmware,
+	}[r.URL.Path]
+	if !ok {
+		return errorResponse(http.StatusNotFound, "no such endpoint: "+r.URL.Path)
+	}
+
+	if s.sessionID == "" || query.Get("sid") != s.sessionID {
+		return http.StatusOK, LoginPage
+	}
+	return handler(r, query)
+}
+
+func (s *Server) login(query url.Values) (int, string) {
+	pwd, ok := query["pwd"]
+	if !ok {
+		//Requesting a session ID without a password ends the current session
+		s.sessionID = ""
+		return http.StatusOK, LoginPage
+	}
+	if pwd[0] != s.state.Password {
+		return http.StatusOK, LoginPage
+	}
+
+	s.sessions++
+	s.sessionID = fmt.Sprintf("%016X", uint64(s.sessions)*11400714819323198485)
+	return http.StatusOK, s.sessionID
+}
+
+func (s *Server) restart(query url.Values) (int, string) {
+	if query.Get("pwd") != s.state.Password {
+		return http.StatusOK, LoginPage
+	}
+	s.reboot()
+	return http.StatusOK, "cmd=doReset"
+}
+
+func (s *Server) dataFile(_ *http.Request, query url.Values) (int, string) {
+	lines, err := strconv.Atoi(query.Get("lines"))
+	if err != nil || lines < 1 || lines > maxRecords {
+		return http.StatusOK, "cmd="
+	}
+	if lines > len(s.records) {
+		lines = len(s.records)
+	}
+
+	var b strings.Builder
+	for _, r := range s.records[len(s.records)-lines:] {
+		b.WriteString(r.line(s.state))
+		b.WriteString("\r\n")
+	}
+	return http.StatusOK, b.String()
+}
+
+func (s *Server) momentaryValues(_ *http.Request, _ url.Values) (int, string) {
+	r := s.meter
+	values := []string{
+		s.state.MeterNumber,
+		"1-0:1.8.0",
+		fmt.Sprint(r.power),
+		formatEnergy(r.consumed[0]),
+		formatEnergy(r.delivered[0]),
+	}
+	for tariff := 1; tariff <= 9; tariff++ {
+		values = append(values, formatEnergy(tariffValue(r.consumed, tariff)))
+	}
+	for tariff := 1; tariff <= 9; tariff++ {
+		values = append(values, formatEnergy(tariffValue(r.delivered, tariff)))
+	}
+	values = append(values, r.status, s.state.SavingInterval)
+	return http.StatusOK, strings.Join(values, "#")
+}
+
+func tariffValue(registers [3]int64, tariff int) int64 {
+	if tariff < len(registers) {
+		return registers[tariff]
+	}
+	return 0
+}
+
+func (s *Server) generalInformation(_ *http.Request, _ url.Values) (int, string) {
+	return http.StatusOK, strings.Join([]string{
+		s.state.ServerIDMeter,
+		s.state.MeterNumber,
+		s.state.ManufacturerCode,
+		s.state.IPAddress,
+		s.state.Gateway,
+		s.state.DNSServer,
+		s.state.NetworkName,
+		s.state.MACAddress,
+		s.state.SavingInterval,
+		s.now.Format(DateFormat),
+		s.now.Format(TimeFormat),
+		s.state.DeviceSn,
+		s.state.FirmwareVersion,
+	}, "#")
+}
+
+func (s *Server) networkInformation(_ *http.Request, _ url.Values) (int, string) {
+	return http.StatusOK, strings.Join([]string{
+		s.state.DHCPServer,
+		s.state.IPAddress,
+		s.state.SubnetMask,
+		s.state.Gateway,
+		s.state.DNSServer,
+		s.state.NTPServer,
+		s.state.NTPName,
+	}, "#")
+}
+
+func (s *Server) systemInformation(_ *http.Request, _ url.Values) (int, string) {
+	return http.StatusOK, strings.Join([]string{
+		s.state.SavingInterval,
+		s.state.ResetCode,
+		s.state.DeleteCode,
+		s.state.ResetWithDefaultPwd,
+	}, "#")
+}
+
+func (s *Server) blink(_ *http.Request, query url.Values) (int, string) {
+	pause, err := strconv.Atoi(query.Get("ledPause"))
+	if err != nil || pause < 1 || pause > 1000 {
+		return http.StatusOK, "cmd="
+	}
+	blink, err := strconv.Atoi(query.Get("ledBlink"))
+	if err != nil || blink < 1 || blink > 10000 {
+		return http.StatusOK, "cmd="
+	}
+	return http.StatusOK, "123"
+}
+
+/*
+ntpTest answers an NTP server test. Names ending in .invalid can't be reached and a new test is only possible 30 seconds
+after the last one.
+*/
+func (s *Server) ntpTest(_ *http.Request, query url.Values) (int, string) {
+	if !s.lastNTPTest.IsZero() && s.now.Sub(s.lastNTPTest) < 30*time.Second {
+		return http.StatusOK, "2"
+	}
+	s.lastNTPTest = s.now
+
+	name := query.Get("ntpName")
+	if !hostname.Valid(name) {
+		return http.StatusOK, "3"
+	}
+	if strings.HasSuffix(name, ".invalid") {
+		return http.StatusOK, "0"
+	}
+	return http.StatusOK, "1"
+}
+
+/*
+changeNetwork applies new network settings. Like the real adapter it does not validate addresses.
+*/
+func (s *Server) changeNetwork(_ *http.Request, query url.Values) (int, string) {
+	changed := false
+	set := func(param string, target *string, yesNo bool) bool {
+		value, ok := query[param]
+		if !ok {
+			return true
+		}
+		if yesNo && !isYesNo(value[0]) {
+			return false
+		}
+		if yesNo {
+			*target = strings.ToLower(value[0])
+		} else {
+			*target = value[0]
+		}
+		changed = true
+		return true
+	}
+
+	state := s.state
+	if !set("dhcpServer", &state.DHCPServer, true) ||
+		!set("ip", &state.IPAddress, false) ||
+		!set("sub", &state.SubnetMask, false) ||
+		!set("gw", &state.Gateway, false) ||
+		!set("dns", &state.DNSServer, false) ||
+		!set("ntpName", &state.NTPName, false) ||
+		!set("ntpServer", &state.NTPServer, true) {
+		return http.StatusOK, "cmd="
+	}
+	if dt, ok := query["setDt"]; ok {
+		t, err := time.ParseInLocation(DateFormat+" "+TimeFormat, dt[0], s.now.Location())
+		if err != nil {
+			return http.StatusOK, "cmd="
+		}
+		s.now = t
+		changed = true
+	}
+	if !changed {
+		return http.StatusOK, "cmd="
+	}
+
+	moved := state.IPAddress != s.state.IPAddress
+	leased := state.DHCPServer == "yes" && s.state.DHCPServer != "yes" && s.lease != ""
+	if leased {
+		state.IPAddress = s.lease
+	}
+	s.state = state
+	if leased || moved && s.rebind {
+		s.moveTo(state.IPAddress)
+	}
+	return http.StatusOK, "cmd=network"
+}
+
+/*
+moveTo starts listening at the given IP address with the current port and stops listening at the old address once
+the pending responses are sent
+*/
+func (s *Server) moveTo(ip string) {
+	_, port, err := net.SplitHostPort(s.server.Listener.Addr().String())
+	if err != nil {
+		return
+	}
+	listener, err := net.Listen("tcp", net.JoinHostPort(ip, port))
+	if err != nil {
+		return
+	}
+
+	server := httptest.NewUnstartedServer(s)
+	server.Listener.Close()
+	server.Listener = listener
+	server.Start()
+
+	old := s.server
+	s.server = server
+	s.URL = server.URL
+	go old.Close()
+}
+
+/*
+changeSystem executes a single system command and echoes it in the response
+*/
+func (s *Server) changeSystem(_ *http.Request, query url.Values) (int, string) {
+	var cmd string
+	switch {
+	case query.Get("interval") != "":
+		interval := query.Get("interval")
+		if interval != "15min" && interval != "min" && interval != "sec" {
+			return http.StatusOK, "cmd="
+		}
+		s.state.SavingInterval = interval
+		cmd = "interval"
+	case query.Get("allowResetWithPwd") != "":
+		allow := query.Get("allowResetWithPwd")
+		if !isYesNo(allow) {
+			return http.StatusOK, "cmd="
+		}
+		s.state.ResetWithDefaultPwd = strings.ToLower(allow)
+		cmd = "allowResetWithPwd"
+	case query.Get("resetAll") != "":
+		if query.Get("resetAll") != s.state.ResetCode {
+			return http.StatusOK, "cmd="
+		}
+		s.state = s.factory
+		s.reboot()
+		cmd = "resetAll"
+	case query.Get("resetData") != "":
+		if query.Get("resetData") != s.state.DeleteCode {
+			return http.StatusOK, "cmd="
+		}
+		s.records = s.records[:0]
+		cmd = "resetData"
+	default:
+		return http.StatusOK, "cmd="
+	}
+	return http.StatusOK, "cmd=" + cmd
+}
+
+/*
+changePassword answers a password change with 1 on success, 2 if the current password is wrong, 3 if the new passwords
+don't match and 4 if the new password contains an illegal character
+*/
+func (s *Server) changePassword(r *http.Request, _ url.Values) (int, string) {
+	if r.Method != http.MethodPost {
+		return errorResponse(http.StatusMethodNotAllowed, "password.cmd only accepts POST")
+	}
+	if err := r.ParseForm(); err != nil {
+		return errorResponse(http.StatusBadRequest, err.Error())
+	}
+
+	switch pw1, pw2, pw3 := r.PostForm.Get("pw1"), r.PostForm.Get("pw2"), r.PostForm.Get("pw3"); {
+	case pw1 != s.state.Password:
+		return http.StatusOK, "2"
+	case pw2 != pw3:
+		return http.StatusOK, "3"
+	case !validPassword(pw2):
+		return http.StatusOK, "4"
+	default:
+		s.state.Password = pw2
+		return http.StatusOK, "1"
+	}
+}
+
+func validPassword(pw string) bool {
+	if pw == "" {
+		return false
+	}
+	for _, c := range pw {
+		if c <= ' ' || c > '~' || strings.ContainsRune("#;&%", c) {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+uploadFirmware accepts a firmware image in the multipart field "firmware" and restarts the adapter afterwards. Empty
+images are answered with code 2, images larger than MaxFirmwareSize with code 3. Images created by FirmwareImage
+update the firmware version.
+*/
+func (s *Server) uploadFirmware(r *http.Request, _ url.Values) (int, string) {
+	if r.Method != http.MethodPost {
+		return errorResponse(http.StatusMethodNotAllowed, "upload.cmd only accepts POST")
+	}
+	file, _, err := r.FormFile("firmware")
+	if err != nil {
+		return http.StatusOK, "2"
+	}
+	defer file.Close()
+
+	image, err := ioutil.ReadAll(file)
+	if err != nil || len(image) == 0 {
+		return http.StatusOK, "2"
+	}
+	if len(image) > MaxFirmwareSize {
+		return http.StatusOK, "3"
+	}
+
+	if version, ok := firmwareVersion(image); ok {
+		s.state.FirmwareVersion = version
+	}
+	s.reboot()
+	return http.StatusOK, "1"
+}
+
+func errorResponse(status int, message string) (int, string) {
+	body, _ := json.Marshal(struct {
+		Message string `json:"message"`
+		Status  int    `json:"status"`
+	}{message, status})
+	return status, string(body)
+}
+
+func contentType(path string, status int, body string) string {
+	switch {
+	case status != http.StatusOK:
+		return "application/json"
+	case strings.HasPrefix(body, "<!DOCTYPE"):
+		return "text/html"
+	case strings.HasSuffix(path, ".csv"):
+		return "text/csv"
+	default:
+		return "text/plain"
+	}
+}
@@ -0,0 +1,131 @@
+package dvlirtest
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func get(t *testing.T, server *Server, path string) string {
+	res, err := http.Get(server.URL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+/*
+TestServer_Formats covers:
+	- getSID.txt
+	- data.txt
+	- info.txt
+	- network.txt
+	- system.txt
+	- daten.csv
+*/
+func TestServer_Formats(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+
+	assert.True(t, strings.HasPrefix(get(t, server, "/data.txt?sid=invalid"), "<!DOCTYPE"), "Login page wasn't returned")
+	assert.True(t, strings.HasPrefix(get(t, server, "/getSID.txt?pwd=wrong"), "<!DOCTYPE"), "Login page wasn't returned")
+
+	sid := get(t, server, "/getSID.txt?pwd="+server.Password())
+	if !assert.Equal(t, server.SessionID(), sid, "Session ID wasn't returned") {
+		return
+	}
+
+	for path, fields := range map[string]int{
+		"/data.txt":    25,
+		"/info.txt":    13,
+		"/network.txt": 7,
+		"/system.txt":  4,
+	} {
+		assert.Len(t, strings.Split(get(t, server, path+"?sid="+sid), "#"), fields, "Wrong number of fields in "+path)
+	}
+
+	file := get(t, server, "/daten.csv?sid="+sid+"&lines=10")
+	lines := strings.Split(strings.TrimSuffix(file, "\r\n"), "\r\n")
+	if !assert.Len(t, lines, 10, "Wrong number of lines in daten.csv") {
+		return
+	}
+	for _, line := range lines {
+		assert.Len(t, strings.Split(line, ";"), 13, "Wrong number of fields in daten.csv")
+	}
+}
+
+/*
+TestServer_Advance covers:
+	- Advance
+	- Records
+	- system.cmd
+*/
+func TestServer_Advance(t *testing.T) {
+	server := NewServer(WithHistory(10))
+	defer server.Close()
+
+	if !assert.Equal(t, 10, server.Records(), "History wasn't created") {
+		return
+	}
+
+	server.Advance(time.Hour)
+	if !assert.Equal(t, 14, server.Records(), "Records weren't saved every 15 minutes") {
+		return
+	}
+
+	sid := get(t, server, "/getSID.txt?pwd="+server.Password())
+	assert.Equal(t, "cmd=", get(t, server, "/system.cmd?sid="+sid+"&resetData=wrong"), "Wrong delete code was accepted")
+	assert.Equal(t, "cmd=resetData", get(t, server, "/system.cmd?sid="+sid+"&resetData="+server.State().DeleteCode))
+	assert.Equal(t, 0, server.Records(), "Data wasn't deleted")
+}
+
+/*
+TestServer_Faults covers:
+	- Inject
+	- TooFewFields
+	- Truncate
+	- Probability with a seed
+*/
+func TestServer_Faults(t *testing.T) {
+	server := NewServer(WithSeed(42))
+	defer server.Close()
+
+	sid := get(t, server, "/getSID.txt?pwd="+server.Password())
+	server.Inject(
+		Rule{Endpoint: "/info.txt", Fault: TooFewFields(5)},
+		Rule{Endpoint: "/daten.csv", Fault: TooFewFields(3)},
+		Rule{Endpoint: "/system.txt", Fault: Truncate(4)},
+	)
+
+	assert.Len(t, strings.Split(get(t, server, "/info.txt?sid="+sid), "#"), 5)
+	for _, line := range strings.Split(strings.TrimSuffix(get(t, server, "/daten.csv?sid="+sid+"&lines=3"), "\r\n"), "\r\n") {
+		assert.Len(t, strings.Split(line, ";"), 3)
+	}
+	assert.Equal(t, "15mi", get(t, server, "/system.txt?sid="+sid))
+	assert.Equal(t, 3, server.Injected())
+
+	pattern := func(seed int64) []bool {
+		server := NewServer(WithSeed(seed))
+		defer server.Close()
+		server.Inject(Rule{Endpoint: "/network.txt", Probability: 0.5, Fault: ServeLoginPage()})
+		sid := get(t, server, "/getSID.txt?pwd="+server.Password())
+
+		var affected []bool
+		for i := 0; i < 20; i++ {
+			affected = append(affected, strings.HasPrefix(get(t, server, "/network.txt?sid="+sid), "<!DOCTYPE"))
+		}
+		return affected
+	}
+	first := pattern(7)
+	assert.Equal(t, first, pattern(7), "Same seed led to different faults")
+	assert.Contains(t, first, true)
+	assert.Contains(t, first, false)
+}
@@ -0,0 +1,64 @@
+package dvlirclient
+
+import (
+	"github.com/pkg/errors"
+	"strconv"
+)
+
+// Errors returned by the operations of the client. They are wrapped, so errors.Is has to be used to check for them.
+var (
+	//ErrSessionExpired is returned if the adapter returned its login page instead of the requested data
+	ErrSessionExpired = errors.New("Login page was returned")
+	//ErrNTPUnreachable is returned by NTPServerTest if the NTP server can't be reached
+	ErrNTPUnreachable = errors.New("NTP-server can't be reached")
+	//ErrNTPRateLimited is returned by NTPServerTest if the last test was less than 30 seconds ago
+	ErrNTPRateLimited = errors.New("You have to wait at least 30 seconds until doing this again")
+	//ErrNTPInvalidName is returned by NTPServerTest if the name of the NTP server is invalid
+	ErrNTPInvalidName = errors.New("NTP-servername is invalid")
+	//ErrWrongPassword is returned by Login and ChangePassword if the password is wrong
+	ErrWrongPassword = errors.New("Current password is wrong")
+	//ErrPasswordMismatch is returned by ChangePassword if the new password and its confirmation differ
+	ErrPasswordMismatch = errors.New("New password does not match confirm new password")
+	//ErrIllegalPasswordChar is returned by ChangePassword if the new password contains an illegal character
+	ErrIllegalPasswordChar = errors.New("Illegal character in new password")
+	//ErrNoParameters is returned by ChangeNetworkSettings if no parameters were given or the adapter rejected them
+	ErrNoParameters = errors.New("Either no parameters were given or an unexpected error occurred")
+	//ErrAdapterNotFound is returned if the adapter didn't answer at its new address in time
+	ErrAdapterNotFound = errors.New("Adapter wasn't found")
+	//ErrDeviceMismatch is returned if a different adapter answered at the new address
+	ErrDeviceMismatch = errors.New("A different adapter answered")
+	//ErrNotReady is returned if the adapter didn't accept a login again in time after a restart
+	ErrNotReady = errors.New("Adapter didn't become ready")
+	//ErrNotConverged is returned by Reconcile if the adapter doesn't report the desired state after the changes
+	ErrNotConverged = errors.New("Adapter doesn't report the desired state")
+	//ErrNoAdapter is returned by Probe if the endpoint doesn't answer like a DvLIR adapter
+	ErrNoAdapter = errors.New("Endpoint isn't a DvLIR adapter")
+)
+
+// Error codes of the firmware upload with a known meaning
+const (
+	//FirmwareInvalidFile means that the file is missing, empty or not a firmware image
+	FirmwareInvalidFile = 2
+	//FirmwareTooLarge means that the image doesn't fit into the flash memory of the adapter
+	FirmwareTooLarge = 3
+)
+
+/*
+FirmwareUploadError is returned by UploadFirmware if the adapter rejected the firmware image
+*/
+type FirmwareUploadError struct {
+	//Code is the error code returned by the adapter, e.g. FirmwareInvalidFile. The adapter also returns codes without
+	//a documented meaning.
+	Code int
+}
+
+func (f *FirmwareUploadError) Error() string {
+	msg := "firmware upload failed with code " + strconv.Itoa(f.Code)
+	switch f.Code {
+	case FirmwareInvalidFile:
+		msg += " // invalid file"
+	case FirmwareTooLarge:
+		msg += " // file too large"
+	}
+	return msg
+}
@@ -0,0 +1,81 @@
+package dvlirclient
+
+import (
+	"github.com/inexio/dvlir-restapi-go-client/dvlirtest"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+/*
+TestDvLIRClient_Errors covers:
+	- NTPServerTest with ErrNTPUnreachable, ErrNTPRateLimited and ErrNTPInvalidName
+	- ChangePassword with ErrWrongPassword, ErrPasswordMismatch and ErrIllegalPasswordChar
+	- ChangeNetworkSettings with ErrNoParameters
+	- UploadFirmware with a FirmwareUploadError
+	- GetMomentaryValues with ErrSessionExpired and an HTTPError
+	- Login with ErrWrongPassword
+*/
+func TestDvLIRClient_Errors(t *testing.T) {
+	dvlirClient, server := newSimulatedClient(t)
+	defer server.Close()
+
+	_, err := dvlirClient.NTPServerTest("ntp.invalid")
+	assert.True(t, errors.Is(err, ErrNTPUnreachable), "ErrNTPUnreachable wasn't returned: %v", err)
+	_, err = dvlirClient.NTPServerTest("de.pool.ntp.org")
+	assert.True(t, errors.Is(err, ErrNTPRateLimited), "ErrNTPRateLimited wasn't returned: %v", err)
+	server.Advance(time.Minute)
+	_, err = dvlirClient.NTPServerTest("not a host name")
+	assert.True(t, errors.Is(err, ErrNTPInvalidName), "ErrNTPInvalidName wasn't returned: %v", err)
+
+	_, err = dvlirClient.ChangePassword("wrong", "secret", "secret")
+	assert.True(t, errors.Is(err, ErrWrongPassword), "ErrWrongPassword wasn't returned: %v", err)
+	_, err = dvlirClient.ChangePassword(server.Password(), "secret", "other")
+	assert.True(t, errors.Is(err, ErrPasswordMismatch), "ErrPasswordMismatch wasn't returned: %v", err)
+	_, err = dvlirClient.ChangePassword(server.Password(), "se#ret", "se#ret")
+	assert.True(t, errors.Is(err, ErrIllegalPasswordChar), "ErrIllegalPasswordChar wasn't returned: %v", err)
+	assert.Equal(t, "dvlir", server.Password(), "Password was changed")
+
+	server.Inject(dvlirtest.Rule{Endpoint: "/network.cmd", Times: 1, Fault: dvlirtest.Truncate(4)})
+	_, err = dvlirClient.ChangeNetworkSettings("", "", "", "", "", "", "", "")
+	assert.True(t, errors.Is(err, ErrNoParameters), "ErrNoParameters wasn't returned: %v", err)
+
+	dir, err := ioutil.TempDir("", "firmware")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "firmware.bin")
+	err = ioutil.WriteFile(path, make([]byte, dvlirtest.MaxFirmwareSize+1), 0600)
+	if !assert.NoError(t, err, "Error while writing firmware file") {
+		return
+	}
+	_, err = dvlirClient.UploadFirmware(path)
+	var uploadError *FirmwareUploadError
+	if assert.True(t, errors.As(err, &uploadError), "FirmwareUploadError wasn't returned: %v", err) {
+		assert.Equal(t, FirmwareTooLarge, uploadError.Code)
+	}
+	assert.EqualError(t, &FirmwareUploadError{Code: 5}, "firmware upload failed with code 5")
+
+	server.Inject(dvlirtest.Rule{Endpoint: "/data.txt", Times: 1, Fault: dvlirtest.Status(http.StatusServiceUnavailable, "busy")})
+	_, err = dvlirClient.GetMomentaryValues()
+	var httpError HTTPError
+	if assert.True(t, errors.As(err, &httpError), "HTTPError wasn't returned: %v", err) {
+		assert.Equal(t, http.StatusServiceUnavailable, httpError.StatusCode)
+	}
+
+	server.ExpireSession()
+	_, err = dvlirClient.GetMomentaryValues()
+	assert.True(t, errors.Is(err, ErrSessionExpired), "ErrSessionExpired wasn't returned: %v", err)
+
+	dvlirClient, err = NewDvLIRClient(server.Address(), "wrong")
+	if assert.NoError(t, err, "Error while creating client") {
+		err = dvlirClient.Login()
+		assert.True(t, errors.Is(err, ErrWrongPassword), "ErrWrongPassword wasn't returned: %v", err)
+	}
+}
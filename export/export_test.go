@@ -0,0 +1,228 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	dvlirclient "github.com/inexio/dvlir-restapi-go-client"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var testLines = dvlirclient.DataLines{
+	{
+		Index: "1", Date: "01.01.2020", Time: "00:15:00", DvLIRSn: "20190001", MeterNumber: "01234567",
+		OneEightZero: "15873.4521", OneEightOne: "12100.2874", OneEightTwo: "3773.1647",
+		TwoEightZero: "421.3370", TwoEightOne: "421.3370", TwoEightTwo: "0.0000", Power: "150", Status: "0000",
+	},
+}
+
+func testMomentaryValues() dvlirclient.MomentaryValues {
+	values := dvlirclient.MomentaryValues{
+		MeterNumber:    "01234567",
+		OBISNum:        "1-0:1.8.0",
+		MomentaryPower: "-12.5",
+		MeterReadingAP: "15873.4521",
+		MeterReadingAM: "421.3370",
+		Status:         "0000",
+	}
+	for i := range values.MeterReadingsAP {
+		values.MeterReadingsAP[i] = "0.0000"
+		values.MeterReadingsAM[i] = "0.0000"
+	}
+	values.MeterReadingsAP[0] = "12100.2874"
+	return values
+}
+
+/*
+TestInfluxEncoder covers:
+	- EncodeDataLines with a time zone and additional tags
+	- EncodeDataLine with millisecond precision and escaping
+	- EncodeMomentaryValues
+	- EncodeDataLine with an invalid line and an invalid precision
+*/
+func TestInfluxEncoder(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("Time zone database isn't available: ", err)
+	}
+
+	enc := NewInfluxEncoder("")
+	enc.Location = berlin
+	enc.Tags = map[string]string{"site": "main building"}
+	var b bytes.Buffer
+	if assert.NoError(t, EncodeDataLines(&b, enc, testLines), "Error while encoding data lines") {
+		assert.Equal(t, "dvlir,dvlir_sn=20190001,meter_number=01234567,site=main\\ building "+
+			"index=1i,1.8.0=15873452.100,1.8.1=12100287.400,1.8.2=3773164.700,2.8.0=421337.000,2.8.1=421337.000,"+
+			"2.8.2=0.000,power=150.000,status=0i 1577834100\n", b.String())
+	}
+
+	enc = &InfluxEncoder{Measurement: "load profile", Location: time.UTC, Precision: time.Millisecond}
+	b.Reset()
+	if assert.NoError(t, enc.EncodeDataLine(&b, testLines[0]), "Error while encoding data line") {
+		assert.True(t, strings.HasPrefix(b.String(), `load\ profile,dvlir_sn=20190001,`), b.String())
+		assert.True(t, strings.HasSuffix(b.String(), " 1577837700000\n"), b.String())
+	}
+
+	b.Reset()
+	at := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	if assert.NoError(t, enc.EncodeMomentaryValues(&b, testMomentaryValues(), at), "Error while encoding momentary values") {
+		assert.Contains(t, b.String(), ",meter_number=01234567 power=-12.500,1.8.0=15873452.100,2.8.0=421337.000,1.8.1=12100287.400,")
+		assert.Contains(t, b.String(), ",2.8.9=0.000,status=0i 1577880000000\n")
+	}
+
+	invalid := testLines[0]
+	invalid.Power = "a lot"
+	assert.Error(t, enc.EncodeDataLine(&b, invalid), "Invalid line wasn't rejected")
+	enc.Precision = time.Minute
+	assert.EqualError(t, enc.EncodeDataLine(&b, testLines[0]), "Invalid precision: 1m0s")
+}
+
+/*
+TestGraphiteEncoder covers:
+	- EncodeDataLine with tags in the metric path
+	- EncodeDataLine with Graphite tags
+	- EncodeMomentaryValues
+*/
+func TestGraphiteEncoder(t *testing.T) {
+	enc := NewGraphiteEncoder("meters")
+	enc.Location = time.UTC
+	var b bytes.Buffer
+	if assert.NoError(t, enc.EncodeDataLine(&b, testLines[0]), "Error while encoding data line") {
+		lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+		if assert.Len(t, lines, 9) {
+			assert.Equal(t, "meters.20190001.01234567.index 1 1577837700", lines[0])
+			assert.Equal(t, "meters.20190001.01234567.1_8_0 15873452.100 1577837700", lines[1])
+		}
+	}
+
+	enc.Tagged = true
+	b.Reset()
+	if assert.NoError(t, enc.EncodeDataLine(&b, testLines[0]), "Error while encoding data line") {
+		assert.Contains(t, b.String(), "meters.2_8_2;dvlir_sn=20190001;meter_number=01234567 0.000 1577837700\n")
+	}
+
+	b.Reset()
+	at := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	if assert.NoError(t, enc.EncodeMomentaryValues(&b, testMomentaryValues(), at), "Error while encoding momentary values") {
+		assert.Contains(t, b.String(), "meters.power;meter_number=01234567 -12.500 1577880000\n")
+		assert.Equal(t, 22, strings.Count(b.String(), "\n"))
+	}
+}
+
+/*
+TestWriter covers:
+	- Write with batches
+	- Write with a retry after a server error
+	- Write with a rejected batch
+	- Write without retries
+	- Concurrent writes of a Writer that wasn't created with NewWriter
+*/
+func TestWriter(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []string
+	var failures int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		body, _ := ioutil.ReadAll(r.Body)
+		switch {
+		case r.URL.Query().Get("db") != "metering" || r.URL.Query().Get("precision") != "ms":
+			http.Error(w, "wrong query", http.StatusBadRequest)
+		case r.Header.Get("Authorization") != "Token secret":
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+		case strings.Contains(string(body), "invalid"):
+			http.Error(w, "unable to parse", http.StatusBadRequest)
+		case failures > 0:
+			failures--
+			http.Error(w, "overloaded", http.StatusServiceUnavailable)
+		default:
+			bodies = append(bodies, string(body))
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	writer := NewWriter(server.URL + "/write?db=metering")
+	writer.Precision = time.Millisecond
+	writer.Token = "secret"
+	writer.BatchSize = 2
+	writer.RetryWait = time.Millisecond
+
+	failures = 2
+	ctx := context.Background()
+	if assert.NoError(t, writer.Write(ctx, []byte("a 1\nb 2\nc 3\n")), "Error during write") {
+		assert.Equal(t, []string{"a 1\nb 2\n", "c 3\n"}, bodies)
+	}
+
+	failures = 4
+	err := writer.Write(ctx, []byte("d 4\n"))
+	var writeError *WriteError
+	if assert.True(t, errors.As(err, &writeError), "WriteError wasn't returned") {
+		assert.Equal(t, http.StatusServiceUnavailable, writeError.StatusCode)
+	}
+	assert.Contains(t, err.Error(), "after 4 attempts")
+
+	failures = 0
+	err = writer.Write(ctx, []byte("invalid\n"))
+	if assert.True(t, errors.As(err, &writeError), "WriteError wasn't returned") {
+		assert.Equal(t, http.StatusBadRequest, writeError.StatusCode)
+		assert.Equal(t, "unable to parse", writeError.Body)
+	}
+
+	failures = 1
+	writer.MaxRetries = 0
+	err = writer.Write(ctx, []byte("e 5\n"))
+	if assert.Error(t, err, "Failed batch wasn't reported") {
+		assert.Contains(t, err.Error(), "after 1 attempts")
+	}
+
+	bodies = nil
+	writer = &Writer{URL: server.URL + "/write?db=metering", Precision: time.Millisecond, Token: "secret"}
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, writer.Write(ctx, []byte("f 6\n")), "Error during write")
+		}()
+	}
+	wg.Wait()
+	assert.Len(t, bodies, 4)
+}
+
+/*
+TestWriter_V2 covers:
+	- Write to the v2 endpoint with microsecond precision
+	- Write to the v1 endpoint with microsecond precision
+*/
+func TestWriter_V2(t *testing.T) {
+	var precisions []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		precision := r.URL.Query().Get("precision")
+		precisions = append(precisions, r.URL.Path+" "+precision)
+		switch {
+		case r.URL.Path == "/api/v2/write" && precision != "us", r.URL.Path == "/write" && precision != "u":
+			http.Error(w, "invalid precision", http.StatusBadRequest)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	writer := NewWriter(server.URL + "/api/v2/write?org=inexio&bucket=metering")
+	writer.Precision = time.Microsecond
+	assert.NoError(t, writer.Write(ctx, []byte("a 1\n")), "Error during write to the v2 endpoint")
+
+	writer = NewWriter(server.URL + "/write?db=metering")
+	writer.Precision = time.Microsecond
+	assert.NoError(t, writer.Write(ctx, []byte("a 1\n")), "Error during write to the v1 endpoint")
+	assert.Equal(t, []string{"/api/v2/write us", "/write u"}, precisions)
+}
@@ -0,0 +1,100 @@
+package export
+
+import (
+	dvlirclient "github.com/inexio/dvlir-restapi-go-client"
+	"github.com/pkg/errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+GraphiteEncoder writes points in the Graphite plaintext protocol. By default the tags are part of the metric path,
+e.g.
+
+	dvlir.20190001.01234567.1_8_0 15873452.100 1577834100
+
+With Tagged the tags are appended in the Graphite tag format instead, e.g.
+
+	dvlir.1_8_0;dvlir_sn=20190001;meter_number=01234567 15873452.100 1577834100
+*/
+type GraphiteEncoder struct {
+	//Prefix is the first component of every metric path, the default is "dvlir"
+	Prefix string
+	//Location is the time zone of the adapter's clock, the default is the local time zone
+	Location *time.Location
+	//Tagged writes the tags in the Graphite tag format instead of the metric path
+	Tagged bool
+}
+
+/*
+NewGraphiteEncoder creates an encoder with the given prefix
+*/
+func NewGraphiteEncoder(prefix string) *GraphiteEncoder {
+	return &GraphiteEncoder{Prefix: prefix}
+}
+
+/*
+EncodeDataLine writes a line of the data file
+*/
+func (e *GraphiteEncoder) EncodeDataLine(w io.Writer, line dvlirclient.DataLine) error {
+	p, err := dataLinePoint(line, e.Location)
+	if err != nil {
+		return err
+	}
+	return e.encode(w, p)
+}
+
+/*
+EncodeMomentaryValues writes the momentary values with the given timestamp
+*/
+func (e *GraphiteEncoder) EncodeMomentaryValues(w io.Writer, values dvlirclient.MomentaryValues, at time.Time) error {
+	p, err := momentaryPoint(values, at)
+	if err != nil {
+		return err
+	}
+	return e.encode(w, p)
+}
+
+func (e *GraphiteEncoder) encode(w io.Writer, p point) error {
+	prefix := e.Prefix
+	if prefix == "" {
+		prefix = "dvlir"
+	}
+	timestamp := " " + strconv.FormatInt(p.time.Unix(), 10) + "\n"
+
+	var b strings.Builder
+	for _, f := range p.fields {
+		b.WriteString(prefix)
+		if !e.Tagged {
+			for _, t := range p.tags {
+				b.WriteString("." + graphiteName(t.value))
+			}
+		}
+		b.WriteString("." + graphiteName(f.key))
+		if e.Tagged {
+			for _, t := range p.tags {
+				if t.value != "" {
+					b.WriteString(";" + graphiteName(t.key) + "=" + graphiteName(t.value))
+				}
+			}
+		}
+		b.WriteString(" " + f.value + timestamp)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return errors.Wrap(err, "Error while writing graphite plaintext")
+}
+
+/*
+graphiteName replaces the characters which separate path components, tags or values, e.g. "1.8.0" becomes "1_8_0"
+*/
+func graphiteName(name string) string {
+	if name == "" {
+		return "unknown"
+	}
+	return graphiteEscaper.Replace(name)
+}
+
+var graphiteEscaper = strings.NewReplacer(".", "_", " ", "_", ";", "_", "=", "_", "~", "_")
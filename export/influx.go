@@ -0,0 +1,146 @@
+package export
+
+import (
+	dvlirclient "github.com/inexio/dvlir-restapi-go-client"
+	"github.com/pkg/errors"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+InfluxEncoder writes points in the InfluxDB line protocol, e.g.
+
+	dvlir,dvlir_sn=20190001,meter_number=01234567 index=1i,1.8.0=15873452.100,...,power=150.000,status=0i 1577834100
+*/
+type InfluxEncoder struct {
+	//Measurement is the name of the measurement, the default is "dvlir"
+	Measurement string
+	//Location is the time zone of the adapter's clock, the default is the local time zone
+	Location *time.Location
+	//Precision of the timestamps, one of time.Nanosecond, time.Microsecond, time.Millisecond or time.Second. The
+	//default is time.Second, as the adapter only reports whole seconds.
+	Precision time.Duration
+	//Tags are added to every point
+	Tags map[string]string
+}
+
+/*
+NewInfluxEncoder creates an encoder for the given measurement
+*/
+func NewInfluxEncoder(measurement string) *InfluxEncoder {
+	return &InfluxEncoder{Measurement: measurement}
+}
+
+/*
+EncodeDataLine writes a line of the data file
+*/
+func (e *InfluxEncoder) EncodeDataLine(w io.Writer, line dvlirclient.DataLine) error {
+	p, err := dataLinePoint(line, e.Location)
+	if err != nil {
+		return err
+	}
+	return e.encode(w, p)
+}
+
+/*
+EncodeMomentaryValues writes the momentary values with the given timestamp
+*/
+func (e *InfluxEncoder) EncodeMomentaryValues(w io.Writer, values dvlirclient.MomentaryValues, at time.Time) error {
+	p, err := momentaryPoint(values, at)
+	if err != nil {
+		return err
+	}
+	return e.encode(w, p)
+}
+
+func (e *InfluxEncoder) encode(w io.Writer, p point) error {
+	precision, err := precisionUnit(e.Precision)
+	if err != nil {
+		return err
+	}
+	measurement := e.Measurement
+	if measurement == "" {
+		measurement = "dvlir"
+	}
+
+	var b strings.Builder
+	b.WriteString(measurementEscaper.Replace(measurement))
+	for _, t := range e.tags(p.tags) {
+		if t.value == "" {
+			//Empty tag values are not allowed by the line protocol
+			continue
+		}
+		b.WriteString("," + keyEscaper.Replace(t.key) + "=" + keyEscaper.Replace(t.value))
+	}
+	for i, f := range p.fields {
+		if i == 0 {
+			b.WriteByte(' ')
+		} else {
+			b.WriteByte(',')
+		}
+		b.WriteString(keyEscaper.Replace(f.key) + "=" + f.value)
+		if f.integer {
+			b.WriteByte('i')
+		}
+	}
+	b.WriteString(" " + strconv.FormatInt(p.time.UnixNano()/int64(precision), 10) + "\n")
+
+	_, err = io.WriteString(w, b.String())
+	return errors.Wrap(err, "Error while writing line protocol")
+}
+
+/*
+tags returns the tags of the point and the additional tags sorted by key, as recommended for the line protocol
+*/
+func (e *InfluxEncoder) tags(tags []tag) []tag {
+	all := append([]tag(nil), tags...)
+	for key, value := range e.Tags {
+		all = append(all, tag{key, value})
+	}
+	sort.SliceStable(all, func(i, j int) bool { return all[i].key < all[j].key })
+	return all
+}
+
+var (
+	measurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+	keyEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+)
+
+/*
+precisionUnit checks the precision and returns its duration, the default is time.Second
+*/
+func precisionUnit(precision time.Duration) (time.Duration, error) {
+	switch precision {
+	case 0:
+		return time.Second, nil
+	case time.Nanosecond, time.Microsecond, time.Millisecond, time.Second:
+		return precision, nil
+	}
+	return 0, errors.New("Invalid precision: " + precision.String())
+}
+
+/*
+precisionParam returns the precision as expected by the precision parameter of the InfluxDB write endpoint. The v1
+endpoint expects "u" for microseconds, the v2 endpoint "us".
+*/
+func precisionParam(precision time.Duration, v2 bool) (string, error) {
+	precision, err := precisionUnit(precision)
+	if err != nil {
+		return "", err
+	}
+	switch precision {
+	case time.Nanosecond:
+		return "ns", nil
+	case time.Microsecond:
+		if v2 {
+			return "us", nil
+		}
+		return "u", nil
+	case time.Millisecond:
+		return "ms", nil
+	}
+	return "s", nil
+}
@@ -0,0 +1,128 @@
+/*
+Package export encodes DataLines and MomentaryValues for time-series databases.
+
+The InfluxEncoder writes the InfluxDB line protocol, the GraphiteEncoder the Graphite plaintext protocol. Both use the
+timestamps of the data file, tag the values with the serial number of the adapter and the meter number and write a
+field for every OBIS register. The Writer posts line protocol batches to an HTTP endpoint.
+*/
+package export
+
+import (
+	dvlirclient "github.com/inexio/dvlir-restapi-go-client"
+	"github.com/pkg/errors"
+	"io"
+	"strconv"
+	"time"
+)
+
+/*
+Encoder writes DataLines and MomentaryValues in the format of a time-series database
+*/
+type Encoder interface {
+	//EncodeDataLine writes a line of the data file with its own timestamp
+	EncodeDataLine(w io.Writer, line dvlirclient.DataLine) error
+	//EncodeMomentaryValues writes the momentary values with the given timestamp, as the adapter doesn't report one
+	EncodeMomentaryValues(w io.Writer, values dvlirclient.MomentaryValues, at time.Time) error
+}
+
+/*
+EncodeDataLines writes all lines with the encoder
+*/
+func EncodeDataLines(w io.Writer, enc Encoder, lines dvlirclient.DataLines) error {
+	for _, line := range lines {
+		if err := enc.EncodeDataLine(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Tags and fields of the points
+const (
+	tagDvLIRSn     = "dvlir_sn"
+	tagMeterNumber = "meter_number"
+	fieldIndex     = "index"
+	fieldPower     = "power"
+	fieldStatus    = "status"
+)
+
+/*
+tag is a tag of a point
+*/
+type tag struct {
+	key   string
+	value string
+}
+
+/*
+field is a field of a point. Integer fields are encoded as integers by the line protocol.
+*/
+type field struct {
+	key     string
+	value   string
+	integer bool
+}
+
+/*
+point is a set of values with the same timestamp
+*/
+type point struct {
+	tags   []tag
+	fields []field
+	time   time.Time
+}
+
+/*
+dataLinePoint converts a line of the data file. Energy is given in Wh, power in W.
+*/
+func dataLinePoint(line dvlirclient.DataLine, loc *time.Location) (point, error) {
+	reading, err := line.Reading(loc)
+	if err != nil {
+		return point{}, errors.Wrap(err, "invalid data line "+line.Index)
+	}
+	return point{
+		tags: []tag{
+			{tagDvLIRSn, reading.DvLIRSn},
+			{tagMeterNumber, reading.MeterNumber},
+		},
+		fields: []field{
+			{key: fieldIndex, value: strconv.Itoa(reading.Index), integer: true},
+			{key: "1.8.0", value: reading.OneEightZero.String()},
+			{key: "1.8.1", value: reading.OneEightOne.String()},
+			{key: "1.8.2", value: reading.OneEightTwo.String()},
+			{key: "2.8.0", value: reading.TwoEightZero.String()},
+			{key: "2.8.1", value: reading.TwoEightOne.String()},
+			{key: "2.8.2", value: reading.TwoEightTwo.String()},
+			{key: fieldPower, value: reading.Power.String()},
+			{key: fieldStatus, value: strconv.Itoa(int(reading.Status)), integer: true},
+		},
+		time: reading.Timestamp,
+	}, nil
+}
+
+/*
+momentaryPoint converts the momentary values. The tariff registers are written as x.8.1 to x.8.9.
+*/
+func momentaryPoint(values dvlirclient.MomentaryValues, at time.Time) (point, error) {
+	momentary, err := values.Momentary()
+	if err != nil {
+		return point{}, errors.Wrap(err, "invalid momentary values")
+	}
+	p := point{
+		tags: []tag{{tagMeterNumber, momentary.MeterNumber}},
+		fields: []field{
+			{key: fieldPower, value: momentary.MomentaryPower.String()},
+			{key: "1.8.0", value: momentary.MeterReadingAP.String()},
+			{key: "2.8.0", value: momentary.MeterReadingAM.String()},
+		},
+		time: at,
+	}
+	for i, energy := range momentary.MeterReadingsAP {
+		p.fields = append(p.fields, field{key: "1.8." + strconv.Itoa(i+1), value: energy.String()})
+	}
+	for i, energy := range momentary.MeterReadingsAM {
+		p.fields = append(p.fields, field{key: "2.8." + strconv.Itoa(i+1), value: energy.String()})
+	}
+	p.fields = append(p.fields, field{key: fieldStatus, value: strconv.Itoa(int(momentary.Status)), integer: true})
+	return p, nil
+}
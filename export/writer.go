@@ -0,0 +1,163 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"github.com/go-resty/resty/v2"
+	"github.com/pkg/errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+Writer posts line protocol batches to an HTTP endpoint like the InfluxDB /write (v1) or /api/v2/write (v2) endpoint.
+
+Failed requests are retried with exponential backoff if the endpoint is unreachable or answers with status 429 or a
+5xx status. Other errors, e.g. a malformed line, are returned without retry. A Writer must not be copied after first
+use.
+*/
+type Writer struct {
+	//URL of the write endpoint including the database or bucket, e.g. http://localhost:8086/write?db=metering
+	URL string
+	//Precision of the timestamps, it must match the precision of the encoder. The default is time.Second.
+	Precision time.Duration
+	//Token is sent in the Authorization header if set
+	Token string
+	//BatchSize is the maximum number of lines per request, the default is 5000
+	BatchSize int
+	//MaxRetries is the number of retries of a failed request, 0 disables them. NewWriter sets it to 3.
+	MaxRetries int
+	//RetryWait is the wait time before the first retry, it doubles with every retry. The default is one second.
+	RetryWait time.Duration
+
+	once  sync.Once
+	resty *resty.Client
+}
+
+/*
+WriteError is returned if the endpoint rejected a batch
+*/
+type WriteError struct {
+	StatusCode int
+	Status     string
+	Body       string
+}
+
+func (w *WriteError) Error() string {
+	msg := "write error: " + w.Status
+	if w.Body != "" {
+		msg += " // " + w.Body
+	}
+	return msg
+}
+
+/*
+NewWriter creates a writer for the given endpoint
+*/
+func NewWriter(url string) *Writer {
+	return &Writer{URL: url, MaxRetries: 3, resty: resty.New()}
+}
+
+/*
+Write splits the lines into batches and posts them. On error, the lines of the failed batch and all following batches
+were not written.
+*/
+func (w *Writer) Write(ctx context.Context, lines []byte) error {
+	batchSize := w.BatchSize
+	if batchSize <= 0 {
+		batchSize = 5000
+	}
+
+	for len(lines) > 0 {
+		end, n := 0, 0
+		for end < len(lines) && n < batchSize {
+			i := bytes.IndexByte(lines[end:], '\n')
+			if i < 0 {
+				end = len(lines)
+			} else {
+				end += i + 1
+			}
+			n++
+		}
+		if err := w.post(ctx, lines[:end]); err != nil {
+			return err
+		}
+		lines = lines[end:]
+	}
+	return nil
+}
+
+/*
+post posts a single batch and retries it if necessary
+*/
+func (w *Writer) post(ctx context.Context, batch []byte) error {
+	precision, err := precisionParam(w.Precision, w.v2())
+	if err != nil {
+		return err
+	}
+	wait := w.RetryWait
+	if wait <= 0 {
+		wait = time.Second
+	}
+	//Writers that weren't created with NewWriter get their client on first use
+	w.once.Do(func() {
+		if w.resty == nil {
+			w.resty = resty.New()
+		}
+	})
+
+	for attempt := 0; ; attempt++ {
+		request := w.resty.R().
+			SetContext(ctx).
+			SetHeader("Content-Type", "text/plain; charset=utf-8").
+			SetQueryParam("precision", precision).
+			SetBody(batch)
+		if w.Token != "" {
+			request.SetHeader("Authorization", "Token "+w.Token)
+		}
+
+		var response *resty.Response
+		response, err = request.Post(w.URL)
+		if err == nil {
+			if response.IsSuccess() {
+				return nil
+			}
+			err = &WriteError{
+				StatusCode: response.StatusCode(),
+				Status:     response.Status(),
+				Body:       response.String(),
+			}
+			if !retryable(response.StatusCode()) {
+				return err
+			}
+		}
+		if ctx.Err() != nil || attempt >= w.MaxRetries {
+			return errors.Wrap(err, "Error during write request after "+strconv.Itoa(attempt+1)+" attempts")
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return errors.Wrap(ctx.Err(), "Error during write request")
+		case <-timer.C:
+		}
+		wait *= 2
+	}
+}
+
+/*
+v2 returns true if the URL is an InfluxDB v2 endpoint like /api/v2/write
+*/
+func (w *Writer) v2() bool {
+	u, err := url.Parse(w.URL)
+	return err == nil && strings.Contains(u.Path, "/api/v2/")
+}
+
+func retryable(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode >= 500
+}
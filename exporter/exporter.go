@@ -0,0 +1,367 @@
+/*
+Package exporter serves the readings of DvLIR adapters in the Prometheus text exposition format.
+
+The exporter supports the multi-target pattern: /probe?target=<device name> scrapes a single device of the inventory
+and /metrics scrapes every device of the inventory. Addresses outside the inventory are only probed if they are part of
+Options.AllowedNetworks. The sessions of the adapters are reused between scrapes.
+*/
+package exporter
+
+import (
+	"context"
+	dvlirclient "github.com/inexio/dvlir-restapi-go-client"
+	"github.com/inexio/dvlir-restapi-go-client/fleet"
+	"github.com/pkg/errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Content type of the Prometheus text exposition format
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+/*
+Options configures an Exporter
+*/
+type Options struct {
+	//Inventory lists the devices scraped by /metrics. Probe targets matching a device name use its address and
+	//credentials.
+	Inventory *fleet.Inventory
+	//AllowedNetworks are the networks of the IP addresses which may be probed although they are not part of the
+	//inventory. By default only devices of the inventory are probed.
+	AllowedNetworks []*net.IPNet
+	//Password is used for probe targets in AllowedNetworks, it is never sent to any other address
+	Password string
+	//MaxTargets limits the number of probe targets outside the inventory whose sessions are kept, the least recently
+	//scraped target is logged out if the limit is reached. The default is 64.
+	MaxTargets int
+	//Concurrency limits the number of adapters /metrics scrapes in parallel, the default is 16
+	Concurrency int
+	//Timeout limits a single scrape of an adapter, the default is 10 seconds
+	Timeout time.Duration
+	//ClientOptions are passed to NewDvLIRClient
+	ClientOptions []dvlirclient.Option
+	//NewAdapter creates the adapter of a target. By default a DvLIRClient with session renewal is created.
+	NewAdapter func(address, password string, opts ...dvlirclient.Option) (dvlirclient.Adapter, error)
+}
+
+/*
+Exporter scrapes DvLIR adapters and serves their readings as Prometheus metrics
+*/
+type Exporter struct {
+	opts Options
+
+	mu      sync.Mutex
+	targets map[string]*target
+	//probed are the targets outside the inventory
+	probed int
+}
+
+/*
+target is a scraped adapter. Scrapes of the same target are serialized, so they share one session.
+*/
+type target struct {
+	mu       sync.Mutex
+	adapter  dvlirclient.Adapter
+	loggedIn bool
+	errors   int
+	//probed is true if the target isn't part of the inventory
+	probed   bool
+	lastUsed time.Time
+}
+
+/*
+New creates an exporter
+*/
+func New(opts Options) (*Exporter, error) {
+	if opts.Inventory != nil {
+		if err := opts.Inventory.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	if opts.MaxTargets <= 0 {
+		opts.MaxTargets = 64
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 16
+	}
+	if opts.NewAdapter == nil {
+		opts.NewAdapter = newClient
+	}
+	return &Exporter{opts: opts, targets: make(map[string]*target)}, nil
+}
+
+func newClient(address, password string, opts ...dvlirclient.Option) (dvlirclient.Adapter, error) {
+	opts = append([]dvlirclient.Option{dvlirclient.WithSessionRenewal()}, opts...)
+	return dvlirclient.NewDvLIRClient(address, password, opts...)
+}
+
+/*
+Handler returns a handler serving /metrics and /probe
+*/
+func (e *Exporter) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", e.Metrics)
+	mux.HandleFunc("/probe", e.Probe)
+	return mux
+}
+
+/*
+Probe scrapes the adapter given by the target query parameter. Targets which are neither a device of the inventory
+nor an IP address in AllowedNetworks are rejected.
+*/
+func (e *Exporter) Probe(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("target")
+	if name == "" {
+		http.Error(w, "target parameter is missing", http.StatusBadRequest)
+		return
+	}
+	device, probed, ok := e.device(name)
+	if !ok {
+		http.Error(w, "target isn't part of the inventory or the allowed networks", http.StatusForbidden)
+		return
+	}
+	fams := e.scrape(r.Context(), device, probed)
+	w.Header().Set("Content-Type", contentType)
+	_ = fams.write(w)
+}
+
+/*
+Metrics scrapes every device of the inventory
+*/
+func (e *Exporter) Metrics(w http.ResponseWriter, r *http.Request) {
+	var devices []fleet.Device
+	if e.opts.Inventory != nil {
+		devices = e.opts.Inventory.Devices
+	}
+
+	results := make([]*families, len(devices))
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, e.opts.Concurrency)
+	for i, device := range devices {
+		select {
+		case slots <- struct{}{}:
+		case <-r.Context().Done():
+			//The scrape was canceled, the remaining devices are left out
+			continue
+		}
+		wg.Add(1)
+		go func(i int, device fleet.Device) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			results[i] = e.scrape(r.Context(), device, false)
+		}(i, device)
+	}
+	wg.Wait()
+
+	fams := &families{}
+	for _, result := range results {
+		if result != nil {
+			fams.merge(result)
+		}
+	}
+	w.Header().Set("Content-Type", contentType)
+	_ = fams.write(w)
+}
+
+/*
+device returns the inventory device with the given name or a device for an address in AllowedNetworks. probed is true
+for the latter, ok is false if the target is neither.
+*/
+func (e *Exporter) device(name string) (device fleet.Device, probed bool, ok bool) {
+	if e.opts.Inventory != nil {
+		for _, device := range e.opts.Inventory.Devices {
+			if device.Name == name {
+				return device, false, true
+			}
+		}
+	}
+	if !e.allowed(name) {
+		return fleet.Device{}, false, false
+	}
+	return fleet.Device{Name: name, Address: name, Password: e.opts.Password}, true, true
+}
+
+/*
+allowed returns true if the address is an IP address in AllowedNetworks. Host names are rejected, so a name can't be
+resolved to an address outside the allowed networks.
+*/
+func (e *Exporter) allowed(address string) bool {
+	if !strings.Contains(address, "://") {
+		address = "http://" + address
+	}
+	u, err := url.Parse(address)
+	if err != nil || u.Scheme != "http" && u.Scheme != "https" || u.Path != "" && u.Path != "/" {
+		return false
+	}
+	ip := net.ParseIP(u.Hostname())
+	if ip == nil {
+		return false
+	}
+	for _, network := range e.opts.AllowedNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+scrape reads the values of a device and converts them into metrics
+*/
+func (e *Exporter) scrape(ctx context.Context, device fleet.Device, probed bool) *families {
+	e.mu.Lock()
+	t, ok := e.targets[device.Name]
+	if !ok {
+		if probed && e.probed >= e.opts.MaxTargets {
+			e.evict()
+		}
+		t = &target{probed: probed}
+		e.targets[device.Name] = t
+		if probed {
+			e.probed++
+		}
+	}
+	t.lastUsed = time.Now()
+	e.mu.Unlock()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, e.opts.Timeout)
+	defer cancel()
+
+	fams := &families{}
+	start := time.Now()
+	err := t.collect(ctx, e, device, fams)
+	duration := time.Since(start)
+
+	up := "1"
+	if err != nil {
+		up = "0"
+		t.errors++
+	}
+	name := label{"target", device.Name}
+	fams.add("dvlir_up", "Whether the last scrape of the adapter was successful", gauge, up, name)
+	fams.add("dvlir_scrape_duration_seconds", "Duration of the last scrape of the adapter", gauge,
+		formatFloat(duration.Seconds()), name)
+	fams.add("dvlir_scrape_errors_total", "Number of failed scrapes of the adapter", counter,
+		strconv.Itoa(t.errors), name)
+	return fams
+}
+
+/*
+evict removes the least recently scraped target outside the inventory and logs it out. e.mu has to be held.
+*/
+func (e *Exporter) evict() {
+	var name string
+	var oldest *target
+	for n, t := range e.targets {
+		if t.probed && (oldest == nil || t.lastUsed.Before(oldest.lastUsed)) {
+			name, oldest = n, t
+		}
+	}
+	if oldest == nil {
+		return
+	}
+	delete(e.targets, name)
+	e.probed--
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), e.opts.Timeout)
+		defer cancel()
+		oldest.logout(ctx)
+	}()
+}
+
+/*
+logout logs out of the adapter if it is logged in
+*/
+func (t *target) logout(ctx context.Context) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.loggedIn {
+		_ = t.adapter.LogoutContext(ctx)
+		t.loggedIn = false
+	}
+}
+
+/*
+collect adds the metrics of the adapter, no metrics are added if a request fails
+*/
+func (t *target) collect(ctx context.Context, e *Exporter, device fleet.Device, fams *families) error {
+	if t.adapter == nil {
+		adapter, err := e.opts.NewAdapter(device.Address, device.Credentials(), e.opts.ClientOptions...)
+		if err != nil {
+			return errors.Wrap(err, "Error while creating client")
+		}
+		t.adapter = adapter
+	}
+	if !t.loggedIn {
+		if err := t.adapter.LoginContext(ctx); err != nil {
+			return errors.Wrap(err, "Error during login")
+		}
+		t.loggedIn = true
+	}
+
+	info, err := t.adapter.GetGeneralInformationContext(ctx)
+	if err != nil {
+		return err
+	}
+	values, err := t.adapter.GetMomentaryValuesContext(ctx)
+	if err != nil {
+		return err
+	}
+	momentary, err := values.Momentary()
+	if err != nil {
+		return err
+	}
+
+	name := label{"target", device.Name}
+	meter := label{"meter_number", momentary.MeterNumber}
+	fams.add("dvlir_info", "Information about the adapter", gauge, "1", name,
+		label{"device_sn", info.DeviceSn},
+		label{"mac_address", info.MACAddress},
+		label{"firmware_version", info.FirmwareVersion},
+		meter,
+	)
+	fams.add("dvlir_power_watts", "Momentary power, negative values mean that energy is fed into the grid", gauge,
+		momentary.MomentaryPower.String(), name, meter)
+	addEnergy(fams, name, meter, "import", "1.8", momentary.MeterReadingAP, momentary.MeterReadingsAP)
+	addEnergy(fams, name, meter, "export", "2.8", momentary.MeterReadingAM, momentary.MeterReadingsAM)
+	fams.add("dvlir_status", "Status word of the adapter, 0 means that no status flag is set", gauge,
+		strconv.Itoa(int(momentary.Status)), name, meter)
+	return nil
+}
+
+/*
+addEnergy adds the total register (x.8.0) and the tariff registers (x.8.1 to x.8.9) of one energy direction
+*/
+func addEnergy(fams *families, name, meter label, direction, obis string, total dvlirclient.Energy,
+	tariffs [9]dvlirclient.Energy) {
+	const help = "Meter reading of the energy register"
+	fams.add("dvlir_energy_watthours_total", help, counter, total.String(), name, meter,
+		label{"direction", direction}, label{"obis", obis + ".0"}, label{"tariff", "0"})
+	for i, energy := range tariffs {
+		tariff := strconv.Itoa(i + 1)
+		fams.add("dvlir_energy_watthours_total", help, counter, energy.String(), name, meter,
+			label{"direction", direction}, label{"obis", obis + "." + tariff}, label{"tariff", tariff})
+	}
+}
+
+/*
+Close logs out of every adapter that is logged in
+*/
+func (e *Exporter) Close(ctx context.Context) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, t := range e.targets {
+		t.logout(ctx)
+	}
+}
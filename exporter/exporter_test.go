@@ -0,0 +1,189 @@
+package exporter
+
+import (
+	"context"
+	dvlirclient "github.com/inexio/dvlir-restapi-go-client"
+	"github.com/inexio/dvlir-restapi-go-client/dvlirtest"
+	"github.com/inexio/dvlir-restapi-go-client/fleet"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func get(t *testing.T, handler http.Handler, path string) (int, string) {
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+	body, err := ioutil.ReadAll(recorder.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return recorder.Code, string(body)
+}
+
+func allowLoopback(t *testing.T) []*net.IPNet {
+	_, loopback, err := net.ParseCIDR("127.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return []*net.IPNet{loopback}
+}
+
+/*
+TestExporter_Probe covers:
+	- Probe of an address in the allowed networks
+	- Probe without a target
+	- Probe of an address and a host name outside the allowed networks
+	- Logout of the least recently scraped target if MaxTargets is reached
+	- Reuse of the session between scrapes
+	- Label value escaping
+*/
+func TestExporter_Probe(t *testing.T) {
+	state := dvlirtest.DefaultState()
+	state.FirmwareVersion = `1.0 "beta"`
+	server := dvlirtest.NewServer(dvlirtest.WithState(state))
+	defer server.Close()
+
+	exp, err := New(Options{AllowedNetworks: allowLoopback(t), Password: server.Password(), MaxTargets: 1})
+	if !assert.NoError(t, err, "Error while creating exporter") {
+		return
+	}
+	handler := exp.Handler()
+	target := "target=" + url.QueryEscape(server.Address())
+
+	code, _ := get(t, handler, "/probe")
+	assert.Equal(t, http.StatusBadRequest, code)
+	for _, forbidden := range []string{"10.0.0.1", "localhost:" + strings.Split(server.Address(), ":")[1]} {
+		code, _ = get(t, handler, "/probe?target="+url.QueryEscape(forbidden))
+		assert.Equal(t, http.StatusForbidden, code, forbidden+" wasn't rejected")
+	}
+	assert.Equal(t, 0, server.Logins(), "Password was sent to a forbidden target")
+
+	code, body := get(t, handler, "/probe?"+target)
+	if !assert.Equal(t, http.StatusOK, code) {
+		return
+	}
+	name := `target="` + server.Address() + `"`
+	assert.Contains(t, body, "# TYPE dvlir_energy_watthours_total counter\n")
+	assert.Contains(t, body, "# TYPE dvlir_power_watts gauge\n")
+	assert.Contains(t, body, "dvlir_up{"+name+"} 1\n")
+	assert.Contains(t, body, "dvlir_scrape_errors_total{"+name+"} 0\n")
+	assert.Contains(t, body, `firmware_version="1.0 \"beta\""`)
+	assert.Contains(t, body, `mac_address="`+state.MACAddress+`"`)
+	assert.Contains(t, body, `direction="import",obis="1.8.0",tariff="0"}`)
+	assert.Contains(t, body, `direction="export",obis="2.8.9",tariff="9"} 0.000`+"\n")
+	assert.Equal(t, 1, strings.Count(body, "# TYPE dvlir_energy_watthours_total"), "Family was written twice")
+
+	_, body = get(t, handler, "/probe?"+target)
+	assert.Contains(t, body, "dvlir_up{"+name+"} 1\n")
+	assert.Equal(t, 1, server.Logins(), "Session wasn't reused")
+
+	server.ExpireSession()
+	_, body = get(t, handler, "/probe?"+target)
+	assert.Contains(t, body, "dvlir_up{"+name+"} 1\n", "Expired session wasn't renewed")
+
+	other := dvlirtest.NewServer(dvlirtest.WithState(state))
+	defer other.Close()
+	_, body = get(t, handler, "/probe?target="+url.QueryEscape(other.Address()))
+	assert.Contains(t, body, `dvlir_up{target="`+other.Address()+`"} 1`+"\n")
+	deadline := time.Now().Add(2 * time.Second)
+	for server.SessionID() != "" && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Empty(t, server.SessionID(), "Least recently scraped target wasn't logged out")
+}
+
+/*
+TestExporter_Metrics covers:
+	- Metrics of all inventory devices with an unreachable device
+	- Probe of an inventory device by name
+	- Scrape errors
+*/
+func TestExporter_Metrics(t *testing.T) {
+	server := dvlirtest.NewServer()
+	defer server.Close()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unreachable := listener.Addr().String()
+	listener.Close()
+
+	inventory := &fleet.Inventory{Devices: []fleet.Device{
+		{Name: "a", Address: server.Address(), Password: server.Password()},
+		{Name: "b", Address: unreachable, Password: "pw"},
+	}}
+	exp, err := New(Options{Inventory: inventory, Timeout: 2 * time.Second})
+	if !assert.NoError(t, err, "Error while creating exporter") {
+		return
+	}
+	handler := exp.Handler()
+
+	_, body := get(t, handler, "/metrics")
+	assert.Contains(t, body, `dvlir_up{target="a"} 1`+"\n")
+	assert.Contains(t, body, `dvlir_up{target="b"} 0`+"\n")
+	assert.Contains(t, body, `dvlir_power_watts{target="a",meter_number=`)
+	assert.NotContains(t, body, `dvlir_power_watts{target="b"`)
+	assert.Equal(t, 1, strings.Count(body, "# TYPE dvlir_up gauge"), "Family was written twice")
+
+	_, body = get(t, handler, "/probe?target=b")
+	assert.Contains(t, body, `dvlir_scrape_errors_total{target="b"} 2`+"\n")
+	_, body = get(t, handler, "/probe?target=a")
+	assert.Contains(t, body, `dvlir_up{target="a"} 1`+"\n")
+}
+
+/*
+TestExporter_Concurrency covers:
+	- Metrics scrapes at most Concurrency adapters in parallel
+*/
+func TestExporter_Concurrency(t *testing.T) {
+	inventory := &fleet.Inventory{}
+	for _, name := range []string{"a", "b", "c", "d"} {
+		server := dvlirtest.NewServer()
+		defer server.Close()
+		server.Inject(dvlirtest.Rule{Endpoint: "/data.txt", Fault: dvlirtest.Latency(20 * time.Millisecond)})
+		inventory.Devices = append(inventory.Devices, fleet.Device{Name: name, Address: server.Address(), Password: server.Password()})
+	}
+
+	var mu sync.Mutex
+	running, max := 0, 0
+	count := func(ctx context.Context, operation string, next func(ctx context.Context) error) error {
+		mu.Lock()
+		running++
+		if running > max {
+			max = running
+		}
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			running--
+			mu.Unlock()
+		}()
+		return next(ctx)
+	}
+	exp, err := New(Options{
+		Inventory:   inventory,
+		Concurrency: 2,
+		NewAdapter: func(address, password string, opts ...dvlirclient.Option) (dvlirclient.Adapter, error) {
+			client, err := dvlirclient.NewDvLIRClient(address, password, opts...)
+			if err != nil {
+				return nil, err
+			}
+			return dvlirclient.Wrap(client, count), nil
+		},
+	})
+	if !assert.NoError(t, err, "Error while creating exporter") {
+		return
+	}
+
+	_, body := get(t, exp.Handler(), "/metrics")
+	assert.Equal(t, 4, strings.Count(body, "dvlir_up{"), "Not every device was scraped")
+	assert.Equal(t, 2, max, "Concurrency wasn't limited")
+}
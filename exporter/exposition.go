@@ -0,0 +1,113 @@
+package exporter
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Types of the Prometheus text exposition format
+const (
+	gauge   = "gauge"
+	counter = "counter"
+)
+
+/*
+label is a label of a sample
+*/
+type label struct {
+	name  string
+	value string
+}
+
+/*
+sample is a single value of a metric family. The value is kept as a string, so fixed-point readings are exposed
+exactly.
+*/
+type sample struct {
+	labels []label
+	value  string
+}
+
+/*
+family is a metric with its HELP and TYPE lines and the samples of all targets
+*/
+type family struct {
+	name    string
+	help    string
+	typ     string
+	samples []sample
+}
+
+/*
+families collects the samples of a scrape in the order the metrics were first added
+*/
+type families struct {
+	order  []*family
+	byName map[string]*family
+}
+
+func (f *families) add(name, help, typ, value string, labels ...label) {
+	if f.byName == nil {
+		f.byName = make(map[string]*family)
+	}
+	fam, ok := f.byName[name]
+	if !ok {
+		fam = &family{name: name, help: help, typ: typ}
+		f.byName[name] = fam
+		f.order = append(f.order, fam)
+	}
+	fam.samples = append(fam.samples, sample{labels: labels, value: value})
+}
+
+func (f *families) merge(other *families) {
+	for _, fam := range other.order {
+		for _, s := range fam.samples {
+			f.add(fam.name, fam.help, fam.typ, s.value, s.labels...)
+		}
+	}
+}
+
+/*
+write writes the families in the Prometheus text exposition format
+*/
+func (f *families) write(w io.Writer) error {
+	b := bufio.NewWriter(w)
+	for _, fam := range f.order {
+		b.WriteString("# HELP " + fam.name + " " + escapeHelp(fam.help) + "\n")
+		b.WriteString("# TYPE " + fam.name + " " + fam.typ + "\n")
+		for _, s := range fam.samples {
+			b.WriteString(fam.name)
+			if len(s.labels) > 0 {
+				b.WriteByte('{')
+				for i, l := range s.labels {
+					if i > 0 {
+						b.WriteByte(',')
+					}
+					b.WriteString(l.name + "=\"" + escapeLabelValue(l.value) + "\"")
+				}
+				b.WriteByte('}')
+			}
+			b.WriteString(" " + s.value + "\n")
+		}
+	}
+	return b.Flush()
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(help string) string {
+	return helpEscaper.Replace(help)
+}
+
+func escapeLabelValue(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,210 @@
+package dvlirclient
+
+import (
+	"github.com/inexio/dvlir-restapi-go-client/dvlirtest"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+	"time"
+)
+
+/*
+newSimulatedClient starts a simulated adapter and returns a logged in client for it
+*/
+func newSimulatedClient(t *testing.T, opts ...dvlirtest.Option) (*DvLIRClient, *dvlirtest.Server) {
+	server := dvlirtest.NewServer(opts...)
+	dvlirClient, err := NewDvLIRClient(server.Address(), server.Password())
+	if err != nil {
+		server.Close()
+		t.Fatal(err)
+	}
+	if err = dvlirClient.Login(); err != nil {
+		server.Close()
+		t.Fatal(err)
+	}
+	return dvlirClient, server
+}
+
+/*
+TestFaults_DroppedConnection covers:
+	- GetMomentaryValues with a dropped connection
+*/
+func TestFaults_DroppedConnection(t *testing.T) {
+	dvlirClient, server := newSimulatedClient(t)
+	defer server.Close()
+
+	server.Inject(dvlirtest.Rule{Endpoint: "/data.txt", Nth: 2, Fault: dvlirtest.Drop()})
+
+	_, err := dvlirClient.GetMomentaryValues()
+	if !assert.NoError(t, err, "First request was affected") {
+		return
+	}
+	_, err = dvlirClient.GetMomentaryValues()
+	if !assert.Error(t, err, "Dropped connection wasn't reported") {
+		return
+	}
+	_, err = dvlirClient.GetMomentaryValues()
+	assert.NoError(t, err, "Third request was affected")
+}
+
+/*
+TestFaults_DroppedResponse covers:
+	- ChangeSavingInterval that is executed, but whose response is lost
+*/
+func TestFaults_DroppedResponse(t *testing.T) {
+	dvlirClient, server := newSimulatedClient(t)
+	defer server.Close()
+
+	server.Inject(dvlirtest.Rule{Endpoint: "/system.cmd", Nth: 1, Fault: dvlirtest.DropResponse()})
+
+	_, err := dvlirClient.ChangeSavingInterval(SavingIntervalMinute)
+	assert.Error(t, err, "Dropped response wasn't reported")
+	assert.Equal(t, "min", server.State().SavingInterval, "Request wasn't executed")
+}
+
+/*
+TestFaults_HTTPError covers:
+	- GetGeneralInformation with a non-200 status
+	- NTPServerTest with a non-200 status
+*/
+func TestFaults_HTTPError(t *testing.T) {
+	dvlirClient, server := newSimulatedClient(t)
+	defer server.Close()
+
+	server.Inject(dvlirtest.Rule{Fault: dvlirtest.Status(http.StatusInternalServerError, "flash error")})
+
+	_, err := dvlirClient.GetGeneralInformation()
+	httpError, ok := errors.Cause(err).(HTTPError)
+	if !assert.True(t, ok, "HTTPError wasn't returned") {
+		return
+	}
+	assert.Equal(t, http.StatusInternalServerError, httpError.StatusCode)
+	if assert.NotNil(t, httpError.Body, "Error response wasn't parsed") {
+		assert.Equal(t, "flash error", httpError.Body.Message)
+	}
+
+	_, err = dvlirClient.NTPServerTest("de.pool.ntp.org")
+	assert.Error(t, err, "HTTP error wasn't reported")
+}
+
+/*
+TestFaults_LoginPage covers:
+	- Login with an empty response
+	- GetNetworkInformation with the login page instead of data
+	- ChangeSavingInterval with the login page instead of data
+*/
+func TestFaults_LoginPage(t *testing.T) {
+	dvlirClient, server := newSimulatedClient(t)
+	defer server.Close()
+
+	server.Inject(
+		dvlirtest.Rule{Endpoint: "/getSID.txt", Times: 1, Fault: dvlirtest.Truncate(0)},
+		dvlirtest.Rule{Endpoint: "/network.txt", Fault: dvlirtest.ServeLoginPage()},
+		dvlirtest.Rule{Endpoint: "/system.cmd", Fault: dvlirtest.ServeLoginPage()},
+	)
+
+	err := dvlirClient.Login()
+	assert.Error(t, err, "Empty response wasn't reported")
+
+	_, err = dvlirClient.GetNetworkInformation()
+	assert.EqualError(t, err, "Login page was returned")
+
+	_, err = dvlirClient.ChangeSavingInterval("min")
+	assert.EqualError(t, err, "Login page was returned")
+	assert.Equal(t, "15min", server.State().SavingInterval)
+}
+
+/*
+TestFaults_Reboot covers:
+	- GetSystemInformation while the adapter reboots
+	- GetSystemInformation after the adapter rebooted
+*/
+func TestFaults_Reboot(t *testing.T) {
+	dvlirClient, server := newSimulatedClient(t)
+	defer server.Close()
+
+	server.Inject(dvlirtest.Rule{Endpoint: "/system.txt", Times: 1, Fault: dvlirtest.Reboot()})
+
+	_, err := dvlirClient.GetSystemInformation()
+	if !assert.Error(t, err, "Reboot wasn't reported") {
+		return
+	}
+	assert.Equal(t, 1, server.Reboots(), "Adapter wasn't restarted")
+
+	_, err = dvlirClient.GetSystemInformation()
+	assert.EqualError(t, err, "Login page was returned")
+}
+
+/*
+TestFaults_Latency covers:
+	- Blink with a delayed response
+*/
+func TestFaults_Latency(t *testing.T) {
+	dvlirClient, server := newSimulatedClient(t)
+	defer server.Close()
+
+	server.Inject(dvlirtest.Rule{Endpoint: "/blink.cmd", Fault: dvlirtest.Latency(50 * time.Millisecond)})
+
+	start := time.Now()
+	code, err := dvlirClient.Blink(500, 10)
+	if !assert.NoError(t, err, "Error during Blink request") {
+		return
+	}
+	assert.Equal(t, 123, code)
+	assert.True(t, time.Since(start) >= 50*time.Millisecond, "Response wasn't delayed")
+}
+
+/*
+TestFaults_MalformedResponses covers:
+	- Login, GetDataFile, GetMomentaryValues, GetGeneralInformation, GetNetworkInformation, GetSystemInformation,
+	  Blink, NTPServerTest, ChangeSavingInterval and Restart with too few fields and truncated responses
+*/
+func TestFaults_MalformedResponses(t *testing.T) {
+	dvlirClient, server := newSimulatedClient(t)
+	defer server.Close()
+
+	for _, fault := range []dvlirtest.Fault{dvlirtest.TooFewFields(2), dvlirtest.Truncate(3), dvlirtest.Truncate(0)} {
+		server.ClearFaults()
+		server.Inject(dvlirtest.Rule{Fault: fault})
+
+		_, err := dvlirClient.GetMomentaryValues()
+		assert.Error(t, err, "Malformed momentary values weren't reported")
+		_, err = dvlirClient.GetGeneralInformation()
+		assert.Error(t, err, "Malformed general information wasn't reported")
+		_, err = dvlirClient.GetNetworkInformation()
+		assert.Error(t, err, "Malformed network information wasn't reported")
+		_, err = dvlirClient.GetSystemInformation()
+		assert.Error(t, err, "Malformed system information wasn't reported")
+
+		var parseError *ParseError
+		_, err = dvlirClient.GetMomentaryValues()
+		if assert.True(t, errors.As(err, &parseError), "ParseError wasn't returned") {
+			assert.Equal(t, "/data.txt", parseError.Endpoint)
+		}
+
+		//Short responses of commands must not cause a panic
+		_, _ = dvlirClient.Blink(500, 10)
+		_, _ = dvlirClient.NTPServerTest("de.pool.ntp.org")
+		_, _ = dvlirClient.ChangeSavingInterval("min")
+		_, _ = dvlirClient.GetDataFile(10)
+		_ = dvlirClient.Login()
+	}
+
+	server.ClearFaults()
+	err := dvlirClient.Login()
+	if !assert.NoError(t, err, "Error during Login") {
+		return
+	}
+
+	server.Inject(dvlirtest.Rule{Endpoint: "/daten.csv", Fault: dvlirtest.TooFewFields(12)})
+	_, err = dvlirClient.GetDataFile(10)
+	var parseError *ParseError
+	if assert.True(t, errors.As(err, &parseError), "ParseError wasn't returned") {
+		assert.Equal(t, 1, parseError.Line)
+		assert.Equal(t, 12, parseError.Field)
+	}
+
+	server.Inject(dvlirtest.Rule{Endpoint: "/doReset.cmd", Fault: dvlirtest.Truncate(2)})
+	_, _ = dvlirClient.Restart()
+}
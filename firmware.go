@@ -0,0 +1,300 @@
+package dvlirclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Errors returned by UploadFirmwareFrom
+var (
+	//ErrInvalidFirmware is returned if the firmware image failed the validation before the upload
+	ErrInvalidFirmware = errors.New("Firmware image is invalid")
+	//ErrFirmwareVersionMismatch is returned if the adapter reports a different firmware version after the upgrade
+	ErrFirmwareVersionMismatch = errors.New("Adapter reports a different firmware version")
+)
+
+/*
+FirmwareManifest describes a firmware image, e.g. as published next to the image:
+
+	{"name": "dvlir-1.22.bin", "version": "1.22", "size": 262144, "sha256": "9f86d08..."}
+*/
+type FirmwareManifest struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Size    int64  `json:"size"`
+	SHA256  string `json:"sha256"`
+}
+
+/*
+ParseFirmwareManifest reads a FirmwareManifest in JSON format
+*/
+func ParseFirmwareManifest(r io.Reader) (*FirmwareManifest, error) {
+	var manifest FirmwareManifest
+	if err := json.NewDecoder(r).Decode(&manifest); err != nil {
+		return nil, errors.Wrap(err, "Error while parsing firmware manifest")
+	}
+	if manifest.SHA256 != "" {
+		if _, err := hex.DecodeString(manifest.SHA256); err != nil || len(manifest.SHA256) != 2*sha256.Size {
+			return nil, errors.New("Invalid checksum in firmware manifest: " + manifest.SHA256)
+		}
+	}
+	return &manifest, nil
+}
+
+/*
+FirmwareOption configures UploadFirmwareFrom
+*/
+type FirmwareOption func(*firmwareOptions)
+
+type firmwareOptions struct {
+	progress        func(sent, total int64)
+	maxSize         int64
+	magic           []byte
+	manifest        *FirmwareManifest
+	expectedVersion string
+	rebootTimeout   time.Duration
+	pollInterval    time.Duration
+}
+
+/*
+WithUploadProgress sets a function which is called with the number of bytes of the image read into the upload request.
+If resty retries the upload, the progress starts over.
+*/
+func WithUploadProgress(progress func(sent, total int64)) FirmwareOption {
+	return func(o *firmwareOptions) {
+		o.progress = progress
+	}
+}
+
+/*
+WithMaxFirmwareSize sets the size of the largest accepted image, by default the size isn't limited
+*/
+func WithMaxFirmwareSize(size int64) FirmwareOption {
+	return func(o *firmwareOptions) {
+		o.maxSize = size
+	}
+}
+
+/*
+WithFirmwareMagic rejects images which don't start with the given bytes
+*/
+func WithFirmwareMagic(magic []byte) FirmwareOption {
+	return func(o *firmwareOptions) {
+		o.magic = magic
+	}
+}
+
+/*
+WithFirmwareManifest rejects images whose size or checksum differ from the manifest. The version of the manifest is
+expected after the upgrade unless WithExpectedFirmwareVersion is given.
+*/
+func WithFirmwareManifest(manifest *FirmwareManifest) FirmwareOption {
+	return func(o *firmwareOptions) {
+		o.manifest = manifest
+	}
+}
+
+/*
+WithExpectedFirmwareVersion sets the firmware version the adapter has to report after the upgrade
+*/
+func WithExpectedFirmwareVersion(version string) FirmwareOption {
+	return func(o *firmwareOptions) {
+		o.expectedVersion = version
+	}
+}
+
+/*
+WithRebootTimeout sets how long to wait for the adapter to come back after the upload and how often to check, the
+defaults are 2 minutes and 2 seconds
+*/
+func WithRebootTimeout(timeout, pollInterval time.Duration) FirmwareOption {
+	return func(o *firmwareOptions) {
+		o.rebootTimeout = timeout
+		o.pollInterval = pollInterval
+	}
+}
+
+/*
+FirmwareUpgradeReport describes a firmware upgrade by UploadFirmwareFrom
+*/
+type FirmwareUpgradeReport struct {
+	//Name and Size of the uploaded image
+	Name string
+	Size int64
+	//SHA256 is the checksum of the uploaded image
+	SHA256 string
+	//PreviousVersion is the firmware version before the upload
+	PreviousVersion string
+	//ExpectedVersion is the version expected after the upgrade, empty if no version was expected
+	ExpectedVersion string
+	//Version is the firmware version after the reboot, empty if the adapter didn't come back
+	Version string
+	//Verified is true if the adapter came back with the expected version or, if no version was expected, at all
+	Verified bool
+	//Response is the response of the adapter to the upload
+	Response string
+	//UploadDuration and RebootDuration are the durations of the upload and of the wait for the adapter
+	UploadDuration time.Duration
+	RebootDuration time.Duration
+	//Attempts is the number of login attempts after the upload
+	Attempts int
+}
+
+/*
+UploadFirmwareFrom validates a firmware image, uploads it and waits for the adapter to reboot. Afterwards the firmware
+version reported by the adapter is compared with the expected version.
+
+size is the size of the image as announced by its source, e.g. a file or an http download, -1 means unknown. The image
+is read completely before the upload, so it can be validated. The client has to be logged in, after the upgrade it is
+logged in again.
+*/
+func (d *DvLIRClient) UploadFirmwareFrom(ctx context.Context, name string, r io.Reader, size int64, opts ...FirmwareOption) (*FirmwareUpgradeReport, error) {
+	if !d.isValid() {
+		return nil, &NotValidError{}
+	}
+	options := firmwareOptions{
+		rebootTimeout: 2 * time.Minute,
+		pollInterval:  2 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	image, err := readFirmware(r, size, options)
+	if err != nil {
+		return nil, err
+	}
+	checksum := sha256.Sum256(image)
+	report := &FirmwareUpgradeReport{
+		Name:            name,
+		Size:            int64(len(image)),
+		SHA256:          hex.EncodeToString(checksum[:]),
+		ExpectedVersion: options.expectedVersion,
+	}
+	if options.manifest != nil {
+		if options.manifest.SHA256 != "" && !strings.EqualFold(options.manifest.SHA256, report.SHA256) {
+			return nil, errors.Wrap(ErrInvalidFirmware, "checksum "+report.SHA256+" doesn't match the manifest")
+		}
+		if report.ExpectedVersion == "" {
+			report.ExpectedVersion = options.manifest.Version
+		}
+	}
+
+	info, err := d.GetGeneralInformationContext(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error while reading the firmware version")
+	}
+	report.PreviousVersion = info.FirmwareVersion
+
+	start := time.Now()
+	report.Response, err = d.uploadFirmwareImage(ctx, name, image, options.progress)
+	report.UploadDuration = time.Since(start)
+	if err != nil {
+		return report, err
+	}
+
+	//The adapter is polled at the interval set by WithRebootTimeout
+	attemptTimeout := options.pollInterval
+	if attemptTimeout < time.Second {
+		attemptTimeout = time.Second
+	}
+	ready, err := d.WaitReady(ctx, ReadyOptions{
+		Timeout:         options.rebootTimeout,
+		InitialInterval: options.pollInterval,
+		MaxInterval:     options.pollInterval,
+		AttemptTimeout:  attemptTimeout,
+	})
+	if ready != nil {
+		report.Attempts, report.RebootDuration = ready.Attempts, ready.Elapsed
+	}
+	if err != nil {
+		return report, errors.Wrap(err, "Adapter didn't come back after the upgrade")
+	}
+
+	info, err = d.GetGeneralInformationContext(ctx)
+	if err != nil {
+		return report, errors.Wrap(err, "Error while reading the firmware version")
+	}
+	report.Version = info.FirmwareVersion
+	if report.ExpectedVersion != "" && report.Version != report.ExpectedVersion {
+		return report, errors.Wrap(ErrFirmwareVersionMismatch, "expected "+report.ExpectedVersion+", got "+report.Version)
+	}
+	report.Verified = true
+	return report, nil
+}
+
+/*
+readFirmware reads and validates the image
+*/
+func readFirmware(r io.Reader, size int64, options firmwareOptions) ([]byte, error) {
+	limited := options.maxSize > 0
+	if limited && size > options.maxSize {
+		return nil, errors.Wrap(ErrInvalidFirmware, "size "+strconv.FormatInt(size, 10)+" exceeds "+
+			strconv.FormatInt(options.maxSize, 10)+" bytes")
+	}
+	if limited {
+		r = io.LimitReader(r, options.maxSize+1)
+	}
+	image, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error while reading firmware image")
+	}
+
+	length := int64(len(image))
+	switch {
+	case length == 0:
+		return nil, errors.Wrap(ErrInvalidFirmware, "image is empty")
+	case limited && length > options.maxSize:
+		return nil, errors.Wrap(ErrInvalidFirmware, "image exceeds "+strconv.FormatInt(options.maxSize, 10)+" bytes")
+	case size >= 0 && length != size:
+		return nil, errors.Wrap(ErrInvalidFirmware, "read "+strconv.FormatInt(length, 10)+" bytes, expected "+
+			strconv.FormatInt(size, 10))
+	case options.manifest != nil && options.manifest.Size > 0 && length != options.manifest.Size:
+		return nil, errors.Wrap(ErrInvalidFirmware, "size "+strconv.FormatInt(length, 10)+" doesn't match the manifest")
+	case !bytes.HasPrefix(image, options.magic):
+		return nil, errors.Wrap(ErrInvalidFirmware, "image doesn't start with the expected header")
+	}
+	return image, nil
+}
+
+/*
+uploadFirmwareImage uploads the image with the current session and renews an expired session if enabled
+*/
+func (d *DvLIRClient) uploadFirmwareImage(ctx context.Context, name string, image []byte, progress func(sent, total int64)) (string, error) {
+	upload := func(sessionID string) (string, error) {
+		return d.postFile(ctx, d.baseURL+"/upload.cmd?sid="+url.QueryEscape(sessionID), "firmware", name, image, progress)
+	}
+
+	sessionID := d.session.current()
+	response, err := upload(sessionID)
+	if err == nil && isLoginPage(response) && d.renewSession {
+		sessionID, err = d.session.renew(ctx, sessionID, d.requestSessionID)
+		if err == nil {
+			response, err = upload(sessionID)
+		}
+	}
+	if err != nil {
+		return "", errors.Wrap(err, "Error during UploadFirmware")
+	}
+
+	//The adapter answers a rejected image with an error code, see FirmwareUploadError
+	switch response {
+	case "2", "3", "4", "5":
+		code, _ := strconv.Atoi(response)
+		return "", &FirmwareUploadError{Code: code}
+	}
+	if isLoginPage(response) {
+		return "", ErrSessionExpired
+	}
+	return response, nil
+}
@@ -0,0 +1,152 @@
+package dvlirclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/go-resty/resty/v2"
+	"github.com/inexio/dvlir-restapi-go-client/dvlirtest"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+/*
+TestDvLIRClient_UploadFirmwareFrom covers:
+	- UploadFirmwareFrom with progress, magic, manifest and verification
+	- UploadFirmwareFrom with an unexpected version
+	- UploadFirmwareFrom with an empty, a too large and a truncated image
+	- UploadFirmwareFrom without a size limit
+	- UploadFirmwareFrom with a wrong header and a wrong checksum
+	- UploadFirmware with a path containing spaces
+*/
+func TestDvLIRClient_UploadFirmwareFrom(t *testing.T) {
+	dvlirClient, server := newSimulatedClient(t)
+	defer server.Close()
+	ctx := context.Background()
+	reboot := WithRebootTimeout(5*time.Second, 10*time.Millisecond)
+	magic := WithFirmwareMagic([]byte(dvlirtest.FirmwareMagic))
+
+	image := dvlirtest.FirmwareImage("1.22", bytes.Repeat([]byte{0x5a}, 100*1024))
+	checksum := sha256.Sum256(image)
+	manifest, err := ParseFirmwareManifest(strings.NewReader(`{"name": "dvlir-1.22.bin", "version": "1.22", "size": ` +
+		strconv.Itoa(len(image)) + `, "sha256": "` + hex.EncodeToString(checksum[:]) + `"}`))
+	if !assert.NoError(t, err, "Error while parsing manifest") {
+		return
+	}
+
+	var calls int
+	var sent, total int64
+	progress := WithUploadProgress(func(s, t int64) {
+		calls++
+		sent, total = s, t
+	})
+	report, err := dvlirClient.UploadFirmwareFrom(ctx, "dvlir-1.22.bin", bytes.NewReader(image), int64(len(image)),
+		progress, magic, WithFirmwareManifest(manifest), reboot)
+	if !assert.NoError(t, err, "Error during UploadFirmwareFrom") {
+		return
+	}
+	assert.True(t, report.Verified, "Upgrade wasn't verified")
+	assert.Equal(t, "1.21", report.PreviousVersion)
+	assert.Equal(t, "1.22", report.Version)
+	assert.Equal(t, manifest.SHA256, report.SHA256)
+	assert.Equal(t, 1, server.Reboots())
+	assert.True(t, calls > 0, "Progress wasn't reported")
+	assert.Equal(t, int64(len(image)), sent)
+	assert.Equal(t, int64(len(image)), total)
+
+	image = dvlirtest.FirmwareImage("1.23-rc1", []byte("payload"))
+	report, err = dvlirClient.UploadFirmwareFrom(ctx, "dvlir.bin", bytes.NewReader(image), -1,
+		WithExpectedFirmwareVersion("1.23"), reboot)
+	assert.True(t, errors.Is(err, ErrFirmwareVersionMismatch), "ErrFirmwareVersionMismatch wasn't returned: %v", err)
+	if assert.NotNil(t, report) {
+		assert.False(t, report.Verified)
+		assert.Equal(t, "1.23-rc1", report.Version)
+	}
+
+	for name, upload := range map[string]func() (*FirmwareUpgradeReport, error){
+		"empty": func() (*FirmwareUpgradeReport, error) {
+			return dvlirClient.UploadFirmwareFrom(ctx, "dvlir.bin", bytes.NewReader(nil), -1)
+		},
+		"too large": func() (*FirmwareUpgradeReport, error) {
+			return dvlirClient.UploadFirmwareFrom(ctx, "dvlir.bin", bytes.NewReader(make([]byte, 2048)), -1,
+				WithMaxFirmwareSize(1024))
+		},
+		"truncated": func() (*FirmwareUpgradeReport, error) {
+			return dvlirClient.UploadFirmwareFrom(ctx, "dvlir.bin", bytes.NewReader(image[:10]), int64(len(image)))
+		},
+		"header": func() (*FirmwareUpgradeReport, error) {
+			return dvlirClient.UploadFirmwareFrom(ctx, "dvlir.bin", strings.NewReader("MZ"), -1, magic)
+		},
+		"checksum": func() (*FirmwareUpgradeReport, error) {
+			return dvlirClient.UploadFirmwareFrom(ctx, "dvlir.bin", bytes.NewReader(image), -1,
+				WithFirmwareManifest(&FirmwareManifest{SHA256: strings.Repeat("0", 64)}))
+		},
+	} {
+		_, err = upload()
+		assert.True(t, errors.Is(err, ErrInvalidFirmware), "ErrInvalidFirmware wasn't returned for %s image: %v", name, err)
+	}
+	assert.Equal(t, 2, server.Reboots(), "Invalid image was uploaded")
+
+	//The size isn't limited by default, the simulator rejects the image
+	large := make([]byte, dvlirtest.MaxFirmwareSize+1)
+	_, err = dvlirClient.UploadFirmwareFrom(ctx, "dvlir.bin", bytes.NewReader(large), int64(len(large)))
+	var uploadError *FirmwareUploadError
+	assert.True(t, errors.As(err, &uploadError), "Large image wasn't uploaded: %v", err)
+
+	_, err = ParseFirmwareManifest(strings.NewReader(`{"sha256": "abc"}`))
+	assert.Error(t, err, "Invalid checksum wasn't rejected")
+
+	dir, err := ioutil.TempDir("", "dvlir firmware")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "firmware 1.24.bin")
+	if err = ioutil.WriteFile(path, dvlirtest.FirmwareImage("1.24", []byte("payload")), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if !assert.NoError(t, dvlirClient.Login(), "Error during Login") {
+		return
+	}
+	_, err = dvlirClient.UploadFirmware(path)
+	if assert.NoError(t, err, "Error during UploadFirmware with spaces in the path") {
+		assert.Equal(t, "1.24", server.State().FirmwareVersion)
+	}
+}
+
+/*
+TestDvLIRClient_UploadFirmwareFromRetry covers:
+	- UploadFirmwareFrom sends the complete image again if resty retries the upload
+*/
+func TestDvLIRClient_UploadFirmwareFromRetry(t *testing.T) {
+	server := dvlirtest.NewServer()
+	defer server.Close()
+	dvlirClient, err := NewDvLIRClient(server.Address(), server.Password(),
+		WithRestyClient(resty.New().SetRetryCount(2).SetRetryWaitTime(time.Millisecond)))
+	if !assert.NoError(t, err, "Error while creating client") {
+		return
+	}
+	if !assert.NoError(t, dvlirClient.Login(), "Error during Login") {
+		return
+	}
+	server.Inject(dvlirtest.Rule{Endpoint: "/upload.cmd", Nth: 1, Fault: dvlirtest.Drop()})
+
+	var sent int64
+	image := dvlirtest.FirmwareImage("1.22", bytes.Repeat([]byte{0x5a}, 64*1024))
+	report, err := dvlirClient.UploadFirmwareFrom(context.Background(), "dvlir-1.22.bin", bytes.NewReader(image),
+		int64(len(image)), WithUploadProgress(func(s, _ int64) { sent = s }),
+		WithRebootTimeout(5*time.Second, 10*time.Millisecond))
+	if assert.NoError(t, err, "Upload wasn't retried") {
+		assert.Equal(t, "1.22", report.Version)
+	}
+	assert.Equal(t, 1, server.Injected(), "Upload wasn't dropped")
+	assert.Equal(t, int64(len(image)), sent)
+}
@@ -0,0 +1,128 @@
+package fleet
+
+import (
+	"context"
+	"encoding/json"
+	dvlirclient "github.com/inexio/dvlir-restapi-go-client"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+/*
+DesiredStates contains the desired state of the devices of an inventory, e.g.
+
+	defaults:
+	  ntp_server: true
+	  ntp_name: de.pool.ntp.org
+	  saving_interval: 15min
+	devices:
+	  berlin-1:
+	    dhcp: false
+	    ip_address: 192.168.0.100
+	    subnet_mask: 255.255.255.0
+	    gateway: 192.168.0.1
+
+The settings of Defaults apply to every device, the settings of a device override them.
+*/
+type DesiredStates struct {
+	Defaults dvlirclient.DesiredState            `yaml:"defaults" json:"defaults"`
+	Devices  map[string]dvlirclient.DesiredState `yaml:"devices" json:"devices"`
+}
+
+/*
+LoadDesiredStates reads desired states from a YAML or JSON file, the format is chosen by the file extension
+*/
+func LoadDesiredStates(path string) (*DesiredStates, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error while reading desired states")
+	}
+	var states DesiredStates
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		err = json.Unmarshal(data, &states)
+	} else {
+		err = yaml.UnmarshalStrict(data, &states)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "Error while parsing desired states")
+	}
+	return &states, nil
+}
+
+/*
+For returns the desired state of the device with the given name
+*/
+func (s *DesiredStates) For(name string) dvlirclient.DesiredState {
+	state := s.Defaults
+	device, ok := s.Devices[name]
+	if !ok {
+		return state
+	}
+	if device.DHCP != nil {
+		state.DHCP = device.DHCP
+	}
+	if device.IPAddress != "" {
+		state.IPAddress = device.IPAddress
+	}
+	if device.SubnetMask != "" {
+		state.SubnetMask = device.SubnetMask
+	}
+	if device.Gateway != "" {
+		state.Gateway = device.Gateway
+	}
+	if device.DNSServer != "" {
+		state.DNSServer = device.DNSServer
+	}
+	if device.NTPServer != nil {
+		state.NTPServer = device.NTPServer
+	}
+	if device.NTPName != "" {
+		state.NTPName = device.NTPName
+	}
+	if device.SavingInterval != "" {
+		state.SavingInterval = device.SavingInterval
+	}
+	if device.ResetWithDefaultPwd != nil {
+		state.ResetWithDefaultPwd = device.ResetWithDefaultPwd
+	}
+	return state
+}
+
+/*
+Validate checks that every device of the desired states is part of the inventory and that the desired state of every
+device of the inventory is valid
+*/
+func (s *DesiredStates) Validate(inventory *Inventory) error {
+	known := make(map[string]bool, len(inventory.Devices))
+	for _, device := range inventory.Devices {
+		known[device.Name] = true
+		if err := s.For(device.Name).Validate(); err != nil {
+			return errors.Wrap(err, "Invalid desired state of "+device.Name)
+		}
+	}
+	var unknown []string
+	for name := range s.Devices {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return errors.New("Desired state of unknown devices: " + strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+/*
+Reconcile returns an operation which brings every device into its desired state. The result contains the
+*dvlirclient.ReconcileReport of the device.
+*/
+func Reconcile(states *DesiredStates, opts dvlirclient.ReconcileOptions) Operation {
+	return func(ctx context.Context, device Device, adapter dvlirclient.Adapter) (interface{}, error) {
+		return adapter.Reconcile(ctx, states.For(device.Name), opts)
+	}
+}
@@ -0,0 +1,272 @@
+/*
+Package fleet runs operations on many DvLIR adapters at once.
+
+The adapters are listed in an inventory with their address, credentials and labels. Operations run on a selection of
+the adapters with bounded concurrency and a timeout per adapter, an unreachable adapter only fails its own result.
+*/
+package fleet
+
+import (
+	"context"
+	dvlirclient "github.com/inexio/dvlir-restapi-go-client"
+	"github.com/pkg/errors"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+Options configures a Fleet
+*/
+type Options struct {
+	//Concurrency limits the number of adapters an operation runs on in parallel, the default is 16
+	Concurrency int
+	//Timeout limits an operation on a single adapter including the login, the default is 30 seconds
+	Timeout time.Duration
+	//ClientOptions are passed to NewDvLIRClient
+	ClientOptions []dvlirclient.Option
+	//NewAdapter creates the adapter for a device, e.g. to add decorators or mocks. By default a DvLIRClient with
+	//session renewal is created.
+	NewAdapter func(device Device, opts ...dvlirclient.Option) (dvlirclient.Adapter, error)
+}
+
+/*
+Fleet runs operations on the devices of an inventory
+*/
+type Fleet struct {
+	devices []Device
+	opts    Options
+
+	mu       sync.Mutex
+	adapters map[string]*member
+}
+
+/*
+member is the adapter of a device, which is logged in on first use
+*/
+type member struct {
+	mu       sync.Mutex
+	adapter  dvlirclient.Adapter
+	loggedIn bool
+}
+
+/*
+Operation is run on a single adapter. The returned value is reported in the Result of the device.
+*/
+type Operation func(ctx context.Context, device Device, adapter dvlirclient.Adapter) (interface{}, error)
+
+/*
+New creates a fleet of the devices in the inventory
+*/
+func New(inventory *Inventory, opts Options) (*Fleet, error) {
+	if err := inventory.Validate(); err != nil {
+		return nil, err
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 16
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 30 * time.Second
+	}
+	if opts.NewAdapter == nil {
+		opts.NewAdapter = newClient
+	}
+	return &Fleet{
+		devices:  append([]Device(nil), inventory.Devices...),
+		opts:     opts,
+		adapters: make(map[string]*member),
+	}, nil
+}
+
+func newClient(device Device, opts ...dvlirclient.Option) (dvlirclient.Adapter, error) {
+	opts = append([]dvlirclient.Option{dvlirclient.WithSessionRenewal()}, opts...)
+	return dvlirclient.NewDvLIRClient(device.Address, device.Credentials(), opts...)
+}
+
+/*
+Devices returns the devices matching the selector in inventory order
+*/
+func (f *Fleet) Devices(selector Selector) []Device {
+	var devices []Device
+	for _, device := range f.devices {
+		if selector == nil || selector(device) {
+			devices = append(devices, device)
+		}
+	}
+	return devices
+}
+
+/*
+Run runs the operation on every device matching the selector and waits for all of them. The adapters are logged in
+on first use and stay logged in for later operations.
+*/
+func (f *Fleet) Run(ctx context.Context, selector Selector, operation Operation) *Report {
+	devices := f.Devices(selector)
+	report := &Report{Results: make([]Result, len(devices))}
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, f.opts.Concurrency)
+	for i, device := range devices {
+		report.Results[i].Device = device
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			report.Results[i].Err = errors.Wrap(ctx.Err(), "Operation wasn't started")
+			continue
+		}
+		wg.Add(1)
+		go func(result *Result) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			start := time.Now()
+			result.Value, result.Err = f.run(ctx, result.Device, operation)
+			result.Duration = time.Since(start)
+		}(&report.Results[i])
+	}
+	wg.Wait()
+	return report
+}
+
+func (f *Fleet) run(ctx context.Context, device Device, operation Operation) (interface{}, error) {
+	ctx, cancel := context.WithTimeout(ctx, f.opts.Timeout)
+	defer cancel()
+
+	adapter, err := f.adapter(ctx, device)
+	if err != nil {
+		return nil, err
+	}
+	return operation(ctx, device, adapter)
+}
+
+/*
+adapter returns the logged in adapter of the device
+*/
+func (f *Fleet) adapter(ctx context.Context, device Device) (dvlirclient.Adapter, error) {
+	f.mu.Lock()
+	m, ok := f.adapters[device.Name]
+	if !ok {
+		m = &member{}
+		f.adapters[device.Name] = m
+	}
+	f.mu.Unlock()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.adapter == nil {
+		adapter, err := f.opts.NewAdapter(device, f.opts.ClientOptions...)
+		if err != nil {
+			return nil, errors.Wrap(err, "Error while creating client")
+		}
+		m.adapter = adapter
+	}
+	if !m.loggedIn {
+		if err := m.adapter.LoginContext(ctx); err != nil {
+			return nil, errors.Wrap(err, "Error during login")
+		}
+		m.loggedIn = true
+	}
+	return m.adapter, nil
+}
+
+/*
+Close logs out of every adapter that is logged in
+*/
+func (f *Fleet) Close(ctx context.Context) error {
+	f.mu.Lock()
+	members := make(map[string]*member, len(f.adapters))
+	for name, m := range f.adapters {
+		members[name] = m
+	}
+	f.mu.Unlock()
+
+	var msgs []string
+	for name, m := range members {
+		m.mu.Lock()
+		if m.loggedIn {
+			if err := m.adapter.LogoutContext(ctx); err != nil {
+				msgs = append(msgs, name+": "+err.Error())
+			}
+			m.loggedIn = false
+		}
+		m.mu.Unlock()
+	}
+	if len(msgs) > 0 {
+		sort.Strings(msgs)
+		return errors.New("Error during logout // " + strings.Join(msgs, " // "))
+	}
+	return nil
+}
+
+/*
+Result is the outcome of an operation on a single device
+*/
+type Result struct {
+	Device   Device
+	Value    interface{}
+	Err      error
+	Duration time.Duration
+}
+
+/*
+Report contains the results of an operation on all selected devices in inventory order
+*/
+type Report struct {
+	Results []Result
+}
+
+/*
+Succeeded returns the results of the devices the operation succeeded on
+*/
+func (r *Report) Succeeded() []Result {
+	var results []Result
+	for _, result := range r.Results {
+		if result.Err == nil {
+			results = append(results, result)
+		}
+	}
+	return results
+}
+
+/*
+Failed returns the results of the devices the operation failed on
+*/
+func (r *Report) Failed() []Result {
+	var results []Result
+	for _, result := range r.Results {
+		if result.Err != nil {
+			results = append(results, result)
+		}
+	}
+	return results
+}
+
+/*
+Err returns an *Error describing all failed devices or nil if the operation succeeded on every device
+*/
+func (r *Report) Err() error {
+	failed := r.Failed()
+	if len(failed) == 0 {
+		return nil
+	}
+	return &Error{Total: len(r.Results), Failed: failed}
+}
+
+/*
+Error is returned by Report.Err if the operation failed on one or more devices
+*/
+type Error struct {
+	Total  int
+	Failed []Result
+}
+
+func (e *Error) Error() string {
+	msgs := make([]string, 0, len(e.Failed))
+	for _, result := range e.Failed {
+		msgs = append(msgs, result.Device.Name+": "+result.Err.Error())
+	}
+	sort.Strings(msgs)
+	return "fleet error: " + strconv.Itoa(len(e.Failed)) + " of " + strconv.Itoa(e.Total) + " devices failed // " +
+		strings.Join(msgs, " // ")
+}
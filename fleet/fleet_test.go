@@ -0,0 +1,337 @@
+package fleet
+
+import (
+	"context"
+	dvlirclient "github.com/inexio/dvlir-restapi-go-client"
+	"github.com/inexio/dvlir-restapi-go-client/dvlirtest"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+/*
+TestInventory covers:
+	- ParseYAML with labels and a password from the environment
+	- ParseJSON
+	- ParseYAML with an unknown field
+	- Validate with a duplicate and a nameless device
+*/
+func TestInventory(t *testing.T) {
+	os.Setenv("FLEET_TEST_PASSWORD", "secret")
+	defer os.Unsetenv("FLEET_TEST_PASSWORD")
+
+	inventory, err := ParseYAML([]byte(`
+devices:
+  - name: berlin-1
+    address: 192.168.0.100
+    password_env: FLEET_TEST_PASSWORD
+    labels:
+      site: berlin
+  - name: hamburg-1
+    address: 192.168.1.100
+    password: pw
+`))
+	if assert.NoError(t, err, "Error while parsing YAML inventory") && assert.Len(t, inventory.Devices, 2) {
+		assert.Equal(t, "secret", inventory.Devices[0].Credentials())
+		assert.Equal(t, "berlin", inventory.Devices[0].Labels["site"])
+		assert.Equal(t, "pw", inventory.Devices[1].Credentials())
+	}
+
+	inventory, err = ParseJSON([]byte(`{"devices": [{"name": "berlin-1", "address": "192.168.0.100"}]}`))
+	if assert.NoError(t, err, "Error while parsing JSON inventory") && assert.Len(t, inventory.Devices, 1) {
+		assert.Equal(t, "192.168.0.100", inventory.Devices[0].Address)
+	}
+
+	_, err = ParseYAML([]byte("devices:\n  - name: a\n    adress: 192.168.0.100\n"))
+	assert.Error(t, err, "Unknown field wasn't rejected")
+
+	inventory = &Inventory{Devices: []Device{{Name: "a", Address: "x"}, {Name: "a", Address: "y"}}}
+	assert.EqualError(t, inventory.Validate(), "Invalid inventory: duplicate device a")
+	inventory = &Inventory{Devices: []Device{{Address: "x"}}}
+	assert.EqualError(t, inventory.Validate(), "Invalid inventory: device x at position 1 has no name")
+}
+
+/*
+TestSelector covers:
+	- ParseSelector with labels
+	- ParseSelector with names and labels
+	- ParseSelector with an empty selector
+	- ParseSelector with a missing label name
+*/
+func TestSelector(t *testing.T) {
+	devices := []Device{
+		{Name: "a", Labels: map[string]string{"site": "berlin", "role": "main"}},
+		{Name: "b", Labels: map[string]string{"site": "berlin"}},
+		{Name: "c", Labels: map[string]string{"site": "hamburg", "role": "main"}},
+	}
+	selected := func(selector Selector) []string {
+		var names []string
+		for _, device := range devices {
+			if selector(device) {
+				names = append(names, device.Name)
+			}
+		}
+		return names
+	}
+
+	selector, err := ParseSelector("site=berlin")
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"a", "b"}, selected(selector))
+	}
+	selector, err = ParseSelector("a, c, role=main")
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"a", "c"}, selected(selector))
+	}
+	selector, err = ParseSelector("")
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"a", "b", "c"}, selected(selector))
+	}
+	_, err = ParseSelector("=main")
+	assert.Error(t, err, "Missing label name wasn't rejected")
+}
+
+/*
+unreachableAddress returns an address nobody listens on
+*/
+func unreachableAddress(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+	return address
+}
+
+/*
+TestFleet_Run covers:
+	- Run on all devices with an unreachable device
+	- Run on a selection of devices
+	- Report.Succeeded, Report.Failed and Report.Err
+	- Reuse of the session for later operations
+	- RestartAndWait with a decorated adapter
+	- ApplyNetworkSettings and Backup with a decorated adapter
+	- Close
+*/
+func TestFleet_Run(t *testing.T) {
+	var servers []*dvlirtest.Server
+	for i := 0; i < 3; i++ {
+		server := dvlirtest.NewServer()
+		defer server.Close()
+		servers = append(servers, server)
+	}
+
+	inventory := &Inventory{Devices: []Device{
+		{Name: "a", Address: servers[0].Address(), Password: servers[0].Password(), Labels: map[string]string{"site": "berlin"}},
+		{Name: "b", Address: servers[1].Address(), Password: servers[1].Password(), Labels: map[string]string{"site": "berlin"}},
+		{Name: "c", Address: servers[2].Address(), Password: servers[2].Password(), Labels: map[string]string{"site": "hamburg"}},
+		{Name: "d", Address: unreachableAddress(t), Password: "pw", Labels: map[string]string{"site": "hamburg"}},
+	}}
+	fleet, err := New(inventory, Options{Concurrency: 2, Timeout: 2 * time.Second})
+	if !assert.NoError(t, err, "Error while creating fleet") {
+		return
+	}
+	ctx := context.Background()
+
+	report := fleet.Run(ctx, All(), GetGeneralInformation())
+	if !assert.Len(t, report.Results, 4) {
+		return
+	}
+	assert.Len(t, report.Succeeded(), 3)
+	if assert.Len(t, report.Failed(), 1) {
+		assert.Equal(t, "d", report.Failed()[0].Device.Name)
+	}
+	info, ok := report.Results[0].Value.(dvlirclient.GeneralInfo)
+	if assert.True(t, ok, "Wrong result type") {
+		assert.Equal(t, servers[0].State().DeviceSn, info.DeviceSn)
+	}
+	var fleetError *Error
+	if assert.True(t, errors.As(report.Err(), &fleetError), "Error wasn't returned") {
+		assert.Equal(t, 4, fleetError.Total)
+		assert.Contains(t, fleetError.Error(), "1 of 4 devices failed // d: ")
+	}
+
+	report = fleet.Run(ctx, Labels(map[string]string{"site": "berlin"}), ChangeSavingInterval(dvlirclient.SavingIntervalMinute))
+	assert.NoError(t, report.Err(), "Error while changing saving interval")
+	assert.Len(t, report.Results, 2)
+	assert.Equal(t, "min", servers[1].State().SavingInterval)
+	assert.NotEqual(t, "min", servers[2].State().SavingInterval)
+	assert.Equal(t, 1, servers[0].Logins(), "Session wasn't reused")
+
+	metrics := &dvlirclient.OperationMetrics{}
+	decorated, err := New(inventory, Options{NewAdapter: func(device Device, opts ...dvlirclient.Option) (dvlirclient.Adapter, error) {
+		client, err := dvlirclient.NewDvLIRClient(device.Address, device.Credentials(), opts...)
+		if err != nil {
+			return nil, err
+		}
+		return dvlirclient.NewCachingAdapter(dvlirclient.NewMetricsAdapter(client, metrics), time.Minute), nil
+	}})
+	if !assert.NoError(t, err, "Error while creating fleet") {
+		return
+	}
+	report = decorated.Run(ctx, Names("c"), RestartAndWait(dvlirclient.ReadyOptions{InitialInterval: 10 * time.Millisecond}))
+	assert.NoError(t, report.Err(), "Error during RestartAndWait")
+	assert.Equal(t, 1, servers[2].Reboots(), "Adapter wasn't restarted")
+	assert.Equal(t, 1, metrics.Stats("RestartAndWait").Calls, "RestartAndWait didn't pass the decorators")
+	if ready, ok := report.Results[0].Value.(*dvlirclient.ReadyReport); assert.True(t, ok, "Wrong result type") {
+		assert.True(t, ready.WentDown)
+	}
+
+	settings := dvlirclient.NetworkSettings{}
+	settings.SetNTP(true, "pool.ntp.org")
+	report = decorated.Run(ctx, Names("a", "b"), ApplyNetworkSettings(settings))
+	assert.NoError(t, report.Err(), "Error during ApplyNetworkSettings")
+	assert.Equal(t, "pool.ntp.org", servers[1].State().NTPName)
+	report = decorated.Run(ctx, Names("a", "b"), Backup())
+	assert.NoError(t, report.Err(), "Error during Backup")
+	if snapshot, ok := report.Results[1].Value.(*dvlirclient.Snapshot); assert.True(t, ok, "Wrong result type") {
+		assert.Equal(t, "pool.ntp.org", snapshot.Network.NTPName)
+	}
+	assert.Equal(t, 2, metrics.Stats("ApplyNetworkSettings").Calls, "ApplyNetworkSettings didn't pass the decorators")
+	assert.Equal(t, 2, metrics.Stats("Backup").Calls, "Backup didn't pass the decorators")
+	assert.NoError(t, decorated.Close(ctx), "Error during Close")
+
+	assert.NoError(t, fleet.Close(ctx), "Error during Close")
+	assert.Equal(t, "", servers[0].SessionID(), "Session wasn't ended")
+}
+
+/*
+TestFleet_Concurrency covers:
+	- Run with bounded concurrency
+	- Run with a timeout per device
+*/
+func TestFleet_Concurrency(t *testing.T) {
+	var devices []Device
+	for _, name := range []string{"a", "b", "c", "d", "e", "f"} {
+		devices = append(devices, Device{Name: name, Address: name})
+	}
+	fleet, err := New(&Inventory{Devices: devices}, Options{
+		Concurrency: 2,
+		Timeout:     50 * time.Millisecond,
+		NewAdapter: func(device Device, _ ...dvlirclient.Option) (dvlirclient.Adapter, error) {
+			return noopAdapter{}, nil
+		},
+	})
+	if !assert.NoError(t, err, "Error while creating fleet") {
+		return
+	}
+
+	var running, peak int32
+	report := fleet.Run(context.Background(), All(), func(ctx context.Context, device Device, _ dvlirclient.Adapter) (interface{}, error) {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		if device.Name == "f" {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		time.Sleep(10 * time.Millisecond)
+		return device.Name, nil
+	})
+	assert.Equal(t, int32(2), atomic.LoadInt32(&peak), "Concurrency wasn't bounded")
+	assert.Len(t, report.Succeeded(), 5)
+	if assert.Len(t, report.Failed(), 1) {
+		assert.True(t, errors.Is(report.Failed()[0].Err, context.DeadlineExceeded), "Timeout wasn't applied")
+	}
+}
+
+/*
+noopAdapter is an adapter whose login always succeeds
+*/
+type noopAdapter struct {
+	dvlirclient.Adapter
+}
+
+func (noopAdapter) LoginContext(context.Context) error {
+	return nil
+}
+
+/*
+TestDesiredStates covers:
+	- LoadDesiredStates with defaults and device settings
+	- DesiredStates.For
+	- DesiredStates.Validate with an unknown device and an invalid setting
+	- Run with Reconcile on decorated adapters
+*/
+func TestDesiredStates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fleet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "desired.yaml")
+	err = ioutil.WriteFile(path, []byte(`
+defaults:
+  ntp_server: true
+  ntp_name: de.pool.ntp.org
+  saving_interval: 15min
+devices:
+  b:
+    ntp_name: pool.ntp.org
+    saving_interval: min
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	states, err := LoadDesiredStates(path)
+	if !assert.NoError(t, err, "Error while loading desired states") {
+		return
+	}
+	assert.Equal(t, "de.pool.ntp.org", states.For("a").NTPName)
+	assert.Equal(t, "pool.ntp.org", states.For("b").NTPName)
+	assert.Equal(t, dvlirclient.SavingIntervalMinute, states.For("b").SavingInterval)
+	if assert.NotNil(t, states.For("b").NTPServer) {
+		assert.True(t, *states.For("b").NTPServer)
+	}
+
+	var servers []*dvlirtest.Server
+	var devices []Device
+	for _, name := range []string{"a", "b"} {
+		server := dvlirtest.NewServer()
+		defer server.Close()
+		servers = append(servers, server)
+		devices = append(devices, Device{Name: name, Address: server.Address(), Password: server.Password()})
+	}
+	inventory := &Inventory{Devices: devices}
+	assert.NoError(t, states.Validate(inventory))
+	assert.EqualError(t, states.Validate(&Inventory{Devices: devices[:1]}), "Desired state of unknown devices: b")
+	invalid := &DesiredStates{Defaults: dvlirclient.DesiredState{SavingInterval: "hourly"}}
+	assert.Error(t, invalid.Validate(inventory), "Invalid saving interval wasn't rejected")
+
+	fleet, err := New(inventory, Options{NewAdapter: func(device Device, opts ...dvlirclient.Option) (dvlirclient.Adapter, error) {
+		client, err := dvlirclient.NewDvLIRClient(device.Address, device.Credentials(), opts...)
+		if err != nil {
+			return nil, err
+		}
+		return dvlirclient.NewLoggingAdapter(client, log.New(ioutil.Discard, "", 0)), nil
+	}})
+	if !assert.NoError(t, err, "Error while creating fleet") {
+		return
+	}
+	defer fleet.Close(context.Background())
+	report := fleet.Run(context.Background(), All(), Reconcile(states, dvlirclient.ReconcileOptions{}))
+	if !assert.NoError(t, report.Err(), "Error during Reconcile") {
+		return
+	}
+	a, ok := report.Results[0].Value.(*dvlirclient.ReconcileReport)
+	if assert.True(t, ok, "Wrong result type") {
+		assert.Empty(t, a.Plan, "Device in the desired state was changed")
+	}
+	b := report.Results[1].Value.(*dvlirclient.ReconcileReport)
+	assert.Len(t, b.Plan, 2)
+	assert.True(t, b.Verified)
+	assert.Equal(t, "pool.ntp.org", servers[1].State().NTPName)
+	assert.Equal(t, "min", servers[1].State().SavingInterval)
+}
@@ -0,0 +1,102 @@
+package fleet
+
+import (
+	"encoding/json"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+/*
+Device is an adapter of the inventory
+*/
+type Device struct {
+	//Name identifies the adapter in the fleet
+	Name string `yaml:"name" json:"name"`
+	//Address is the address of the adapter as expected by NewDvLIRClient
+	Address string `yaml:"address" json:"address"`
+	//Password is the password of the adapter
+	Password string `yaml:"password,omitempty" json:"password,omitempty"`
+	//PasswordEnv is the name of an environment variable containing the password, it is used if Password is empty
+	PasswordEnv string `yaml:"password_env,omitempty" json:"password_env,omitempty"`
+	//Labels are used to select adapters, e.g. site=berlin
+	Labels map[string]string `yaml:"labels,omitempty" json:"labels,omitempty"`
+}
+
+/*
+Credentials returns the password of the device
+*/
+func (d Device) Credentials() string {
+	if d.Password == "" && d.PasswordEnv != "" {
+		return os.Getenv(d.PasswordEnv)
+	}
+	return d.Password
+}
+
+/*
+Inventory is a list of adapters
+*/
+type Inventory struct {
+	Devices []Device `yaml:"devices" json:"devices"`
+}
+
+/*
+LoadInventory reads an inventory from a YAML or JSON file, the format is chosen by the file extension
+*/
+func LoadInventory(path string) (*Inventory, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error while reading inventory")
+	}
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		return ParseJSON(data)
+	}
+	return ParseYAML(data)
+}
+
+/*
+ParseYAML parses an inventory in YAML format
+*/
+func ParseYAML(data []byte) (*Inventory, error) {
+	var inventory Inventory
+	if err := yaml.UnmarshalStrict(data, &inventory); err != nil {
+		return nil, errors.Wrap(err, "Error while parsing inventory")
+	}
+	return &inventory, inventory.Validate()
+}
+
+/*
+ParseJSON parses an inventory in JSON format
+*/
+func ParseJSON(data []byte) (*Inventory, error) {
+	var inventory Inventory
+	if err := json.Unmarshal(data, &inventory); err != nil {
+		return nil, errors.Wrap(err, "Error while parsing inventory")
+	}
+	return &inventory, inventory.Validate()
+}
+
+/*
+Validate checks that every device has a unique name and an address
+*/
+func (i *Inventory) Validate() error {
+	names := make(map[string]bool, len(i.Devices))
+	for n, device := range i.Devices {
+		if device.Name == "" {
+			return errors.New("Invalid inventory: device " + strings.TrimSpace(device.Address) + " at position " +
+				strconv.Itoa(n+1) + " has no name")
+		}
+		if device.Address == "" {
+			return errors.New("Invalid inventory: device " + device.Name + " has no address")
+		}
+		if names[device.Name] {
+			return errors.New("Invalid inventory: duplicate device " + device.Name)
+		}
+		names[device.Name] = true
+	}
+	return nil
+}
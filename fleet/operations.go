@@ -0,0 +1,90 @@
+package fleet
+
+import (
+	"context"
+	dvlirclient "github.com/inexio/dvlir-restapi-go-client"
+)
+
+/*
+GetMomentaryValues returns an operation which reads the momentary values of every device
+*/
+func GetMomentaryValues() Operation {
+	return func(ctx context.Context, device Device, adapter dvlirclient.Adapter) (interface{}, error) {
+		return adapter.GetMomentaryValuesContext(ctx)
+	}
+}
+
+/*
+GetGeneralInformation returns an operation which reads the general information of every device
+*/
+func GetGeneralInformation() Operation {
+	return func(ctx context.Context, device Device, adapter dvlirclient.Adapter) (interface{}, error) {
+		return adapter.GetGeneralInformationContext(ctx)
+	}
+}
+
+/*
+GetSystemInformation returns an operation which reads the system information of every device
+*/
+func GetSystemInformation() Operation {
+	return func(ctx context.Context, device Device, adapter dvlirclient.Adapter) (interface{}, error) {
+		return adapter.GetSystemInformationContext(ctx)
+	}
+}
+
+/*
+Blink returns an operation which lets the LED of every device blink
+*/
+func Blink(blink, pause int) Operation {
+	return func(ctx context.Context, device Device, adapter dvlirclient.Adapter) (interface{}, error) {
+		return adapter.BlinkContext(ctx, blink, pause)
+	}
+}
+
+/*
+ChangeSavingInterval returns an operation which changes the saving interval of every device
+*/
+func ChangeSavingInterval(interval dvlirclient.SavingInterval) Operation {
+	return func(ctx context.Context, device Device, adapter dvlirclient.Adapter) (interface{}, error) {
+		return adapter.ChangeSavingIntervalContext(ctx, interval)
+	}
+}
+
+/*
+Restart returns an operation which restarts every device
+*/
+func Restart() Operation {
+	return func(ctx context.Context, device Device, adapter dvlirclient.Adapter) (interface{}, error) {
+		return adapter.RestartContext(ctx)
+	}
+}
+
+/*
+RestartAndWait returns an operation which restarts every device and waits until it accepts a login again. The result
+contains the *dvlirclient.ReadyReport of the device.
+*/
+func RestartAndWait(opts dvlirclient.ReadyOptions) Operation {
+	return func(ctx context.Context, device Device, adapter dvlirclient.Adapter) (interface{}, error) {
+		return adapter.RestartAndWait(ctx, opts)
+	}
+}
+
+/*
+ApplyNetworkSettings returns an operation which validates the settings and applies them to every device. Settings that
+change the address of a device have to be applied to each device with MigrateAddress instead.
+*/
+func ApplyNetworkSettings(settings dvlirclient.NetworkSettings) Operation {
+	return func(ctx context.Context, device Device, adapter dvlirclient.Adapter) (interface{}, error) {
+		return adapter.ApplyNetworkSettings(ctx, settings)
+	}
+}
+
+/*
+Backup returns an operation which reads a snapshot of every device. The result contains the *dvlirclient.Snapshot of
+the device.
+*/
+func Backup() Operation {
+	return func(ctx context.Context, device Device, adapter dvlirclient.Adapter) (interface{}, error) {
+		return adapter.Backup(ctx)
+	}
+}
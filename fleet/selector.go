@@ -0,0 +1,74 @@
+package fleet
+
+import (
+	"github.com/pkg/errors"
+	"strings"
+)
+
+/*
+Selector selects the devices an operation runs on
+*/
+type Selector func(device Device) bool
+
+/*
+All selects every device
+*/
+func All() Selector {
+	return func(Device) bool { return true }
+}
+
+/*
+Names selects the devices with the given names
+*/
+func Names(names ...string) Selector {
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		set[name] = true
+	}
+	return func(device Device) bool { return set[device.Name] }
+}
+
+/*
+Labels selects the devices that have all given labels
+*/
+func Labels(labels map[string]string) Selector {
+	return func(device Device) bool {
+		for key, value := range labels {
+			if actual, ok := device.Labels[key]; !ok || actual != value {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+/*
+ParseSelector parses a selector like "site=berlin,role=main". An empty selector selects every device, a term without
+"=" selects the device with that name.
+*/
+func ParseSelector(selector string) (Selector, error) {
+	labels := make(map[string]string)
+	var names []string
+	for _, term := range strings.Split(selector, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+		i := strings.Index(term, "=")
+		switch {
+		case i < 0:
+			names = append(names, term)
+		case i == 0:
+			return nil, errors.New("Invalid selector: missing label name in " + term)
+		default:
+			labels[strings.TrimSpace(term[:i])] = strings.TrimSpace(term[i+1:])
+		}
+	}
+
+	byLabels := Labels(labels)
+	if len(names) == 0 {
+		return byLabels, nil
+	}
+	byName := Names(names...)
+	return func(device Device) bool { return byName(device) && byLabels(device) }, nil
+}
@@ -1,11 +1,13 @@
 package dvlirclient
 
 import (
+	"context"
 	"github.com/go-resty/resty/v2"
 	"github.com/pkg/errors"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"path/filepath"
 	"strconv"
 )
 
@@ -17,14 +19,48 @@ type DvLIRClient struct {
 }
 
 /*
-NewDvLIRClient generates a new dvlir api-client object which can be used to communicate with the DvLIR-API
+NewDvLIRClient generates a new dvlir api-client object which can be used to communicate with the DvLIR-API.
+
+The address can be an IP address or host name with an optional port, an IPv6 literal or a complete base url like
+"https://proxy.example.com/dvlir". The options configure how requests are sent to the adapter.
 */
-func NewDvLIRClient(ipAddress string, password string) (*DvLIRClient, error) {
+func NewDvLIRClient(ipAddress string, password string, opts ...Option) (*DvLIRClient, error) {
 	if ipAddress == "" || password == "" {
 		return nil, errors.New("invalid IP address or invalid password")
 	}
+	return newDvLIRClient(ipAddress, password, opts...)
+}
+
+/*
+newDvLIRClient creates the client without validating the password
+*/
+func newDvLIRClient(ipAddress string, password string, opts ...Option) (*DvLIRClient, error) {
+	var options clientOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	base := options.baseURL
+	if base == "" {
+		base = ipAddress
+	}
+	base, err := baseURL(base)
+	if err != nil {
+		return nil, err
+	}
 
-	clientData := clientData{ipAddress: ipAddress, password: password, resty: resty.New()}
+	restyClient, err := options.newResty()
+	if err != nil {
+		return nil, err
+	}
+
+	clientData := clientData{
+		ipAddress:    ipAddress,
+		baseURL:      base,
+		resty:        restyClient,
+		session:      &session{password: password},
+		renewSession: options.renewSession,
+	}
 	newClient := client{&clientData}
 	return &DvLIRClient{newClient}, nil
 }
@@ -33,41 +69,90 @@ func NewDvLIRClient(ipAddress string, password string) (*DvLIRClient, error) {
 Login performs a login via the dvlir api-client
 */
 func (d *DvLIRClient) Login() error {
+	return d.LoginContext(context.Background())
+}
+
+/*
+LoginContext performs Login using ctx for the http request
+*/
+func (d *DvLIRClient) LoginContext(ctx context.Context) error {
 	if !d.isValid() {
 		return &NotValidError{}
 	}
 
-	res, err := d.get("/getSID.txt?pwd=", "", d.password)
+	sessionID, err := d.requestSessionID(ctx)
 	if err != nil {
-		return errors.Wrap(err, "Error during login request")
+		return err
+	}
+
+	d.session.start(sessionID)
+
+	return err
+}
+
+/*
+requestSessionID requests a new session ID with the password of the client
+*/
+func (d *DvLIRClient) requestSessionID(ctx context.Context) (string, error) {
+	res, err := d.get(ctx, "/getSID.txt?pwd=", "", d.session.secret())
+	if err != nil {
+		return "", errors.Wrap(err, "Error during login request")
 	}
 	if res.String() == "" {
 		err = errors.New("Error during login request")
-		return err
+		return "", err
 	}
-	check := res.String()[0:9]
-	if check == "<!DOCTYPE" {
-		err = errors.New("Login page was returned")
-		return err
+	if isLoginPage(res.String()) {
+		return "", ErrWrongPassword
 	}
 
-	d.sessionID = res.String()
+	return res.String(), nil
+}
 
-	return err
+/*
+Probe checks without a login whether a DvLIR adapter answers at the address. The session ID is requested without a
+password, which the adapter answers with its login page, so the session of another client isn't ended. The options
+configure the request like those of NewDvLIRClient. ErrNoAdapter is returned if the endpoint answers differently.
+*/
+func Probe(ctx context.Context, address string, opts ...Option) error {
+	if address == "" {
+		return errors.New("invalid IP address")
+	}
+	d, err := newDvLIRClient(address, "", opts...)
+	if err != nil {
+		return err
+	}
+	res, err := d.get(ctx, "/getSID.txt?pwd=", "", "")
+	if err != nil {
+		return err
+	}
+	if !isLoginPage(res.String()) {
+		return errors.Wrap(ErrNoAdapter, "Endpoint at "+d.baseURL+" didn't answer with the login page")
+	}
+	return nil
 }
 
 /*
 Logout performs a logout via the dvlir api-client
 */
 func (d *DvLIRClient) Logout() error {
+	return d.LogoutContext(context.Background())
+}
+
+/*
+LogoutContext performs Logout using ctx for the http request
+*/
+func (d *DvLIRClient) LogoutContext(ctx context.Context) error {
 	if !d.isValid() {
 		return &NotValidError{}
 	}
-	_, err := d.get("/getSID.txt", "", "")
+	_, err := d.get(ctx, "/getSID.txt", "", "")
 	if err != nil {
 		return errors.Wrap(err, "Error during logout")
 	}
 
+	d.session.clear()
+
 	return err
 }
 
@@ -75,10 +160,14 @@ func (d *DvLIRClient) Logout() error {
 GetDataFile a performs GetDataFile operation via the dvlir api-client
 */
 func (d *DvLIRClient) GetDataFile(lines int) (DataLines, error) {
+	return d.GetDataFileContext(context.Background(), lines)
+}
+
+/*
+GetDataFileContext performs GetDataFile using ctx for the http request
+*/
+func (d *DvLIRClient) GetDataFileContext(ctx context.Context, lines int) (DataLines, error) {
 	var empty DataLines
-	var splitted []string
-	var fileLines DataLines
-	var fileLine DataLine
 	if !d.isValid() {
 		return empty, &NotValidError{}
 	}
@@ -91,22 +180,19 @@ func (d *DvLIRClient) GetDataFile(lines int) (DataLines, error) {
 
 	linesE := url.QueryEscape(strconv.Itoa(lines))
 
-	path := "/daten.csv?sid=" + d.sessionID + "&lines=" + linesE
-	f, err := d.get(path, "", "")
+	f, err := d.getSession(ctx, "/daten.csv", "&lines="+linesE)
 	if err != nil {
 		return empty, errors.Wrap(err, "Error during GetDataFile request")
 	}
 
-	check := f.String()[0:9]
-	if check == "<!DOCTYPE" {
-		err = errors.New("Login page was returned")
+	if isLoginPage(f.String()) {
+		err = ErrSessionExpired
 		return empty, err
 	}
 
-	splitted = d.LineSplitter(f.String())
-	for i := 0; i < len(splitted); i++ {
-		fileLine = d.DataLineConversion(splitted[i])
-		fileLines = append(fileLines, fileLine)
+	fileLines, err := ParseDataFile(f.String())
+	if err != nil {
+		return empty, errors.Wrap(err, "Error while parsing GetDataFile response")
 	}
 
 	return fileLines, err
@@ -116,43 +202,32 @@ func (d *DvLIRClient) GetDataFile(lines int) (DataLines, error) {
 GetMomentaryValues a performs GetMomentaryValue operation via the dvlir api-client
 */
 func (d *DvLIRClient) GetMomentaryValues() (MomentaryValues, error) {
+	return d.GetMomentaryValuesContext(context.Background())
+}
+
+/*
+GetMomentaryValuesContext performs GetMomentaryValues using ctx for the http request
+*/
+func (d *DvLIRClient) GetMomentaryValuesContext(ctx context.Context) (MomentaryValues, error) {
 	var empty MomentaryValues
-	var values MomentaryValues
 	if !d.isValid() {
 		return empty, &NotValidError{}
 	}
 
-	path := "/data.txt?sid=" + d.sessionID
-	v, err := d.get(path, "", "")
+	v, err := d.getSession(ctx, "/data.txt", "")
 	if err != nil {
 		return empty, errors.Wrap(err, "Error during GetMomentaryValues request")
 	}
 
-	check := v.String()[0:9]
-	if check == "<!DOCTYPE" {
-		err = errors.New("Login page was returned")
+	if isLoginPage(v.String()) {
+		err = ErrSessionExpired
 		return empty, err
 	}
 
-	val := d.HashTagSplitter(v.String())
-
-	for ns := 8 - len(val[0]); ns > 0; ns-- {
-		val[0] = "0" + val[0]
-	}
-
-	values.MeterNumber = val[0]
-	values.OBISNum = val[1]
-	values.MomentaryPower = val[2]
-	values.MeterReadingAP = val[3]
-	values.MeterReadingAM = val[4]
-	for i := 5; i <= 13; i++ {
-		values.MeterReadingsAP[i-5] = val[i]
-	}
-	for j := 14; j <= 22; j++ {
-		values.MeterReadingsAM[j-14] = val[j]
+	values, err := ParseMomentaryValues(v.String())
+	if err != nil {
+		return empty, errors.Wrap(err, "Error while parsing GetMomentaryValues response")
 	}
-	values.Status = val[23]
-	values.SavingInterval = val[24]
 
 	return values, err
 }
@@ -161,44 +236,33 @@ func (d *DvLIRClient) GetMomentaryValues() (MomentaryValues, error) {
 GetGeneralInformation a performs GetGeneralInformation operation via the dvlir api-client
 */
 func (d *DvLIRClient) GetGeneralInformation() (GeneralInfo, error) {
+	return d.GetGeneralInformationContext(context.Background())
+}
+
+/*
+GetGeneralInformationContext performs GetGeneralInformation using ctx for the http request
+*/
+func (d *DvLIRClient) GetGeneralInformationContext(ctx context.Context) (GeneralInfo, error) {
 	var empty GeneralInfo
-	var info GeneralInfo
 	if !d.isValid() {
 		return empty, &NotValidError{}
 	}
 
-	path := "/info.txt?sid=" + d.sessionID
-	i, err := d.get(path, "", "")
+	i, err := d.getSession(ctx, "/info.txt", "")
 	if err != nil {
 		return empty, errors.Wrap(err, "Error during GetGeneralInformation request")
 	}
 
-	check := i.String()[0:9]
-	if check == "<!DOCTYPE" {
-		err = errors.New("Login page was returned")
+	if isLoginPage(i.String()) {
+		err = ErrSessionExpired
 		return empty, err
 	}
 
-	information := d.HashTagSplitter(i.String())
-	info.ServerIDMeter = information[0]
-
-	for ns := 8 - len(information[1]); ns > 0; ns-- {
-		information[1] = "0" + information[1]
+	info, err := ParseGeneralInfo(i.String())
+	if err != nil {
+		return empty, errors.Wrap(err, "Error while parsing GetGeneralInformation response")
 	}
 
-	info.MeterNumber = information[1]
-	info.ManufacturerCode = information[2]
-	info.IPAddress = information[3]
-	info.Gateway = information[4]
-	info.DNSServer = information[5]
-	info.NetworkName = information[6]
-	info.MACAddress = information[7]
-	info.SavingInterval = information[8]
-	info.Date = information[9]
-	info.Time = information[10]
-	info.DeviceSn = information[11]
-	info.FirmwareVersion = information[12]
-
 	return info, err
 }
 
@@ -206,32 +270,32 @@ func (d *DvLIRClient) GetGeneralInformation() (GeneralInfo, error) {
 GetNetworkInformation a performs GetNetworkInformation operation via the dvlir api-client
 */
 func (d *DvLIRClient) GetNetworkInformation() (NetworkInfo, error) {
+	return d.GetNetworkInformationContext(context.Background())
+}
+
+/*
+GetNetworkInformationContext performs GetNetworkInformation using ctx for the http request
+*/
+func (d *DvLIRClient) GetNetworkInformationContext(ctx context.Context) (NetworkInfo, error) {
 	var empty NetworkInfo
-	var net NetworkInfo
 	if !d.isValid() {
 		return empty, &NotValidError{}
 	}
 
-	path := "/network.txt?sid=" + d.sessionID
-	n, err := d.get(path, "", "")
+	n, err := d.getSession(ctx, "/network.txt", "")
 	if err != nil {
 		return empty, errors.Wrap(err, "Error during GetGeneralInformation request")
 	}
 
-	check := n.String()[0:9]
-	if check == "<!DOCTYPE" {
-		err = errors.New("Login page was returned")
+	if isLoginPage(n.String()) {
+		err = ErrSessionExpired
 		return empty, err
 	}
 
-	info := d.HashTagSplitter(n.String())
-	net.DHCPServer = info[0]
-	net.IPAddress = info[1]
-	net.SubnetMask = info[2]
-	net.Gateway = info[3]
-	net.DNSServer = info[4]
-	net.NTPServer = info[5]
-	net.NTPName = info[6]
+	net, err := ParseNetworkInfo(n.String())
+	if err != nil {
+		return empty, errors.Wrap(err, "Error while parsing GetNetworkInformation response")
+	}
 
 	return net, err
 }
@@ -240,29 +304,32 @@ func (d *DvLIRClient) GetNetworkInformation() (NetworkInfo, error) {
 GetSystemInformation a performs GetSystemInformation operation via the dvlir api-client
 */
 func (d *DvLIRClient) GetSystemInformation() (SystemInfo, error) {
+	return d.GetSystemInformationContext(context.Background())
+}
+
+/*
+GetSystemInformationContext performs GetSystemInformation using ctx for the http request
+*/
+func (d *DvLIRClient) GetSystemInformationContext(ctx context.Context) (SystemInfo, error) {
 	var empty SystemInfo
-	var system SystemInfo
 	if !d.isValid() {
 		return empty, &NotValidError{}
 	}
 
-	path := "/system.txt?sid=" + d.sessionID
-	s, err := d.get(path, "", "")
+	s, err := d.getSession(ctx, "/system.txt", "")
 	if err != nil {
 		return empty, errors.Wrap(err, "Error during GetSystemInformation request")
 	}
 
-	check := s.String()[0:9]
-	if check == "<!DOCTYPE" {
-		err = errors.New("Login page was returned")
+	if isLoginPage(s.String()) {
+		err = ErrSessionExpired
 		return empty, err
 	}
 
-	sys := d.HashTagSplitter(s.String())
-	system.SavingInterval = sys[0]
-	system.ResetCode = sys[1]
-	system.DeleteCode = sys[2]
-	system.ResetWithDefaultPwd = sys[3]
+	system, err := ParseSystemInfo(s.String())
+	if err != nil {
+		return empty, errors.Wrap(err, "Error while parsing GetSystemInformation response")
+	}
 
 	return system, err
 }
@@ -271,6 +338,13 @@ func (d *DvLIRClient) GetSystemInformation() (SystemInfo, error) {
 Blink a performs Blink operation via the dvlir api-client
 */
 func (d *DvLIRClient) Blink(blink int, pause int) (response int, err error) {
+	return d.BlinkContext(context.Background(), blink, pause)
+}
+
+/*
+BlinkContext performs Blink using ctx for the http request
+*/
+func (d *DvLIRClient) BlinkContext(ctx context.Context, blink int, pause int) (response int, err error) {
 	if !d.isValid() {
 		return 0, &NotValidError{}
 	}
@@ -286,24 +360,19 @@ func (d *DvLIRClient) Blink(blink int, pause int) (response int, err error) {
 	pauseE := url.QueryEscape(strconv.Itoa(pause))
 	blinkE := url.QueryEscape(strconv.Itoa(blink))
 
-	path := "/blink.cmd?sid=" + d.sessionID + "&ledPause=" + pauseE + "&ledBlink=" + blinkE
-	resp, err := d.get(path, "", "")
+	resp, err := d.getSession(ctx, "/blink.cmd", "&ledPause="+pauseE+"&ledBlink="+blinkE)
 	if err != nil {
 		return 0, errors.Wrap(err, "Error during Blink request")
 	}
+	if isLoginPage(resp.String()) {
+		err = ErrSessionExpired
+		return 0, err
+	}
 	res, err := strconv.Atoi(resp.String())
 	if err != nil {
 		return 0, errors.Wrap(err, "Error during conversion of response code from string to integer")
 	}
 
-	if len(resp.String()) > 3 {
-		check := resp.String()[0:9]
-		if check == "<!DOCTYPE" {
-			err = errors.New("Login page was returned")
-			return 0, err
-		}
-	}
-
 	return res, err
 }
 
@@ -311,17 +380,27 @@ func (d *DvLIRClient) Blink(blink int, pause int) (response int, err error) {
 NTPServerTest a performs NTPServerTest operation via the dvlir api-client
 */
 func (d *DvLIRClient) NTPServerTest(ntpName string) (int, error) {
+	return d.NTPServerTestContext(context.Background(), ntpName)
+}
+
+/*
+NTPServerTestContext performs NTPServerTest using ctx for the http request
+*/
+func (d *DvLIRClient) NTPServerTestContext(ctx context.Context, ntpName string) (int, error) {
 	if !d.isValid() {
 		return 0, &NotValidError{}
 	}
 
 	ntpNameE := url.QueryEscape(ntpName)
 
-	path := "/ntpTest.cmd?sid=" + d.sessionID + "&ntpName=" + ntpNameE
-	c, err := d.get(path, "", "")
+	c, err := d.getSession(ctx, "/ntpTest.cmd", "&ntpName="+ntpNameE)
 	if err != nil {
 		return 0, errors.Wrap(err, "Error during Blink request")
 	}
+	if isLoginPage(c.String()) {
+		err = ErrSessionExpired
+		return 0, err
+	}
 	code, err := strconv.Atoi(c.String())
 	if err != nil {
 		return 0, errors.Wrap(err, "Error during conversion of response code")
@@ -329,29 +408,21 @@ func (d *DvLIRClient) NTPServerTest(ntpName string) (int, error) {
 
 	switch code {
 	case 0:
-		err = errors.New("NTP-server can't be reached")
+		err = ErrNTPUnreachable
 		return 0, err
 	case 1:
 		break
 	case 2:
-		err = errors.New("You have to wait at least 30 seconds until doing this again")
+		err = ErrNTPRateLimited
 		return 2, err
 	case 3:
-		err = errors.New("NTP-servername is invalid")
+		err = ErrNTPInvalidName
 		return 3, err
 	default:
 		err = errors.New("Error during NTPServerTest request")
 		return 0, err
 	}
 
-	if len(c.String()) > 3 {
-		check := c.String()[0:9]
-		if check == "<!DOCTYPE" {
-			err = errors.New("Login page was returned")
-			return 0, err
-		}
-	}
-
 	return code, err
 }
 
@@ -359,15 +430,22 @@ func (d *DvLIRClient) NTPServerTest(ntpName string) (int, error) {
 ChangeNetworkSettings a performs ChangeNetworkSettings operation via the dvlir api-client
 */
 func (d *DvLIRClient) ChangeNetworkSettings(dhcp, ip, sub, gw, dns, ntpName, ntpServer, setDt string) (string, error) {
+	return d.ChangeNetworkSettingsContext(context.Background(), dhcp, ip, sub, gw, dns, ntpName, ntpServer, setDt)
+}
+
+/*
+ChangeNetworkSettingsContext performs ChangeNetworkSettings using ctx for the http request
+*/
+func (d *DvLIRClient) ChangeNetworkSettingsContext(ctx context.Context, dhcp, ip, sub, gw, dns, ntpName, ntpServer, setDt string) (string, error) {
 	if !d.isValid() {
 		return "", &NotValidError{}
 	}
-	path := "/network.cmd?sid=" + d.sessionID
+	var params string
 
 	if dhcp != "" {
 		if switchCase(dhcp) {
 			dhcpE := url.QueryEscape(dhcp)
-			path += "&dhcpServer=" + dhcpE
+			params += "&dhcpServer=" + dhcpE
 		} else {
 			err := errors.New("Invalid input for dhcp (can only be 'Yes', 'yes', 'No', 'no')")
 			return "", err
@@ -375,28 +453,28 @@ func (d *DvLIRClient) ChangeNetworkSettings(dhcp, ip, sub, gw, dns, ntpName, ntp
 	}
 	if ip != "" {
 		ipE := url.QueryEscape(ip)
-		path += "&ip=" + ipE
+		params += "&ip=" + ipE
 	}
 	if sub != "" {
 		subE := url.QueryEscape(sub)
-		path += "&sub=" + subE
+		params += "&sub=" + subE
 	}
 	if gw != "" {
 		gwE := url.QueryEscape(gw)
-		path += "&gw=" + gwE
+		params += "&gw=" + gwE
 	}
 	if dns != "" {
 		dnsE := url.QueryEscape(dns)
-		path += "&dns=" + dnsE
+		params += "&dns=" + dnsE
 	}
 	if ntpName != "" {
 		ntpNameE := url.QueryEscape(ntpName)
-		path += "&ntpName=" + ntpNameE
+		params += "&ntpName=" + ntpNameE
 	}
 	if ntpServer != "" {
 		if switchCase(ntpServer) {
 			ntpServerE := url.QueryEscape(ntpServer)
-			path += "&ntpServer=" + ntpServerE
+			params += "&ntpServer=" + ntpServerE
 		} else {
 			err := errors.New("Invalid input for ntpServer (can only be 'Yes', 'yes', 'No', 'no')")
 			return "", err
@@ -404,25 +482,22 @@ func (d *DvLIRClient) ChangeNetworkSettings(dhcp, ip, sub, gw, dns, ntpName, ntp
 	}
 	if setDt != "" {
 		setDtE := url.QueryEscape(setDt)
-		path += "&setDt=" + setDtE
+		params += "&setDt=" + setDtE
 	}
 
-	res, err := d.get(path, "", "")
+	res, err := d.getSession(ctx, "/network.cmd", params)
 	if err != nil {
 		return "", errors.Wrap(err, "Error during ChangeNetworkSettings request")
 	}
 
 	if res.String() == "cmd=" {
-		err = errors.New("Either no parameters were given or an unexpected error occurred")
+		err = ErrNoParameters
 		return "", errors.Wrap(err, "An error was returned")
 	}
 
-	if len(res.String()) > 4 {
-		check := res.String()[0:9]
-		if check == "<!DOCTYPE" {
-			err = errors.New("Login page was returned")
-			return "", err
-		}
+	if isLoginPage(res.String()) {
+		err = ErrSessionExpired
+		return "", err
 	}
 
 	return res.String(), err
@@ -431,20 +506,26 @@ func (d *DvLIRClient) ChangeNetworkSettings(dhcp, ip, sub, gw, dns, ntpName, ntp
 /*
 ChangeSavingInterval a performs ChangeSavingInterval operation via the dvlir api-client
 */
-func (d *DvLIRClient) ChangeSavingInterval(interval string) (string, error) {
+func (d *DvLIRClient) ChangeSavingInterval(interval SavingInterval) (string, error) {
+	return d.ChangeSavingIntervalContext(context.Background(), interval)
+}
+
+/*
+ChangeSavingIntervalContext performs ChangeSavingInterval using ctx for the http request
+*/
+func (d *DvLIRClient) ChangeSavingIntervalContext(ctx context.Context, interval SavingInterval) (string, error) {
 	if !d.isValid() {
 		return "", &NotValidError{}
 	}
 
-	if interval != "15min" && interval != "min" && interval != "sec" {
+	if !interval.Valid() {
 		err := errors.New("Invalid saving interval (can only be '15min', 'min', 'sec')")
 		return "", err
 	}
 
-	intervalE := url.QueryEscape(interval)
+	intervalE := url.QueryEscape(interval.String())
 
-	path := "/system.cmd?sid=" + d.sessionID + "&interval=" + intervalE
-	res, err := d.get(path, "", "")
+	res, err := d.getSession(ctx, "/system.cmd", "&interval="+intervalE)
 	if err != nil {
 		return "", errors.Wrap(err, "Error during ChangeSavingInterval request")
 	}
@@ -453,9 +534,8 @@ func (d *DvLIRClient) ChangeSavingInterval(interval string) (string, error) {
 		return "", err
 	}
 
-	check := res.String()[0:9]
-	if check == "<!DOCTYPE" {
-		err = errors.New("Login page was returned")
+	if isLoginPage(res.String()) {
+		err = ErrSessionExpired
 		return "", err
 	}
 
@@ -466,6 +546,13 @@ func (d *DvLIRClient) ChangeSavingInterval(interval string) (string, error) {
 AllowResetWithPwd a performs AllowResetWithPwd operation via the dvlir api-client
 */
 func (d *DvLIRClient) AllowResetWithPwd(allow string) (string, error) {
+	return d.AllowResetWithPwdContext(context.Background(), allow)
+}
+
+/*
+AllowResetWithPwdContext performs AllowResetWithPwd using ctx for the http request
+*/
+func (d *DvLIRClient) AllowResetWithPwdContext(ctx context.Context, allow string) (string, error) {
 	if !d.isValid() {
 		return "", &NotValidError{}
 	}
@@ -477,8 +564,7 @@ func (d *DvLIRClient) AllowResetWithPwd(allow string) (string, error) {
 
 	allowE := url.QueryEscape(allow)
 
-	path := "/system.cmd?sid=" + d.sessionID + "&allowResetWithPwd=" + allowE
-	res, err := d.get(path, "", "")
+	res, err := d.getSession(ctx, "/system.cmd", "&allowResetWithPwd="+allowE)
 	if err != nil {
 		return "", errors.Wrap(err, "Error during AllowResetWithPwd request")
 	}
@@ -487,9 +573,8 @@ func (d *DvLIRClient) AllowResetWithPwd(allow string) (string, error) {
 		return "", err
 	}
 
-	check := res.String()[0:9]
-	if check == "<!DOCTYPE" {
-		err = errors.New("Login page was returned")
+	if isLoginPage(res.String()) {
+		err = ErrSessionExpired
 		return "", err
 	}
 
@@ -500,14 +585,20 @@ func (d *DvLIRClient) AllowResetWithPwd(allow string) (string, error) {
 ResetAll a performs ResetAll operation via the dvlir api-client
 */
 func (d *DvLIRClient) ResetAll(rCode string) (string, error) {
+	return d.ResetAllContext(context.Background(), rCode)
+}
+
+/*
+ResetAllContext performs ResetAll using ctx for the http request
+*/
+func (d *DvLIRClient) ResetAllContext(ctx context.Context, rCode string) (string, error) {
 	if !d.isValid() {
 		return "", &NotValidError{}
 	}
 
 	rCodeE := url.QueryEscape(rCode)
 
-	path := "/system.cmd?sid=" + d.sessionID + "&resetAll=" + rCodeE
-	res, err := d.get(path, "", "")
+	res, err := d.getSession(ctx, "/system.cmd", "&resetAll="+rCodeE)
 	if err != nil {
 		return "", errors.Wrap(err, "Error during ResetAll request")
 	}
@@ -516,9 +607,8 @@ func (d *DvLIRClient) ResetAll(rCode string) (string, error) {
 		return "", err
 	}
 
-	check := res.String()[0:9]
-	if check == "<!DOCTYPE" {
-		err = errors.New("Login page was returned")
+	if isLoginPage(res.String()) {
+		err = ErrSessionExpired
 		return "", err
 	}
 
@@ -529,14 +619,20 @@ func (d *DvLIRClient) ResetAll(rCode string) (string, error) {
 DeleteData a performs DeleteData operation via the dvlir api-client
 */
 func (d *DvLIRClient) DeleteData(code string) (string, error) {
+	return d.DeleteDataContext(context.Background(), code)
+}
+
+/*
+DeleteDataContext performs DeleteData using ctx for the http request
+*/
+func (d *DvLIRClient) DeleteDataContext(ctx context.Context, code string) (string, error) {
 	if !d.isValid() {
 		return "", &NotValidError{}
 	}
 
 	dCodeE := url.QueryEscape(code)
 
-	path := "/system.cmd?sid=" + d.sessionID + "&resetData=" + dCodeE
-	res, err := d.get(path, "", "")
+	res, err := d.getSession(ctx, "/system.cmd", "&resetData="+dCodeE)
 	if err != nil {
 		return "", errors.Wrap(err, "Error during DeleteData request")
 	}
@@ -545,9 +641,8 @@ func (d *DvLIRClient) DeleteData(code string) (string, error) {
 		return "", err
 	}
 
-	check := res.String()[0:9]
-	if check == "<!DOCTYPE" {
-		err = errors.New("Login page was returned")
+	if isLoginPage(res.String()) {
+		err = ErrSessionExpired
 		return "", err
 	}
 
@@ -558,46 +653,53 @@ func (d *DvLIRClient) DeleteData(code string) (string, error) {
 ChangePassword a performs ChangePassword operation via the dvlir api-client
 */
 func (d *DvLIRClient) ChangePassword(pw1, pw2, pw3 string) (string, error) {
+	return d.ChangePasswordContext(context.Background(), pw1, pw2, pw3)
+}
+
+/*
+ChangePasswordContext performs ChangePassword using ctx for the http request
+*/
+func (d *DvLIRClient) ChangePasswordContext(ctx context.Context, pw1, pw2, pw3 string) (string, error) {
 	if !d.isValid() {
 		return "", &NotValidError{}
 	}
-	path := "http://" + d.ipAddress + "/password.cmd?sid=" + d.sessionID
-
 	Pw1 := url.QueryEscape(pw1)
 	Pw2 := url.QueryEscape(pw2)
 	Pw3 := url.QueryEscape(pw3)
 
-	res, err := http.PostForm(path, url.Values{"pw1": {Pw1}, "pw2": {Pw2}, "pw3": {Pw3}})
+	form := map[string]string{"pw1": Pw1, "pw2": Pw2, "pw3": Pw3}
+	res, err := d.withSession(ctx, func(sessionID string) (*resty.Response, error) {
+		path := d.baseURL + "/password.cmd?sid=" + sessionID
+		return d.resty.R().SetContext(ctx).SetFormData(form).Post(path)
+	})
 	if err != nil {
 		return "", errors.Wrap(err, "Error during ChangePassword request")
 	}
 
 	var bodyString string
-	if res.StatusCode == http.StatusOK {
-		bodyBytes, err := ioutil.ReadAll(res.Body)
-		if err != nil {
-			err = errors.New("Error while converting http response to string")
-			return "", err
-		}
-		bodyString = string(bodyBytes)
+	if res.StatusCode() == http.StatusOK {
+		bodyString = string(res.Body())
 	}
 
 	if bodyString == "2" {
-		err = errors.New("Current password is wrong")
+		err = ErrWrongPassword
 		return "", err
 	} else if bodyString == "3" {
-		err = errors.New("New password does not match confirm new password")
+		err = ErrPasswordMismatch
 		return "", err
 	} else if bodyString == "4" {
-		err = errors.New("Illegal character in new password")
+		err = ErrIllegalPasswordChar
+		return "", err
 	}
 
-	if len(bodyString) > 3 {
-		check := bodyString[0:9]
-		if check == "<!DOCTYPE" {
-			err := errors.New("Login page was returned")
-			return "", err
-		}
+	if isLoginPage(bodyString) {
+		err := ErrSessionExpired
+		return "", err
+	}
+
+	//The next login, e.g. the renewal of an expired session, has to use the new password
+	if res.StatusCode() == http.StatusOK {
+		d.session.setPassword(pw2)
 	}
 
 	return bodyString, err
@@ -608,48 +710,42 @@ func (d *DvLIRClient) ChangePassword(pw1, pw2, pw3 string) (string, error) {
 UploadFirmware a performs UploadFirmware operation via the dvlir api-client
 */
 func (d *DvLIRClient) UploadFirmware(filePath string) (string, error) {
+	return d.UploadFirmwareContext(context.Background(), filePath)
+}
+
+/*
+UploadFirmwareContext performs UploadFirmware using ctx for the http request. UploadFirmwareFrom additionally validates
+the image and verifies the upgrade.
+*/
+func (d *DvLIRClient) UploadFirmwareContext(ctx context.Context, filePath string) (string, error) {
 	if !d.isValid() {
 		return "", &NotValidError{}
 	}
 
-	path := "http://" + d.ipAddress + "/upload.cmd?sid=" + d.sessionID
-
-	header := make(map[string]string)
-	header["Content-Type"] = "multipart/form-data"
-
-	filePathE := url.QueryEscape(filePath)
-
-	resp, err := d.post(path, filePathE, header, nil, true)
-
+	image, err := ioutil.ReadFile(filePath)
 	if err != nil {
-		return "", errors.Wrap(err, "Error during UploadFirmware")
-	}
-	if resp.String() == "2" || resp.String() == "3" || resp.String() == "4" || resp.String() == "5" {
-		err = errors.New("The following error occurred" + resp.String())
-		return "", err
+		return "", errors.Wrap(err, "Error while reading firmware file")
 	}
-
-	if len(resp.String()) > 3 {
-		check := resp.String()[0:9]
-		if check == "<!DOCTYPE" {
-			err = errors.New("Login page was returned")
-			return "", err
-		}
-	}
-
-	return resp.String(), err
+	return d.uploadFirmwareImage(ctx, filepath.Base(filePath), image, nil)
 }
 
 /*
 Restart a performs Restart operation via the dvlir api-client
 */
 func (d *DvLIRClient) Restart() (string, error) {
+	return d.RestartContext(context.Background())
+}
+
+/*
+RestartContext performs Restart using ctx for the http request
+*/
+func (d *DvLIRClient) RestartContext(ctx context.Context) (string, error) {
 	if !d.isValid() {
 		return "", &NotValidError{}
 	}
 
 	path := "/doReset.cmd?pwd="
-	res, err := d.get(path, "", d.password)
+	res, err := d.get(ctx, path, "", d.session.secret())
 	if err != nil {
 		return "", err
 	}
@@ -658,9 +754,8 @@ func (d *DvLIRClient) Restart() (string, error) {
 		return "", err
 	}
 
-	check := res.String()[0:9]
-	if check == "<!DOCTYPE" {
-		err = errors.New("Login page was returned")
+	if isLoginPage(res.String()) {
+		err = ErrSessionExpired
 		return "", err
 	}
 
@@ -7,4 +7,5 @@ require (
 	github.com/pkg/errors v0.9.1
 	github.com/spf13/viper v1.6.2
 	github.com/stretchr/testify v1.5.1
+	gopkg.in/yaml.v2 v2.2.4
 )
@@ -0,0 +1,29 @@
+/*
+Package hostname validates host names. It is shared by the client and the simulated adapter, which can't import each
+other.
+*/
+package hostname
+
+import (
+	"strings"
+)
+
+/*
+Valid returns true if name is a valid host name or IPv4 address
+*/
+func Valid(name string) bool {
+	if len(name) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(name, ".") {
+		if label == "" || len(label) > 63 || strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return false
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
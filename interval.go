@@ -0,0 +1,108 @@
+package dvlirclient
+
+import (
+	"github.com/pkg/errors"
+	"strings"
+	"time"
+)
+
+/*
+SavingInterval is the interval in which the adapter saves a line to daten.csv. The value is the representation used
+in requests to the adapter.
+*/
+type SavingInterval string
+
+// Saving intervals supported by the adapter
+const (
+	SavingInterval15Min  SavingInterval = "15min"
+	SavingIntervalMinute SavingInterval = "min"
+	SavingIntervalSecond SavingInterval = "sec"
+)
+
+/*
+ParseSavingInterval parses a saving interval as reported by the adapter. Besides the request representations
+"15min", "min" and "sec" it accepts spelled out variants like "15 min", "1min", "1 sec" or "900s".
+*/
+func ParseSavingInterval(interval string) (SavingInterval, error) {
+	normalized := strings.ToLower(strings.Join(strings.Fields(interval), ""))
+	switch normalized {
+	case "15min", "15m", "900s", "900sec":
+		return SavingInterval15Min, nil
+	case "min", "1min", "1m", "60s", "60sec":
+		return SavingIntervalMinute, nil
+	case "sec", "1sec", "1s":
+		return SavingIntervalSecond, nil
+	default:
+		return "", errors.New("Unknown saving interval: " + interval)
+	}
+}
+
+/*
+Valid returns true if the saving interval is supported by the adapter
+*/
+func (s SavingInterval) Valid() bool {
+	return s.Duration() > 0
+}
+
+/*
+Duration returns the time between two lines of daten.csv. Zero is returned for an unknown saving interval.
+*/
+func (s SavingInterval) Duration() time.Duration {
+	switch s {
+	case SavingInterval15Min:
+		return 15 * time.Minute
+	case SavingIntervalMinute:
+		return time.Minute
+	case SavingIntervalSecond:
+		return time.Second
+	default:
+		return 0
+	}
+}
+
+/*
+LinesPerHour returns the number of lines daten.csv receives per hour
+*/
+func (s SavingInterval) LinesPerHour() int {
+	return s.lines(time.Hour)
+}
+
+/*
+LinesPerDay returns the number of lines daten.csv receives per day
+*/
+func (s SavingInterval) LinesPerDay() int {
+	return s.lines(24 * time.Hour)
+}
+
+/*
+Window returns the time span covered by the given number of lines of daten.csv
+*/
+func (s SavingInterval) Window(lines int) time.Duration {
+	return time.Duration(lines) * s.Duration()
+}
+
+func (s SavingInterval) lines(window time.Duration) int {
+	if !s.Valid() {
+		return 0
+	}
+	return int(window / s.Duration())
+}
+
+/*
+String returns the request representation of the saving interval
+*/
+func (s SavingInterval) String() string {
+	return string(s)
+}
+
+/*
+parseSavingIntervalField converts a saving interval reported by the adapter. Unknown values are kept as reported, so
+they can be inspected, but aren't Valid.
+*/
+func parseSavingIntervalField(interval string) SavingInterval {
+	parsed, err := ParseSavingInterval(interval)
+	if err != nil {
+		return SavingInterval(interval)
+	}
+	return parsed
+}
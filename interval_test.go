@@ -0,0 +1,57 @@
+package dvlirclient
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+/*
+TestSavingInterval covers:
+	- ParseSavingInterval with every representation
+	- Duration, LinesPerHour, LinesPerDay and Window
+	- ParseSystemInfo with a spelled out saving interval
+	- ChangeSavingInterval with an invalid saving interval
+*/
+func TestSavingInterval(t *testing.T) {
+	for input, expected := range map[string]SavingInterval{
+		"15min":  SavingInterval15Min,
+		"15 Min": SavingInterval15Min,
+		"900s":   SavingInterval15Min,
+		"min":    SavingIntervalMinute,
+		"1 min":  SavingIntervalMinute,
+		"sec":    SavingIntervalSecond,
+		"1sec":   SavingIntervalSecond,
+	} {
+		interval, err := ParseSavingInterval(input)
+		if assert.NoError(t, err, "Error while parsing %q", input) {
+			assert.Equal(t, expected, interval, "Wrong saving interval for %q", input)
+		}
+	}
+	_, err := ParseSavingInterval("hourly")
+	assert.Error(t, err, "Unknown saving interval wasn't reported")
+
+	assert.Equal(t, 15*time.Minute, SavingInterval15Min.Duration())
+	assert.Equal(t, 4, SavingInterval15Min.LinesPerHour())
+	assert.Equal(t, 96, SavingInterval15Min.LinesPerDay())
+	assert.Equal(t, 1440, SavingIntervalMinute.LinesPerDay())
+	assert.Equal(t, 3600, SavingIntervalSecond.LinesPerHour())
+	assert.Equal(t, 4*time.Hour, SavingIntervalSecond.Window(14400))
+	assert.Equal(t, 0, SavingInterval("hourly").LinesPerDay())
+
+	system, err := ParseSystemInfo("15 min#4711#0815#no")
+	if assert.NoError(t, err, "Error while parsing system information") {
+		assert.Equal(t, SavingInterval15Min, system.SavingInterval)
+	}
+	system, err = ParseSystemInfo("hourly#4711#0815#no")
+	if assert.NoError(t, err, "Unknown saving interval caused an error") {
+		assert.Equal(t, SavingInterval("hourly"), system.SavingInterval)
+		assert.False(t, system.SavingInterval.Valid())
+	}
+
+	dvlirClient, err := NewDvLIRClient("127.0.0.1", "dvlir")
+	if assert.NoError(t, err, "Error while creating client") {
+		_, err = dvlirClient.ChangeSavingInterval("hourly")
+		assert.Error(t, err, "Invalid saving interval wasn't rejected")
+	}
+}
@@ -0,0 +1,241 @@
+package dvlirclient
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+/*
+MigrateOptions configures MigrateAddress
+*/
+type MigrateOptions struct {
+	//Address is the address the adapter is expected at after the change. If it is empty, the IPAddress of the settings
+	//is used with the port of the current address.
+	Address string
+	//Timeout limits how long MigrateAddress waits for the adapter, the default is 2 minutes
+	Timeout time.Duration
+	//PollInterval is the time between two attempts to reach the adapter, the default is 2 seconds
+	PollInterval time.Duration
+	//AttemptTimeout limits a single attempt to reach the adapter, the default is 2 seconds
+	AttemptTimeout time.Duration
+	//Locate finds the address of the adapter with the MAC address, e.g. with discovery.Locator. It is used if neither
+	//Address nor the IPAddress of the settings is set, which is the case if the adapter switches to DHCP.
+	Locate func(ctx context.Context, mac string) (string, error)
+}
+
+/*
+MigrationReport describes the outcome of MigrateAddress
+*/
+type MigrationReport struct {
+	//OldAddress is the base url of the adapter before the change
+	OldAddress string
+	//NewAddress is the base url the adapter was expected at after the change
+	NewAddress string
+	//MACAddress is the MAC address of the adapter
+	MACAddress string
+	//Attempts is the number of attempts to reach the adapter at the new address
+	Attempts int
+	//Elapsed is the time from applying the settings until the adapter was found or the timeout expired
+	Elapsed time.Duration
+	//LastError is the error of the last failed attempt to reach the adapter
+	LastError error
+}
+
+/*
+MigrateAddress changes the network settings of the adapter and follows it to its new address.
+
+The settings are validated and applied, then the new address is polled until the adapter accepts a login. Errors of
+the change request are returned right away, unless the connection broke after the request was sent, which happens if
+the adapter switches its address before it answers. The client only switches to the new address if the adapter found
+there has the same MAC address as before. If the adapter doesn't answer in time, an error wrapping ErrAdapterNotFound
+is returned together with a report of the attempts. The client must not be used concurrently while the migration is
+running.
+*/
+func (d *DvLIRClient) MigrateAddress(ctx context.Context, settings NetworkSettings, opts MigrateOptions) (*MigrationReport, error) {
+	if !d.isValid() {
+		return nil, &NotValidError{}
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 2 * time.Minute
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = 2 * time.Second
+	}
+	if opts.AttemptTimeout <= 0 {
+		opts.AttemptTimeout = 2 * time.Second
+	}
+
+	report := &MigrationReport{OldAddress: d.baseURL}
+	newBase, err := d.migrationTarget(settings, opts)
+	if err != nil {
+		return report, err
+	}
+
+	info, err := d.GetGeneralInformationContext(ctx)
+	if err != nil {
+		return report, errors.Wrap(err, "Error while identifying the adapter")
+	}
+	report.MACAddress = info.MACAddress
+
+	if err := d.validateNetworkSettings(ctx, settings); err != nil {
+		return report, err
+	}
+	//The connection breaks if the adapter switches its address before the response is sent, other errors mean that
+	//the settings weren't applied
+	if _, err := d.changeNetworkSettings(ctx, settings); err != nil && !responseLost(err) {
+		return report, errors.Wrap(err, "Error while changing the network settings")
+	}
+
+	start := time.Now()
+	waitCtx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+	if newBase == "" {
+		newBase, report.Attempts, report.LastError = locate(waitCtx, opts.Locate, report.MACAddress, opts.PollInterval)
+	}
+	report.NewAddress = newBase
+
+	probe := d.withBaseURL(newBase)
+	if report.LastError == nil {
+		var attempts int
+		attempts, report.LastError = waitForLogin(waitCtx, probe, opts.PollInterval, opts.PollInterval, opts.AttemptTimeout)
+		report.Attempts += attempts
+	}
+	report.Elapsed = time.Since(start)
+	if report.LastError != nil {
+		at := newBase
+		if at == "" {
+			at = "any address"
+		}
+		return report, errors.Wrap(ErrAdapterNotFound, "Adapter "+report.MACAddress+" didn't answer at "+at+
+			" within "+opts.Timeout.String()+" after "+strconv.Itoa(report.Attempts)+" attempts, last error: "+
+			report.LastError.Error())
+	}
+
+	found, err := probe.GetGeneralInformationContext(ctx)
+	if err != nil {
+		return report, errors.Wrap(err, "Error while identifying the adapter at "+newBase)
+	}
+	if NormalizeMAC(found.MACAddress) != NormalizeMAC(report.MACAddress) {
+		return report, errors.Wrap(ErrDeviceMismatch, "Adapter "+found.MACAddress+" answered at "+newBase+
+			" instead of "+report.MACAddress)
+	}
+
+	d.ipAddress = probe.ipAddress
+	d.baseURL = probe.baseURL
+	d.session.start(probe.session.current())
+	return report, nil
+}
+
+/*
+migrationTarget returns the base url the adapter is expected at after the settings were applied
+*/
+func (d *DvLIRClient) migrationTarget(settings NetworkSettings, opts MigrateOptions) (string, error) {
+	if opts.Address != "" {
+		return baseURL(opts.Address)
+	}
+	if settings.IPAddress == nil {
+		if opts.Locate != nil {
+			//The address is located after the settings were applied
+			return "", nil
+		}
+		return "", errors.New("The new address of the adapter is unknown, MigrateOptions.Address or Locate has to be set")
+	}
+
+	current, err := url.Parse(d.baseURL)
+	if err != nil {
+		return "", errors.Wrap(err, "invalid address")
+	}
+	host := settings.IPAddress.String()
+	if port := current.Port(); port != "" {
+		host = net.JoinHostPort(host, port)
+	}
+	return baseURL(current.Scheme + "://" + host + current.Path)
+}
+
+/*
+withBaseURL returns a client for the adapter at another base url, which shares the settings of d but has its own
+session
+*/
+func (d *DvLIRClient) withBaseURL(base string) *DvLIRClient {
+	data := *d.clientData
+	data.ipAddress = strings.TrimPrefix(strings.TrimPrefix(base, "http://"), "https://")
+	data.baseURL = base
+	data.session = &session{password: d.session.secret()}
+	return &DvLIRClient{client{&data}}
+}
+
+/*
+locate calls the locate function until it finds the adapter or ctx is done. It returns the base url of the adapter,
+the number of attempts and the error of the last attempt, which is nil on success.
+*/
+func locate(ctx context.Context, locate func(ctx context.Context, mac string) (string, error), mac string, interval time.Duration) (string, int, error) {
+	attempts := 0
+	for {
+		attempts++
+		address, err := locate(ctx, mac)
+		if err == nil {
+			address, err = baseURL(address)
+			if err == nil {
+				return address, attempts, nil
+			}
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return "", attempts, err
+		}
+	}
+}
+
+/*
+waitForLogin polls the adapter until a login succeeds or ctx is done. The interval between two attempts doubles after
+every failed attempt up to maxInterval. It returns the number of attempts and the error of the last attempt, which is
+nil on success.
+*/
+func waitForLogin(ctx context.Context, d *DvLIRClient, interval, maxInterval, attemptTimeout time.Duration) (int, error) {
+	attempts := 0
+	for {
+		attempts++
+		attemptCtx, cancel := context.WithTimeout(ctx, attemptTimeout)
+		err := d.LoginContext(attemptCtx)
+		cancel()
+		if err == nil {
+			return attempts, nil
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return attempts, err
+		}
+		if interval *= 2; interval > maxInterval {
+			interval = maxInterval
+		}
+	}
+}
+
+/*
+responseLost returns true if the connection broke after the request was sent, so the request may have been executed
+*/
+func responseLost(err error) bool {
+	var opError *net.OpError
+	if errors.As(err, &opError) && opError.Op == "dial" {
+		//The request was never sent
+		return false
+	}
+	var netError net.Error
+	return errors.As(err, &netError) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET)
+}
@@ -0,0 +1,87 @@
+package dvlirclient
+
+import (
+	"context"
+	"github.com/inexio/dvlir-restapi-go-client/dvlirtest"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+/*
+TestDvLIRClient_MigrateAddress covers:
+	- MigrateAddress to a new static address
+	- MigrateAddress to an address the adapter doesn't answer at
+	- MigrateAddress with a different adapter at the new address
+	- MigrateAddress with settings rejected by the adapter
+	- MigrateAddress with settings applied, but a lost response
+*/
+func TestDvLIRClient_MigrateAddress(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.2:0")
+	if err != nil {
+		t.Skip("127.0.0.2 isn't available: ", err)
+	}
+	listener.Close()
+
+	dvlirClient, server := newSimulatedClient(t, dvlirtest.WithRebinding())
+	defer server.Close()
+	ctx := context.Background()
+
+	settings := NetworkSettings{}
+	settings.SetStatic(net.ParseIP("127.0.0.2"), net.IPv4Mask(255, 0, 0, 0), net.ParseIP("127.0.0.1"))
+	opts := MigrateOptions{Timeout: 5 * time.Second, PollInterval: 20 * time.Millisecond}
+	report, err := dvlirClient.MigrateAddress(ctx, settings, opts)
+	if !assert.NoError(t, err, "Error during MigrateAddress") {
+		return
+	}
+	assert.Equal(t, "http://"+server.Address(), report.NewAddress)
+	assert.Equal(t, server.State().MACAddress, report.MACAddress)
+	assert.True(t, report.Attempts >= 1)
+
+	info, err := dvlirClient.GetGeneralInformation()
+	if assert.NoError(t, err, "Client didn't follow the adapter") {
+		assert.Equal(t, "127.0.0.2", info.IPAddress)
+	}
+
+	settings.SetStatic(net.ParseIP("192.0.2.10"), net.IPv4Mask(255, 255, 255, 0), net.ParseIP("192.0.2.1"))
+	opts = MigrateOptions{Timeout: 300 * time.Millisecond, PollInterval: 20 * time.Millisecond, AttemptTimeout: 50 * time.Millisecond}
+	report, err = dvlirClient.MigrateAddress(ctx, settings, opts)
+	assert.True(t, errors.Is(err, ErrAdapterNotFound), "ErrAdapterNotFound wasn't returned: %v", err)
+	if assert.NotNil(t, report) {
+		assert.True(t, report.Attempts >= 1)
+		assert.Error(t, report.LastError)
+	}
+	_, err = dvlirClient.GetGeneralInformation()
+	assert.NoError(t, err, "Client didn't stay at the old address")
+
+	other := dvlirtest.NewServer(dvlirtest.WithState(func() dvlirtest.State {
+		state := dvlirtest.DefaultState()
+		state.MACAddress = "00:50:C2:9F:10:02"
+		return state
+	}()))
+	defer other.Close()
+	opts = MigrateOptions{Address: other.Address(), Timeout: time.Second, PollInterval: 20 * time.Millisecond}
+	_, err = dvlirClient.MigrateAddress(ctx, NetworkSettings{DNSServer: net.ParseIP("192.0.2.53")}, opts)
+	assert.True(t, errors.Is(err, ErrDeviceMismatch), "ErrDeviceMismatch wasn't returned: %v", err)
+	_, err = dvlirClient.GetGeneralInformation()
+	assert.NoError(t, err, "Client didn't stay at the old address")
+	start := time.Now()
+	server.Inject(dvlirtest.Rule{Endpoint: "/network.cmd", Times: 1, Fault: dvlirtest.Status(http.StatusInternalServerError, "flash error")})
+	opts = MigrateOptions{Address: server.Address(), Timeout: 5 * time.Second, PollInterval: 20 * time.Millisecond}
+	_, err = dvlirClient.MigrateAddress(ctx, NetworkSettings{DNSServer: net.ParseIP("192.0.2.53")}, opts)
+	var httpError HTTPError
+	if assert.True(t, errors.As(err, &httpError), "HTTPError wasn't returned: %v", err) {
+		assert.Equal(t, http.StatusInternalServerError, httpError.StatusCode)
+	}
+	assert.True(t, time.Since(start) < time.Second, "MigrateAddress waited for the adapter after a rejected request")
+
+	server.Inject(dvlirtest.Rule{Endpoint: "/network.cmd", Times: 1, Fault: dvlirtest.DropResponse()})
+	report, err = dvlirClient.MigrateAddress(ctx, NetworkSettings{DNSServer: net.ParseIP("192.0.2.53")}, opts)
+	if assert.NoError(t, err, "Lost response wasn't tolerated") {
+		assert.Equal(t, "http://"+server.Address(), report.NewAddress)
+	}
+	assert.Equal(t, "192.0.2.53", server.State().DNSServer, "Settings weren't applied")
+}
@@ -0,0 +1,231 @@
+package dvlirclient
+
+import (
+	"context"
+	"github.com/inexio/dvlir-restapi-go-client/internal/hostname"
+	"github.com/pkg/errors"
+	"net"
+	"time"
+)
+
+/*
+NetworkSettings contains the network settings of the adapter that can be changed with ApplyNetworkSettings. Fields that
+are nil or zero are left unchanged.
+*/
+type NetworkSettings struct {
+	//DHCP enables or disables the DHCP client of the adapter
+	DHCP *bool
+	//IPAddress is the static IPv4 address of the adapter
+	IPAddress net.IP
+	//SubnetMask is the IPv4 subnet mask of the adapter
+	SubnetMask net.IPMask
+	//Gateway is the IPv4 address of the default gateway
+	Gateway net.IP
+	//DNSServer is the IPv4 address of the DNS server
+	DNSServer net.IP
+	//NTPName is the host name or IP address of the NTP server
+	NTPName string
+	//NTPServer enables or disables the time synchronization via NTP
+	NTPServer *bool
+	//DateTime sets the clock of the adapter, the wall clock of the time is sent as is
+	DateTime time.Time
+}
+
+/*
+SetDHCP enables or disables the DHCP client of the adapter
+*/
+func (s *NetworkSettings) SetDHCP(enabled bool) *NetworkSettings {
+	s.DHCP = &enabled
+	return s
+}
+
+/*
+SetStatic sets a static IPv4 address, subnet mask and gateway and disables the DHCP client
+*/
+func (s *NetworkSettings) SetStatic(ip net.IP, mask net.IPMask, gateway net.IP) *NetworkSettings {
+	s.SetDHCP(false)
+	s.IPAddress = ip
+	s.SubnetMask = mask
+	s.Gateway = gateway
+	return s
+}
+
+/*
+SetDNSServer sets the DNS server
+*/
+func (s *NetworkSettings) SetDNSServer(dns net.IP) *NetworkSettings {
+	s.DNSServer = dns
+	return s
+}
+
+/*
+SetNTP enables or disables the time synchronization via NTP with the given server. An empty name keeps the current
+server.
+*/
+func (s *NetworkSettings) SetNTP(enabled bool, name string) *NetworkSettings {
+	s.NTPServer = &enabled
+	s.NTPName = name
+	return s
+}
+
+/*
+SetDateTime sets the clock of the adapter
+*/
+func (s *NetworkSettings) SetDateTime(t time.Time) *NetworkSettings {
+	s.DateTime = t
+	return s
+}
+
+/*
+Validate checks the settings before they are sent to the adapter, which accepts invalid addresses without complaint.
+The returned error is a *FieldError for the first invalid field. The address and gateway are only checked against the
+subnet if IPAddress and SubnetMask are both set, ApplyNetworkSettings completes them with the current settings of the
+adapter before validating.
+*/
+func (s *NetworkSettings) Validate() error {
+	if s.DHCP != nil && *s.DHCP && s.IPAddress != nil {
+		return &FieldError{Field: "IPAddress", Value: s.IPAddress.String(), Err: errors.New("static address while DHCP is enabled")}
+	}
+	if s.SubnetMask != nil {
+		if !validSubnetMask(s.SubnetMask) {
+			return &FieldError{Field: "SubnetMask", Value: net.IP(s.SubnetMask).String(), Err: errors.New("invalid IPv4 subnet mask")}
+		}
+	}
+	for _, address := range []struct {
+		field string
+		ip    net.IP
+	}{{"IPAddress", s.IPAddress}, {"Gateway", s.Gateway}, {"DNSServer", s.DNSServer}} {
+		if address.ip != nil && !validHostAddress(address.ip) {
+			return &FieldError{Field: address.field, Value: address.ip.String(), Err: errors.New("invalid IPv4 host address")}
+		}
+	}
+	if s.IPAddress != nil && s.SubnetMask != nil {
+		subnet := net.IPNet{IP: s.IPAddress.To4().Mask(s.SubnetMask), Mask: s.SubnetMask}
+		broadcast := make(net.IP, net.IPv4len)
+		for i := range broadcast {
+			broadcast[i] = subnet.IP[i] | ^s.SubnetMask[i]
+		}
+		if s.IPAddress.Equal(subnet.IP) || s.IPAddress.Equal(broadcast) {
+			return &FieldError{Field: "IPAddress", Value: s.IPAddress.String(), Err: errors.New("network or broadcast address of " + subnet.String())}
+		}
+		if s.Gateway != nil && !subnet.Contains(s.Gateway) {
+			return &FieldError{Field: "Gateway", Value: s.Gateway.String(), Err: errors.New("outside of subnet " + subnet.String())}
+		}
+		if s.Gateway != nil && s.Gateway.Equal(s.IPAddress) {
+			return &FieldError{Field: "Gateway", Value: s.Gateway.String(), Err: errors.New("same as IPAddress")}
+		}
+	}
+	if s.NTPName != "" && !hostname.Valid(s.NTPName) {
+		return &FieldError{Field: "NTPName", Value: s.NTPName, Err: errors.New("invalid host name")}
+	}
+	return nil
+}
+
+/*
+arguments returns the settings as arguments of ChangeNetworkSettings
+*/
+func (s *NetworkSettings) arguments() (dhcp, ip, sub, gw, dns, ntpName, ntpServer, setDt string) {
+	if s.DHCP != nil {
+		dhcp = yesNo(*s.DHCP)
+	}
+	if s.IPAddress != nil {
+		ip = s.IPAddress.String()
+	}
+	if s.SubnetMask != nil {
+		sub = net.IP(s.SubnetMask).String()
+	}
+	if s.Gateway != nil {
+		gw = s.Gateway.String()
+	}
+	if s.DNSServer != nil {
+		dns = s.DNSServer.String()
+	}
+	ntpName = s.NTPName
+	if s.NTPServer != nil {
+		ntpServer = yesNo(*s.NTPServer)
+	}
+	if !s.DateTime.IsZero() {
+		setDt = s.DateTime.Format(dateLayout + " " + timeLayout)
+	}
+	return
+}
+
+/*
+ApplyNetworkSettings validates the settings and changes them with a ChangeNetworkSettings request. If only some of the
+IP address, subnet mask and gateway change, the others are taken from the current settings of the adapter for the
+validation, so e.g. a new gateway is checked against the current subnet.
+*/
+func (d *DvLIRClient) ApplyNetworkSettings(ctx context.Context, s NetworkSettings) (string, error) {
+	if err := d.validateNetworkSettings(ctx, s); err != nil {
+		return "", err
+	}
+	return d.changeNetworkSettings(ctx, s)
+}
+
+/*
+validateNetworkSettings validates the settings completed with the current settings of the adapter
+*/
+func (d *DvLIRClient) validateNetworkSettings(ctx context.Context, s NetworkSettings) error {
+	merged, err := d.withCurrentAddress(ctx, s)
+	if err != nil {
+		return err
+	}
+	return errors.Wrap(merged.Validate(), "Invalid network settings")
+}
+
+/*
+changeNetworkSettings sends the settings with a ChangeNetworkSettings request without validating them
+*/
+func (d *DvLIRClient) changeNetworkSettings(ctx context.Context, s NetworkSettings) (string, error) {
+	dhcp, ip, sub, gw, dns, ntpName, ntpServer, setDt := s.arguments()
+	return d.ChangeNetworkSettingsContext(ctx, dhcp, ip, sub, gw, dns, ntpName, ntpServer, setDt)
+}
+
+/*
+withCurrentAddress returns the settings completed with the current IP address, subnet mask and gateway of the adapter
+if only some of them are set. Current values that aren't valid are left out.
+*/
+func (d *DvLIRClient) withCurrentAddress(ctx context.Context, s NetworkSettings) (NetworkSettings, error) {
+	if s.DHCP != nil && *s.DHCP || s.IPAddress == nil && s.SubnetMask == nil && s.Gateway == nil ||
+		s.IPAddress != nil && s.SubnetMask != nil && s.Gateway != nil {
+		return s, nil
+	}
+	current, err := d.GetNetworkInformationContext(ctx)
+	if err != nil {
+		return s, errors.Wrap(err, "Error while reading the current network settings")
+	}
+	if ip := net.ParseIP(current.IPAddress); s.IPAddress == nil && ip != nil && validHostAddress(ip) {
+		s.IPAddress = ip.To4()
+	}
+	//An adapter without a DHCP lease reports a mask like 0.0.0.0, which Validate would reject
+	if mask := net.IPMask(net.ParseIP(current.SubnetMask).To4()); s.SubnetMask == nil && mask != nil && validSubnetMask(mask) {
+		s.SubnetMask = mask
+	}
+	if gateway := net.ParseIP(current.Gateway); s.Gateway == nil && gateway != nil && validHostAddress(gateway) {
+		s.Gateway = gateway.To4()
+	}
+	return s, nil
+}
+
+/*
+validSubnetMask returns true if mask is an IPv4 subnet mask with a prefix length between 1 and 30
+*/
+func validSubnetMask(mask net.IPMask) bool {
+	ones, bits := mask.Size()
+	return bits == 8*net.IPv4len && ones >= 1 && ones <= 30
+}
+
+/*
+validHostAddress returns true if ip is an IPv4 address that can be assigned to a host
+*/
+func validHostAddress(ip net.IP) bool {
+	ip4 := ip.To4()
+	return ip4 != nil && !ip4.IsUnspecified() && !ip4.IsMulticast() && !ip4.Equal(net.IPv4bcast)
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
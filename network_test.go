@@ -0,0 +1,128 @@
+package dvlirclient
+
+import (
+	"context"
+	"github.com/inexio/dvlir-restapi-go-client/dvlirtest"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+/*
+queryTransport records the queries of the requests to network.cmd
+*/
+type queryTransport struct {
+	mu      sync.Mutex
+	queries []string
+}
+
+func (q *queryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Path == "/network.cmd" {
+		q.mu.Lock()
+		q.queries = append(q.queries, req.URL.RawQuery)
+		q.mu.Unlock()
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+/*
+TestNetworkSettings_Validate covers:
+	- Validate with valid settings
+	- Validate with a gateway outside the subnet
+	- Validate with an invalid subnet mask
+	- Validate with a static address while DHCP is enabled
+	- Validate with invalid addresses and NTP names
+*/
+func TestNetworkSettings_Validate(t *testing.T) {
+	valid := (&NetworkSettings{}).
+		SetStatic(net.ParseIP("192.168.0.100"), net.IPv4Mask(255, 255, 255, 0), net.ParseIP("192.168.0.1")).
+		SetDNSServer(net.ParseIP("192.168.0.1")).
+		SetNTP(true, "de.pool.ntp.org")
+	assert.NoError(t, valid.Validate())
+
+	for field, settings := range map[string]*NetworkSettings{
+		"Gateway":    (&NetworkSettings{}).SetStatic(net.ParseIP("192.168.0.100"), net.IPv4Mask(255, 255, 255, 0), net.ParseIP("192.168.1.1")),
+		"SubnetMask": {SubnetMask: net.IPv4Mask(255, 0, 255, 0)},
+		"IPAddress":  (&NetworkSettings{IPAddress: net.ParseIP("192.168.0.100")}).SetDHCP(true),
+		"DNSServer":  {DNSServer: net.ParseIP("224.0.0.1")},
+		"NTPName":    {NTPName: "pool ntp org"},
+	} {
+		err := settings.Validate()
+		var fieldError *FieldError
+		if assert.True(t, errors.As(err, &fieldError), "Invalid %s wasn't reported", field) {
+			assert.Equal(t, field, fieldError.Field)
+		}
+	}
+
+	broadcast := (&NetworkSettings{}).SetStatic(net.ParseIP("192.168.0.255"), net.IPv4Mask(255, 255, 255, 0), net.ParseIP("192.168.0.1"))
+	assert.Error(t, broadcast.Validate(), "Broadcast address wasn't reported")
+}
+
+/*
+TestDvLIRClient_ApplyNetworkSettings covers:
+	- ApplyNetworkSettings compared to ChangeNetworkSettings
+	- ApplyNetworkSettings with invalid settings
+	- ApplyNetworkSettings checks a new gateway against the current subnet
+	- ApplyNetworkSettings ignores an invalid current subnet mask
+*/
+func TestDvLIRClient_ApplyNetworkSettings(t *testing.T) {
+	server := dvlirtest.NewServer()
+	defer server.Close()
+	transport := &queryTransport{}
+	dvlirClient, err := NewDvLIRClient(server.Address(), server.Password(), WithTransport(transport))
+	if err == nil {
+		err = dvlirClient.Login()
+	}
+	if !assert.NoError(t, err, "Error during Login") {
+		return
+	}
+
+	_, err = dvlirClient.ChangeNetworkSettings("no", "192.168.0.100", "255.255.255.0", "192.168.0.1", "192.168.0.1", "de.pool.ntp.org", "yes", "01.02.2020 12:30:00")
+	if !assert.NoError(t, err, "Error during ChangeNetworkSettings request") {
+		return
+	}
+
+	settings := NetworkSettings{}
+	settings.
+		SetStatic(net.ParseIP("192.168.0.100"), net.IPv4Mask(255, 255, 255, 0), net.ParseIP("192.168.0.1")).
+		SetDNSServer(net.ParseIP("192.168.0.1")).
+		SetNTP(true, "de.pool.ntp.org").
+		SetDateTime(time.Date(2020, time.February, 1, 12, 30, 0, 0, time.UTC))
+	_, err = dvlirClient.ApplyNetworkSettings(context.Background(), settings)
+	if !assert.NoError(t, err, "Error during ApplyNetworkSettings request") {
+		return
+	}
+	if assert.Len(t, transport.queries, 2) {
+		assert.Equal(t, transport.queries[0], transport.queries[1], "Queries differ")
+	}
+	assert.Equal(t, "192.168.0.100", server.State().IPAddress)
+
+	settings.Gateway = net.ParseIP("10.0.0.1")
+	_, err = dvlirClient.ApplyNetworkSettings(context.Background(), settings)
+	assert.Error(t, err, "Invalid settings weren't rejected")
+	assert.Len(t, transport.queries, 2, "Invalid settings were sent")
+
+	gateway := NetworkSettings{Gateway: net.ParseIP("10.0.0.1")}
+	_, err = dvlirClient.ApplyNetworkSettings(context.Background(), gateway)
+	var fieldError *FieldError
+	if assert.True(t, errors.As(err, &fieldError), "Gateway outside of the current subnet wasn't rejected: %v", err) {
+		assert.Equal(t, "Gateway", fieldError.Field)
+	}
+	gateway.Gateway = net.ParseIP("192.168.0.254")
+	_, err = dvlirClient.ApplyNetworkSettings(context.Background(), gateway)
+	if assert.NoError(t, err, "Error during ApplyNetworkSettings request") {
+		assert.Equal(t, "192.168.0.254", server.State().Gateway)
+	}
+
+	//An adapter without a DHCP lease reports an empty subnet
+	server.Update(func(state *dvlirtest.State) { state.SubnetMask = "0.0.0.0" })
+	gateway.Gateway = net.ParseIP("10.0.0.1")
+	_, err = dvlirClient.ApplyNetworkSettings(context.Background(), gateway)
+	if assert.NoError(t, err, "Invalid current subnet mask was validated") {
+		assert.Equal(t, "10.0.0.1", server.State().Gateway)
+	}
+}
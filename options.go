@@ -0,0 +1,168 @@
+package dvlirclient
+
+import (
+	"crypto/tls"
+	"github.com/go-resty/resty/v2"
+	"github.com/pkg/errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+/*
+clientOptions contains the settings collected from the options passed to NewDvLIRClient
+*/
+type clientOptions struct {
+	baseURL   string
+	timeout   time.Duration
+	transport http.RoundTripper
+	tlsConfig *tls.Config
+	proxyURL  string
+	userAgent string
+	resty     *resty.Client
+
+	renewSession bool
+}
+
+/*
+Option configures a DvLIRClient on creation
+*/
+type Option func(*clientOptions)
+
+/*
+WithBaseURL sets the url all requests are sent to, e.g. "https://proxy.example.com/dvlir". It overrides the address
+passed to NewDvLIRClient and can be used for adapters behind a port-forward, a reverse proxy or HTTPS termination.
+*/
+func WithBaseURL(baseURL string) Option {
+	return func(o *clientOptions) {
+		o.baseURL = baseURL
+	}
+}
+
+/*
+WithTimeout sets the timeout of every http request
+*/
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *clientOptions) {
+		o.timeout = timeout
+	}
+}
+
+/*
+WithTransport sets the http.RoundTripper used for all requests
+*/
+func WithTransport(transport http.RoundTripper) Option {
+	return func(o *clientOptions) {
+		o.transport = transport
+	}
+}
+
+/*
+WithTLSConfig sets the TLS configuration used for HTTPS connections. It only takes effect if the transport is a
+*http.Transport.
+*/
+func WithTLSConfig(config *tls.Config) Option {
+	return func(o *clientOptions) {
+		o.tlsConfig = config
+	}
+}
+
+/*
+WithProxy sends all requests through the given proxy, e.g. "http://proxy.example.com:3128". It only takes effect if the
+transport is a *http.Transport.
+*/
+func WithProxy(proxyURL string) Option {
+	return func(o *clientOptions) {
+		o.proxyURL = proxyURL
+	}
+}
+
+/*
+WithUserAgent sets the User-Agent header of all requests
+*/
+func WithUserAgent(userAgent string) Option {
+	return func(o *clientOptions) {
+		o.userAgent = userAgent
+	}
+}
+
+/*
+WithRestyClient uses a caller-supplied resty client for all requests. The other options are applied to this client.
+*/
+func WithRestyClient(client *resty.Client) Option {
+	return func(o *clientOptions) {
+		o.resty = client
+	}
+}
+
+/*
+WithSessionRenewal enables the automatic renewal of expired sessions. If the adapter returns its login page instead of
+the requested data, the client logs in again with its password and replays the request once.
+*/
+func WithSessionRenewal() Option {
+	return func(o *clientOptions) {
+		o.renewSession = true
+	}
+}
+
+/*
+baseURL returns the base url for the given address. The address can be an IP address or host name with an optional
+port, an IPv6 literal or a complete url.
+*/
+func baseURL(address string) (string, error) {
+	if !strings.Contains(address, "://") {
+		if ip := net.ParseIP(address); ip != nil && ip.To4() == nil {
+			address = "[" + address + "]"
+		}
+		address = "http://" + address
+	}
+
+	u, err := url.Parse(address)
+	if err != nil {
+		return "", errors.Wrap(err, "invalid address")
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", errors.New("invalid address: unsupported scheme " + u.Scheme)
+	}
+	if u.Host == "" {
+		return "", errors.New("invalid address: missing host")
+	}
+	return strings.TrimSuffix(u.String(), "/"), nil
+}
+
+/*
+newResty creates the resty client according to the options
+*/
+func (o *clientOptions) newResty() (*resty.Client, error) {
+	client := o.resty
+	if client == nil {
+		client = resty.New()
+	}
+
+	if o.transport != nil {
+		client.SetTransport(o.transport)
+	}
+	if o.tlsConfig != nil || o.proxyURL != "" {
+		if _, ok := client.GetClient().Transport.(*http.Transport); !ok {
+			return nil, errors.New("TLS config and proxy can only be set for a *http.Transport")
+		}
+	}
+	if o.tlsConfig != nil {
+		client.SetTLSClientConfig(o.tlsConfig)
+	}
+	if o.proxyURL != "" {
+		if _, err := url.Parse(o.proxyURL); err != nil {
+			return nil, errors.Wrap(err, "invalid proxy url")
+		}
+		client.SetProxy(o.proxyURL)
+	}
+	if o.timeout > 0 {
+		client.SetTimeout(o.timeout)
+	}
+	if o.userAgent != "" {
+		client.SetHeader("User-Agent", o.userAgent)
+	}
+	return client, nil
+}
@@ -0,0 +1,99 @@
+package dvlirclient
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+/*
+countingTransport counts the requests and user agents passing through it
+*/
+type countingTransport struct {
+	mu         sync.Mutex
+	requests   map[string]int
+	userAgents map[string]bool
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.mu.Lock()
+	c.requests[req.URL.Path]++
+	c.userAgents[req.Header.Get("User-Agent")] = true
+	c.mu.Unlock()
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+/*
+TestNewDvLIRClient_Options covers:
+	- WithBaseURL behind a reverse proxy with a path prefix
+	- WithTransport
+	- WithUserAgent
+	- WithTimeout
+*/
+func TestNewDvLIRClient_Options(t *testing.T) {
+	_, server := newSimulatedClient(t)
+	defer server.Close()
+
+	proxy := httptest.NewServer(http.StripPrefix("/dvlir", server))
+	defer proxy.Close()
+
+	transport := &countingTransport{requests: map[string]int{}, userAgents: map[string]bool{}}
+	dvlirClient, err := NewDvLIRClient("unused", server.Password(),
+		WithBaseURL(proxy.URL+"/dvlir/"),
+		WithTransport(transport),
+		WithUserAgent("dvlir-test"),
+		WithTimeout(5*time.Second),
+	)
+	if !assert.NoError(t, err, "Error while creating Api client") {
+		return
+	}
+
+	err = dvlirClient.Login()
+	if !assert.NoError(t, err, "Error during Login") {
+		return
+	}
+	_, err = dvlirClient.ChangePassword(server.Password(), "secret", "secret")
+	if !assert.NoError(t, err, "Error during ChangePassword request") {
+		return
+	}
+	_, err = dvlirClient.UploadFirmware("LICENSE")
+	if !assert.NoError(t, err, "Error during UploadFirmware request") {
+		return
+	}
+
+	assert.Equal(t, "secret", server.Password(), "Password wasn't changed")
+	assert.Equal(t, map[string]int{"/dvlir/getSID.txt": 1, "/dvlir/password.cmd": 1, "/dvlir/upload.cmd": 1}, transport.requests)
+	assert.Equal(t, map[string]bool{"dvlir-test": true}, transport.userAgents)
+}
+
+/*
+TestNewDvLIRClient_Address covers:
+	- NewDvLIRClient with IPv4, IPv6 and url addresses
+	- NewDvLIRClient with invalid options
+*/
+func TestNewDvLIRClient_Address(t *testing.T) {
+	for address, expected := range map[string]string{
+		"192.168.0.100":                "http://192.168.0.100",
+		"192.168.0.100:8080":           "http://192.168.0.100:8080",
+		"fe80::1":                      "http://[fe80::1]",
+		"[fe80::1]:8080":               "http://[fe80::1]:8080",
+		"https://proxy.example.com/a/": "https://proxy.example.com/a",
+	} {
+		dvlirClient, err := NewDvLIRClient(address, "pw")
+		if assert.NoError(t, err, "Error while creating Api client for "+address) {
+			assert.Equal(t, expected, dvlirClient.baseURL)
+		}
+	}
+
+	_, err := NewDvLIRClient("ftp://192.168.0.100", "pw")
+	assert.Error(t, err, "Unsupported scheme wasn't reported")
+
+	_, err = NewDvLIRClient("192.168.0.100", "pw", WithTransport(&countingTransport{}), WithProxy("http://proxy:3128"))
+	if assert.Error(t, err, "Proxy for a custom transport wasn't reported") {
+		assert.True(t, strings.Contains(err.Error(), "*http.Transport"))
+	}
+}
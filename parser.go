@@ -0,0 +1,269 @@
+package dvlirclient
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Number of fields in the responses of the adapter
+const (
+	dataLineFields        = 13
+	momentaryValuesFields = 25
+	generalInfoFields     = 13
+	networkInfoFields     = 7
+	systemInfoFields      = 4
+)
+
+/*
+ParseError is returned if a response of the adapter doesn't have the expected format
+*/
+type ParseError struct {
+	//Endpoint is the endpoint that returned the response, e.g. /data.txt
+	Endpoint string
+	//Line is the line of the response the error occurred in (starting at 1)
+	Line int
+	//Field is the index of the first missing field
+	Field int
+	//Expected is the number of fields that were expected
+	Expected int
+	//Actual is the number of fields that were found
+	Actual int
+	//Input is the raw input that couldn't be parsed
+	Input string
+}
+
+func (p *ParseError) Error() string {
+	msg := "parse error: endpoint: " + p.Endpoint
+	if p.Line > 0 {
+		msg += " // line: " + strconv.Itoa(p.Line)
+	}
+	msg += " // field: " + strconv.Itoa(p.Field) +
+		" // expected " + strconv.Itoa(p.Expected) + " fields, got " + strconv.Itoa(p.Actual)
+	return msg
+}
+
+/*
+splitFields splits a response into its fields and checks that at least the expected number of fields is present.
+Additional fields are ignored.
+*/
+func splitFields(endpoint string, line int, input, separator string, expected int) ([]string, error) {
+	fields := strings.Split(input, separator)
+	if input == "" {
+		fields = nil
+	}
+	if len(fields) < expected {
+		return nil, &ParseError{
+			Endpoint: endpoint,
+			Line:     line,
+			Field:    len(fields),
+			Expected: expected,
+			Actual:   len(fields),
+			Input:    input,
+		}
+	}
+	return fields, nil
+}
+
+/*
+splitInto splits a response into the given slice of fields without allocating and returns the number of fields found.
+Fields beyond the length of the slice are counted, but not stored.
+*/
+func splitInto(input, separator string, fields []string) int {
+	if input == "" {
+		return 0
+	}
+	n := 0
+	for {
+		i := strings.Index(input, separator)
+		field := input
+		if i >= 0 {
+			field = input[:i]
+		}
+		if n < len(fields) {
+			fields[n] = field
+		}
+		n++
+		if i < 0 {
+			return n
+		}
+		input = input[i+len(separator):]
+	}
+}
+
+/*
+isLoginPage returns true if the adapter returned its login page instead of the requested data
+*/
+func isLoginPage(response string) bool {
+	return strings.HasPrefix(response, "<!DOCTYPE")
+}
+
+/*
+padMeterNumber pads a meter number with leading zeros to 8 digits
+*/
+func padMeterNumber(meterNumber string) string {
+	for ns := 8 - len(meterNumber); ns > 0; ns-- {
+		meterNumber = "0" + meterNumber
+	}
+	return meterNumber
+}
+
+/*
+NormalizeMAC returns the MAC address in lower case with colons as separator, e.g. to compare the MAC address of
+GeneralInfo with one given by a user
+*/
+func NormalizeMAC(mac string) string {
+	return strings.ToLower(strings.Replace(strings.TrimSpace(mac), "-", ":", -1))
+}
+
+/*
+ParseDataLine parses a single line of the daten.csv file
+*/
+func ParseDataLine(input string) (DataLine, error) {
+	return parseDataLine(input, 0)
+}
+
+func parseDataLine(input string, lineNumber int) (DataLine, error) {
+	var line DataLine
+
+	var inputSlice [dataLineFields]string
+	if n := splitInto(input, ";", inputSlice[:]); n < dataLineFields {
+		return line, &ParseError{
+			Endpoint: "/daten.csv",
+			Line:     lineNumber,
+			Field:    n,
+			Expected: dataLineFields,
+			Actual:   n,
+			Input:    input,
+		}
+	}
+
+	line.Index = inputSlice[0]
+	line.Date = inputSlice[1]
+	line.Time = inputSlice[2]
+	line.DvLIRSn = inputSlice[3]
+	line.MeterNumber = padMeterNumber(inputSlice[4])
+	line.OneEightZero = inputSlice[5]
+	line.OneEightOne = inputSlice[6]
+	line.OneEightTwo = inputSlice[7]
+	line.TwoEightZero = inputSlice[8]
+	line.TwoEightOne = inputSlice[9]
+	line.TwoEightTwo = inputSlice[10]
+	line.Power = inputSlice[11]
+	line.Status = inputSlice[12]
+
+	return line, nil
+}
+
+/*
+ParseDataFile parses the content of the daten.csv file. Lines are separated by \r\n, blank lines (e.g. after the final
+line break) are skipped.
+*/
+func ParseDataFile(input string) (DataLines, error) {
+	var fileLines DataLines
+
+	for i, l := range strings.Split(input, "\r\n") {
+		if strings.TrimSpace(l) == "" {
+			continue
+		}
+		fileLine, err := parseDataLine(l, i+1)
+		if err != nil {
+			return nil, err
+		}
+		fileLines = append(fileLines, fileLine)
+	}
+
+	return fileLines, nil
+}
+
+/*
+ParseMomentaryValues parses the content of the data.txt file
+*/
+func ParseMomentaryValues(input string) (MomentaryValues, error) {
+	var values MomentaryValues
+
+	val, err := splitFields("/data.txt", 0, input, "#", momentaryValuesFields)
+	if err != nil {
+		return values, err
+	}
+
+	values.MeterNumber = padMeterNumber(val[0])
+	values.OBISNum = val[1]
+	values.MomentaryPower = val[2]
+	values.MeterReadingAP = val[3]
+	values.MeterReadingAM = val[4]
+	copy(values.MeterReadingsAP[:], val[5:14])
+	copy(values.MeterReadingsAM[:], val[14:23])
+	values.Status = val[23]
+	values.SavingInterval = parseSavingIntervalField(val[24])
+
+	return values, nil
+}
+
+/*
+ParseGeneralInfo parses the content of the info.txt file
+*/
+func ParseGeneralInfo(input string) (GeneralInfo, error) {
+	var info GeneralInfo
+
+	information, err := splitFields("/info.txt", 0, input, "#", generalInfoFields)
+	if err != nil {
+		return info, err
+	}
+
+	info.ServerIDMeter = information[0]
+	info.MeterNumber = padMeterNumber(information[1])
+	info.ManufacturerCode = information[2]
+	info.IPAddress = information[3]
+	info.Gateway = information[4]
+	info.DNSServer = information[5]
+	info.NetworkName = information[6]
+	info.MACAddress = information[7]
+	info.SavingInterval = parseSavingIntervalField(information[8])
+	info.Date = information[9]
+	info.Time = information[10]
+	info.DeviceSn = information[11]
+	info.FirmwareVersion = information[12]
+
+	return info, nil
+}
+
+/*
+ParseNetworkInfo parses the content of the network.txt file
+*/
+func ParseNetworkInfo(input string) (NetworkInfo, error) {
+	var net NetworkInfo
+
+	info, err := splitFields("/network.txt", 0, input, "#", networkInfoFields)
+	if err != nil {
+		return net, err
+	}
+
+	net.DHCPServer = info[0]
+	net.IPAddress = info[1]
+	net.SubnetMask = info[2]
+	net.Gateway = info[3]
+	net.DNSServer = info[4]
+	net.NTPServer = info[5]
+	net.NTPName = info[6]
+
+	return net, nil
+}
+
+/*
+ParseSystemInfo parses the content of the system.txt file
+*/
+func ParseSystemInfo(input string) (SystemInfo, error) {
+	var system SystemInfo
+
+	sys, err := splitFields("/system.txt", 0, input, "#", systemInfoFields)
+	if err != nil {
+		return system, err
+	}
+
+	system.SavingInterval = parseSavingIntervalField(sys[0])
+	system.ResetCode = sys[1]
+	system.DeleteCode = sys[2]
+	system.ResetWithDefaultPwd = sys[3]
+
+	return system, nil
+}
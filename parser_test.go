@@ -0,0 +1,79 @@
+package dvlirclient
+
+import (
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+/*
+TestParseDataFile covers:
+	- ParseDataFile with a trailing line break
+	- ParseDataFile with a short line
+	- ParseDataLine with an empty line
+*/
+func TestParseDataFile(t *testing.T) {
+	input := "1;01.01.2020;00:15:00;20190001;1234567;15873.4521;12100.2874;3773.1647;421.3370;421.3370;0.0000;150;0000\r\n" +
+		"2;01.01.2020;00:30:00;20190001;1234567;15873.4896;12100.3249;3773.1647;421.3370;421.3370;0.0000;150;0000\r\n"
+
+	lines, err := ParseDataFile(input)
+	if !assert.NoError(t, err, "Error while parsing data file") {
+		return
+	}
+	if !assert.Len(t, lines, 2, "Wrong number of lines") {
+		return
+	}
+	assert.Equal(t, "01234567", lines[1].MeterNumber, "Meter number wasn't padded")
+	assert.Equal(t, "15873.4896", lines[1].OneEightZero)
+
+	_, err = ParseDataFile(input + "3;01.01.2020;00:45:00\r\n")
+	var parseError *ParseError
+	if !assert.True(t, errors.As(err, &parseError), "ParseError wasn't returned") {
+		return
+	}
+	assert.Equal(t, "/daten.csv", parseError.Endpoint)
+	assert.Equal(t, 3, parseError.Line)
+	assert.Equal(t, 3, parseError.Field)
+	assert.Equal(t, 13, parseError.Expected)
+	assert.Equal(t, 3, parseError.Actual)
+	assert.Equal(t, "3;01.01.2020;00:45:00", parseError.Input)
+
+	_, err = ParseDataLine("")
+	if assert.True(t, errors.As(err, &parseError), "ParseError wasn't returned") {
+		assert.Equal(t, 0, parseError.Actual)
+	}
+}
+
+/*
+TestParseInformation covers:
+	- ParseMomentaryValues
+	- ParseGeneralInfo
+	- ParseNetworkInfo
+	- ParseSystemInfo
+*/
+func TestParseInformation(t *testing.T) {
+	_, err := ParseMomentaryValues("1234567#1-0:1.8.0#150")
+	assert.EqualError(t, err, "parse error: endpoint: /data.txt // field: 3 // expected 25 fields, got 3")
+
+	_, err = ParseGeneralInfo("")
+	assert.EqualError(t, err, "parse error: endpoint: /info.txt // field: 0 // expected 13 fields, got 0")
+
+	network, err := ParseNetworkInfo("no#192.168.0.100#255.255.255.0#192.168.0.1#192.168.0.1#yes#de.pool.ntp.org")
+	if assert.NoError(t, err, "Error while parsing network information") {
+		assert.Equal(t, "de.pool.ntp.org", network.NTPName)
+	}
+
+	system, err := ParseSystemInfo("15min#4711#0815#no#additional")
+	if assert.NoError(t, err, "Additional fields weren't ignored") {
+		assert.Equal(t, "no", system.ResetWithDefaultPwd)
+	}
+}
+
+/*
+TestNormalizeMAC covers:
+	- NormalizeMAC with dashes, upper case and surrounding spaces
+*/
+func TestNormalizeMAC(t *testing.T) {
+	assert.Equal(t, "00:50:c2:9f:10:01", NormalizeMAC(" 00-50-C2-9F-10-01\n"))
+	assert.Equal(t, NormalizeMAC("00:50:c2:9f:10:01"), NormalizeMAC("00-50-C2-9F-10-01"))
+}
@@ -0,0 +1,366 @@
+package dvlirclient
+
+import (
+	"github.com/pkg/errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Layouts of the date and time fields of the adapter
+const (
+	dateLayout = "02.01.2006"
+	timeLayout = "15:04:05"
+)
+
+/*
+Energy is a fixed-point amount of energy in Wh with three decimals, i.e. it counts milliwatt hours.
+
+The adapter reports registers in kWh with up to four decimals, so every register value is represented exactly.
+*/
+type Energy int64
+
+/*
+ParseEnergy parses a register value in kWh as reported by the adapter, e.g. "15873.4521"
+*/
+func ParseEnergy(kWh string) (Energy, error) {
+	mWh, err := parseFixedPoint(kWh, 6)
+	if err != nil {
+		return 0, errors.Wrap(err, "invalid energy value")
+	}
+	return Energy(mWh), nil
+}
+
+/*
+MilliwattHours returns the energy in mWh
+*/
+func (e Energy) MilliwattHours() int64 {
+	return int64(e)
+}
+
+/*
+WattHours returns the energy in whole Wh, the fractional part is truncated
+*/
+func (e Energy) WattHours() int64 {
+	return int64(e) / 1000
+}
+
+/*
+String returns the energy in Wh with three decimals
+*/
+func (e Energy) String() string {
+	return formatFixedPoint(int64(e), 3)
+}
+
+/*
+Power is a fixed-point power in W with three decimals, i.e. it counts milliwatts. Negative values mean that energy is
+fed into the grid.
+*/
+type Power int64
+
+/*
+ParsePower parses a power value in W as reported by the adapter, e.g. "150" or "-12.5"
+*/
+func ParsePower(w string) (Power, error) {
+	mW, err := parseFixedPoint(w, 3)
+	if err != nil {
+		return 0, errors.Wrap(err, "invalid power value")
+	}
+	return Power(mW), nil
+}
+
+/*
+Milliwatts returns the power in mW
+*/
+func (p Power) Milliwatts() int64 {
+	return int64(p)
+}
+
+/*
+Watts returns the power in whole W, the fractional part is truncated
+*/
+func (p Power) Watts() int64 {
+	return int64(p) / 1000
+}
+
+/*
+String returns the power in W with three decimals
+*/
+func (p Power) String() string {
+	return formatFixedPoint(int64(p), 3)
+}
+
+/*
+Status is the decoded status word of the adapter. Each bit represents a status flag, a status of 0 means that no flag
+is set.
+*/
+type Status uint16
+
+/*
+ParseStatus parses the hexadecimal status word reported by the adapter, e.g. "0000"
+*/
+func ParseStatus(status string) (Status, error) {
+	s, err := strconv.ParseUint(status, 16, 16)
+	if err != nil {
+		return 0, errors.Wrap(err, "invalid status")
+	}
+	return Status(s), nil
+}
+
+/*
+OK returns true if no status flag is set
+*/
+func (s Status) OK() bool {
+	return s == 0
+}
+
+/*
+Flag returns true if the status flag with the given bit number (0-15) is set
+*/
+func (s Status) Flag(bit uint) bool {
+	return bit < 16 && s&(1<<bit) != 0
+}
+
+/*
+String returns the status word in the hexadecimal representation of the adapter
+*/
+func (s Status) String() string {
+	str := strconv.FormatUint(uint64(s), 16)
+	return strings.Repeat("0", 4-len(str)) + strings.ToUpper(str)
+}
+
+/*
+Reading is the typed representation of a DataLine
+*/
+type Reading struct {
+	Index        int       `json:"index"`
+	Timestamp    time.Time `json:"timestamp"`
+	DvLIRSn      string    `json:"dvlir_sn"`
+	MeterNumber  string    `json:"meter_number"`
+	OneEightZero Energy    `json:"one_eight_zero"`
+	OneEightOne  Energy    `json:"one_eight_one"`
+	OneEightTwo  Energy    `json:"one_eight_two"`
+	TwoEightZero Energy    `json:"two_eight_zero"`
+	TwoEightOne  Energy    `json:"two_eight_one"`
+	TwoEightTwo  Energy    `json:"two_eight_two"`
+	Power        Power     `json:"power"`
+	Status       Status    `json:"status"`
+}
+
+/*
+Momentary is the typed representation of MomentaryValues
+*/
+type Momentary struct {
+	MeterNumber     string         `json:"meter_number"`
+	OBISNum         string         `json:"obis_num"`
+	MomentaryPower  Power          `json:"momentary_power"`
+	MeterReadingAP  Energy         `json:"meter_reading_ap"`
+	MeterReadingAM  Energy         `json:"meter_reading_am"`
+	MeterReadingsAP [9]Energy      `json:"meter_readings_ap"`
+	MeterReadingsAM [9]Energy      `json:"meter_readings_am"`
+	Status          Status         `json:"status"`
+	SavingInterval  SavingInterval `json:"saving_interval"`
+}
+
+/*
+FieldError describes a single field that couldn't be converted
+*/
+type FieldError struct {
+	Field string
+	Value string
+	Err   error
+}
+
+func (f *FieldError) Error() string {
+	return f.Field + " (" + strconv.Quote(f.Value) + "): " + f.Err.Error()
+}
+
+func (f *FieldError) Unwrap() error {
+	return f.Err
+}
+
+/*
+ConversionError is returned if one or more fields couldn't be converted into their typed representation
+*/
+type ConversionError struct {
+	Errors []*FieldError
+}
+
+func (c *ConversionError) Error() string {
+	msgs := make([]string, 0, len(c.Errors))
+	for _, err := range c.Errors {
+		msgs = append(msgs, err.Error())
+	}
+	return "conversion error: " + strings.Join(msgs, " // ")
+}
+
+/*
+converter collects the errors of all fields of a conversion
+*/
+type converter struct {
+	errors []*FieldError
+}
+
+func (c *converter) check(field, value string, err error) {
+	if err != nil {
+		c.errors = append(c.errors, &FieldError{Field: field, Value: value, Err: err})
+	}
+}
+
+func (c *converter) energy(field, value string) Energy {
+	e, err := ParseEnergy(value)
+	c.check(field, value, err)
+	return e
+}
+
+func (c *converter) power(field, value string) Power {
+	p, err := ParsePower(value)
+	c.check(field, value, err)
+	return p
+}
+
+func (c *converter) status(field, value string) Status {
+	s, err := ParseStatus(value)
+	c.check(field, value, err)
+	return s
+}
+
+func (c *converter) err() error {
+	if len(c.errors) == 0 {
+		return nil
+	}
+	return &ConversionError{Errors: c.errors}
+}
+
+/*
+Reading converts the DataLine into its typed representation. Date and Time are interpreted in the given location,
+which should be the time zone the adapter's clock is set to. If loc is nil, the local time zone is used.
+
+All fields are converted, the returned ConversionError contains an error for each invalid field.
+*/
+func (l DataLine) Reading(loc *time.Location) (Reading, error) {
+	var c converter
+	if loc == nil {
+		loc = time.Local
+	}
+
+	index, err := strconv.Atoi(l.Index)
+	c.check("Index", l.Index, err)
+	timestamp, err := time.ParseInLocation(dateLayout+" "+timeLayout, l.Date+" "+l.Time, loc)
+	c.check("Date/Time", l.Date+" "+l.Time, err)
+
+	reading := Reading{
+		Index:        index,
+		Timestamp:    timestamp,
+		DvLIRSn:      l.DvLIRSn,
+		MeterNumber:  l.MeterNumber,
+		OneEightZero: c.energy("OneEightZero", l.OneEightZero),
+		OneEightOne:  c.energy("OneEightOne", l.OneEightOne),
+		OneEightTwo:  c.energy("OneEightTwo", l.OneEightTwo),
+		TwoEightZero: c.energy("TwoEightZero", l.TwoEightZero),
+		TwoEightOne:  c.energy("TwoEightOne", l.TwoEightOne),
+		TwoEightTwo:  c.energy("TwoEightTwo", l.TwoEightTwo),
+		Power:        c.power("Power", l.Power),
+		Status:       c.status("Status", l.Status),
+	}
+
+	return reading, c.err()
+}
+
+/*
+Readings converts all DataLines into their typed representation. The conversion stops at the first invalid DataLine.
+*/
+func (l DataLines) Readings(loc *time.Location) ([]Reading, error) {
+	readings := make([]Reading, 0, len(l))
+	for i, line := range l {
+		reading, err := line.Reading(loc)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid data line "+strconv.Itoa(i+1))
+		}
+		readings = append(readings, reading)
+	}
+	return readings, nil
+}
+
+/*
+Momentary converts the MomentaryValues into their typed representation.
+
+All fields are converted, the returned ConversionError contains an error for each invalid field.
+*/
+func (m MomentaryValues) Momentary() (Momentary, error) {
+	var c converter
+
+	momentary := Momentary{
+		MeterNumber:    m.MeterNumber,
+		OBISNum:        m.OBISNum,
+		MomentaryPower: c.power("MomentaryPower", m.MomentaryPower),
+		MeterReadingAP: c.energy("MeterReadingAP", m.MeterReadingAP),
+		MeterReadingAM: c.energy("MeterReadingAM", m.MeterReadingAM),
+		Status:         c.status("Status", m.Status),
+		SavingInterval: m.SavingInterval,
+	}
+	for i := range m.MeterReadingsAP {
+		momentary.MeterReadingsAP[i] = c.energy("MeterReadingsAP["+strconv.Itoa(i)+"]", m.MeterReadingsAP[i])
+	}
+	for i := range m.MeterReadingsAM {
+		momentary.MeterReadingsAM[i] = c.energy("MeterReadingsAM["+strconv.Itoa(i)+"]", m.MeterReadingsAM[i])
+	}
+
+	return momentary, c.err()
+}
+
+/*
+parseFixedPoint parses a decimal number into an integer scaled by 10^decimals. Numbers with more decimals can't be
+represented exactly and are rejected.
+*/
+func parseFixedPoint(value string, decimals int) (int64, error) {
+	number := strings.TrimSpace(value)
+	sign := ""
+	if strings.HasPrefix(number, "-") || strings.HasPrefix(number, "+") {
+		sign, number = number[:1], number[1:]
+	}
+
+	whole, fraction := number, ""
+	if i := strings.IndexByte(number, '.'); i >= 0 {
+		whole, fraction = number[:i], number[i+1:]
+	}
+	if whole == "" || !isDigits(whole) || !isDigits(fraction) {
+		return 0, errors.New("not a decimal number: " + strconv.Quote(value))
+	}
+	if len(fraction) > decimals {
+		if strings.TrimRight(fraction[decimals:], "0") != "" {
+			return 0, errors.New("more than " + strconv.Itoa(decimals) + " decimals: " + strconv.Quote(value))
+		}
+		fraction = fraction[:decimals]
+	}
+	fraction += strings.Repeat("0", decimals-len(fraction))
+
+	n, err := strconv.ParseInt(sign+whole+fraction, 10, 64)
+	if err != nil {
+		return 0, errors.Wrap(err, "value out of range")
+	}
+	return n, nil
+}
+
+func formatFixedPoint(n int64, decimals int) string {
+	sign := ""
+	u := uint64(n)
+	if n < 0 {
+		sign = "-"
+		u = uint64(-n)
+	}
+	str := strconv.FormatUint(u, 10)
+	if len(str) <= decimals {
+		str = strings.Repeat("0", decimals-len(str)+1) + str
+	}
+	return sign + str[:len(str)-decimals] + "." + str[len(str)-decimals:]
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
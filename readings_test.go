@@ -0,0 +1,84 @@
+package dvlirclient
+
+import (
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+/*
+TestDataLine_Reading covers:
+	- ParseDataLine
+	- Reading
+*/
+func TestDataLine_Reading(t *testing.T) {
+	line, err := ParseDataLine("87;31.12.2019;21:45:00;20190001;1234567;15881.1546;12106.5274;3774.6272;421.5686;421.5686;0.0000;-510.5;0104")
+	if !assert.NoError(t, err, "Error while parsing data line") {
+		return
+	}
+
+	reading, err := line.Reading(time.UTC)
+	if !assert.NoError(t, err, "Error while converting data line") {
+		return
+	}
+	assert.Equal(t, 87, reading.Index)
+	assert.Equal(t, time.Date(2019, time.December, 31, 21, 45, 0, 0, time.UTC), reading.Timestamp)
+	assert.Equal(t, Energy(15881154600), reading.OneEightZero)
+	assert.Equal(t, int64(15881154), reading.OneEightZero.WattHours())
+	assert.Equal(t, "15881154.600", reading.OneEightZero.String())
+	assert.Equal(t, Power(-510500), reading.Power)
+	assert.Equal(t, "-510.500", reading.Power.String())
+	assert.False(t, reading.Status.OK())
+	assert.True(t, reading.Status.Flag(2))
+	assert.True(t, reading.Status.Flag(8))
+	assert.Equal(t, "0104", reading.Status.String())
+
+	line.Index = "x"
+	line.OneEightOne = "1.2.3"
+	line.Power = "12.3456"
+	_, err = line.Reading(time.UTC)
+	var conversionError *ConversionError
+	if !assert.True(t, errors.As(err, &conversionError), "ConversionError wasn't returned") {
+		return
+	}
+	if assert.Len(t, conversionError.Errors, 3, "Not every invalid field was reported") {
+		assert.Equal(t, "Index", conversionError.Errors[0].Field)
+		assert.Equal(t, "OneEightOne", conversionError.Errors[1].Field)
+		assert.Equal(t, "Power", conversionError.Errors[2].Field)
+	}
+}
+
+/*
+TestMomentaryValues_Momentary covers:
+	- Login
+	- GetMomentaryValues
+	- Momentary
+*/
+func TestMomentaryValues_Momentary(t *testing.T) {
+	dvlirClient, server := newSimulatedClient(t)
+	defer server.Close()
+
+	values, err := dvlirClient.GetMomentaryValues()
+	if !assert.NoError(t, err, "Error during GetMomentaryValues request") {
+		return
+	}
+
+	momentary, err := values.Momentary()
+	if !assert.NoError(t, err, "Error while converting momentary values") {
+		return
+	}
+	assert.Equal(t, Energy(15882332100), momentary.MeterReadingAP)
+	assert.Equal(t, momentary.MeterReadingAP, momentary.MeterReadingsAP[0]+momentary.MeterReadingsAP[1])
+	assert.Equal(t, Power(150000), momentary.MomentaryPower)
+
+	file, err := dvlirClient.GetDataFile(100)
+	if !assert.NoError(t, err, "Error during GetDataFile request") {
+		return
+	}
+	readings, err := file.Readings(time.UTC)
+	if !assert.NoError(t, err, "Error while converting data file") {
+		return
+	}
+	assert.Equal(t, momentary.MeterReadingAP, readings[len(readings)-1].OneEightZero)
+}
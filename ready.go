@@ -0,0 +1,146 @@
+package dvlirclient
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"strconv"
+	"time"
+)
+
+/*
+ReadyOptions configures WaitReady
+*/
+type ReadyOptions struct {
+	//Timeout limits how long to wait for the adapter, the default is 2 minutes
+	Timeout time.Duration
+	//DownTimeout limits how long to wait for the adapter to go down, the default is 10 seconds. An adapter which isn't
+	//seen going down in time is assumed to have restarted faster than it was polled.
+	DownTimeout time.Duration
+	//InitialInterval is the time between the first two attempts to reach the adapter, it doubles after every failed
+	//attempt up to MaxInterval. The defaults are 250 milliseconds and 5 seconds.
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+	//AttemptTimeout limits a single attempt to reach the adapter, the default is 2 seconds
+	AttemptTimeout time.Duration
+}
+
+/*
+ReadyReport describes how the adapter went down and came back
+*/
+type ReadyReport struct {
+	//WentDown is true if the adapter was seen going down, i.e. it didn't answer or it ended the session
+	WentDown bool
+	//Downtime is the time from the adapter going down until it accepted a login again. If the adapter wasn't seen
+	//going down, the time since the start of the wait is reported.
+	Downtime time.Duration
+	//Elapsed is the time from the start of the wait until the adapter was ready or the timeout expired
+	Elapsed time.Duration
+	//Attempts is the number of login attempts
+	Attempts int
+	//LastError is the error of the last failed login attempt
+	LastError error
+}
+
+/*
+RestartAndWait restarts the adapter and waits until it is ready again as described for WaitReady
+*/
+func (d *DvLIRClient) RestartAndWait(ctx context.Context, opts ReadyOptions) (*ReadyReport, error) {
+	if _, err := d.RestartContext(ctx); err != nil {
+		return nil, err
+	}
+	return d.WaitReady(ctx, opts)
+}
+
+/*
+ResetAllAndWait resets the adapter to its factory settings and waits until it is ready again as described for
+WaitReady. The login after the reset uses the password of the client, so it fails if the factory password differs.
+*/
+func (d *DvLIRClient) ResetAllAndWait(ctx context.Context, rCode string, opts ReadyOptions) (*ReadyReport, error) {
+	if _, err := d.ResetAllContext(ctx, rCode); err != nil {
+		return nil, err
+	}
+	return d.WaitReady(ctx, opts)
+}
+
+/*
+WaitReady waits until the adapter restarted, e.g. after Restart, ResetAll or a firmware upload.
+
+The adapter is polled until it stops answering or ends the current session, which shows that it went down. Afterwards
+a login is attempted with exponential backoff until it succeeds, so the client is logged in again when WaitReady
+returns. If the adapter doesn't accept a login in time, an error wrapping ErrNotReady is returned together with the
+report.
+*/
+func (d *DvLIRClient) WaitReady(ctx context.Context, opts ReadyOptions) (*ReadyReport, error) {
+	if !d.isValid() {
+		return nil, &NotValidError{}
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 2 * time.Minute
+	}
+	if opts.DownTimeout <= 0 {
+		opts.DownTimeout = 10 * time.Second
+	}
+	if opts.InitialInterval <= 0 {
+		opts.InitialInterval = 250 * time.Millisecond
+	}
+	if opts.MaxInterval <= 0 {
+		opts.MaxInterval = 5 * time.Second
+	}
+	if opts.MaxInterval < opts.InitialInterval {
+		opts.MaxInterval = opts.InitialInterval
+	}
+	if opts.AttemptTimeout <= 0 {
+		opts.AttemptTimeout = 2 * time.Second
+	}
+
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	report := &ReadyReport{}
+	downAt := start
+	if at, ok := d.waitDown(ctx, opts); ok {
+		report.WentDown = true
+		downAt = at
+	}
+
+	report.Attempts, report.LastError = waitForLogin(ctx, d, opts.InitialInterval, opts.MaxInterval, opts.AttemptTimeout)
+	report.Elapsed = time.Since(start)
+	if report.LastError != nil {
+		return report, errors.Wrap(ErrNotReady, "Adapter didn't accept a login within "+opts.Timeout.String()+
+			" after "+strconv.Itoa(report.Attempts)+" attempts, last error: "+report.LastError.Error())
+	}
+	report.Downtime = time.Since(downAt)
+	return report, nil
+}
+
+/*
+waitDown polls the adapter until it doesn't answer or rejects the current session. It returns the time the adapter
+was seen going down and false if it wasn't seen going down within DownTimeout.
+*/
+func (d *DvLIRClient) waitDown(ctx context.Context, opts ReadyOptions) (time.Time, bool) {
+	ctx, cancel := context.WithTimeout(ctx, opts.DownTimeout)
+	defer cancel()
+
+	sessionID := d.session.current()
+	for {
+		attemptCtx, cancelAttempt := context.WithTimeout(ctx, opts.AttemptTimeout)
+		res, err := d.get(attemptCtx, "/info.txt?sid="+sessionID, "", "")
+		cancelAttempt()
+		if ctx.Err() != nil {
+			return time.Time{}, false
+		}
+		//Without a session the login page is always returned, so only a missing answer shows the restart
+		if err != nil || sessionID != "" && isLoginPage(res.String()) {
+			return time.Now(), true
+		}
+
+		timer := time.NewTimer(opts.InitialInterval)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return time.Time{}, false
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package dvlirclient
+
+import (
+	"context"
+	"github.com/inexio/dvlir-restapi-go-client/dvlirtest"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+/*
+TestDvLIRClient_RestartAndWait covers:
+	- RestartAndWait with an adapter that is down for a while
+	- GetGeneralInformation with the new session
+	- ResetAllAndWait with an instant restart
+*/
+func TestDvLIRClient_RestartAndWait(t *testing.T) {
+	dvlirClient, server := newSimulatedClient(t, dvlirtest.WithRebootDuration(200*time.Millisecond))
+	defer server.Close()
+	ctx := context.Background()
+
+	opts := ReadyOptions{Timeout: 5 * time.Second, InitialInterval: 10 * time.Millisecond, MaxInterval: 50 * time.Millisecond}
+	report, err := dvlirClient.RestartAndWait(ctx, opts)
+	if !assert.NoError(t, err, "Error during RestartAndWait") {
+		return
+	}
+	assert.Equal(t, 1, server.Reboots(), "Adapter wasn't restarted")
+	assert.True(t, report.WentDown, "Adapter wasn't seen going down")
+	assert.True(t, report.Attempts > 1, "Login wasn't retried while the adapter was down")
+	assert.True(t, report.Downtime >= 100*time.Millisecond && report.Downtime <= report.Elapsed, "Downtime %v wasn't measured", report.Downtime)
+
+	_, err = dvlirClient.GetGeneralInformation()
+	assert.NoError(t, err, "Session wasn't re-established")
+
+	instant, instantServer := newSimulatedClient(t)
+	defer instantServer.Close()
+	report, err = instant.ResetAllAndWait(ctx, instantServer.State().ResetCode, opts)
+	if !assert.NoError(t, err, "Error during ResetAllAndWait") {
+		return
+	}
+	assert.True(t, report.WentDown, "Ended session wasn't detected")
+	assert.Equal(t, 1, report.Attempts)
+	_, err = instant.GetGeneralInformation()
+	assert.NoError(t, err, "Session wasn't re-established")
+}
+
+/*
+TestDvLIRClient_WaitReady covers:
+	- RestartAndWait with an adapter that doesn't come back in time
+	- WaitReady with an adapter that isn't restarted
+*/
+func TestDvLIRClient_WaitReady(t *testing.T) {
+	dvlirClient, server := newSimulatedClient(t, dvlirtest.WithRebootDuration(time.Hour))
+	defer server.Close()
+	ctx := context.Background()
+
+	opts := ReadyOptions{Timeout: 300 * time.Millisecond, InitialInterval: 10 * time.Millisecond, AttemptTimeout: 50 * time.Millisecond}
+	report, err := dvlirClient.RestartAndWait(ctx, opts)
+	assert.True(t, errors.Is(err, ErrNotReady), "ErrNotReady wasn't returned: %v", err)
+	if assert.NotNil(t, report) {
+		assert.True(t, report.WentDown)
+		assert.True(t, report.Attempts > 1)
+		assert.Error(t, report.LastError)
+	}
+
+	running, runningServer := newSimulatedClient(t)
+	defer runningServer.Close()
+	opts.DownTimeout = 50 * time.Millisecond
+	report, err = running.WaitReady(ctx, opts)
+	if !assert.NoError(t, err, "Error during WaitReady") {
+		return
+	}
+	assert.False(t, report.WentDown, "Running adapter was reported down")
+	assert.True(t, report.Downtime >= 50*time.Millisecond)
+}
@@ -0,0 +1,193 @@
+package dvlirclient
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"net"
+	"strings"
+)
+
+/*
+DesiredState describes the settings an adapter should have. Fields that are nil or empty aren't managed and keep the
+value of the adapter.
+*/
+type DesiredState struct {
+	//DHCP enables or disables the DHCP client of the adapter
+	DHCP *bool `json:"dhcp,omitempty" yaml:"dhcp,omitempty"`
+	//IPAddress, SubnetMask, Gateway and DNSServer are IPv4 addresses in dotted notation
+	IPAddress  string `json:"ip_address,omitempty" yaml:"ip_address,omitempty"`
+	SubnetMask string `json:"subnet_mask,omitempty" yaml:"subnet_mask,omitempty"`
+	Gateway    string `json:"gateway,omitempty" yaml:"gateway,omitempty"`
+	DNSServer  string `json:"dns_server,omitempty" yaml:"dns_server,omitempty"`
+	//NTPServer enables or disables the time synchronization via NTP with the server NTPName
+	NTPServer *bool  `json:"ntp_server,omitempty" yaml:"ntp_server,omitempty"`
+	NTPName   string `json:"ntp_name,omitempty" yaml:"ntp_name,omitempty"`
+	//SavingInterval is the interval in which the adapter saves a line to the data file
+	SavingInterval SavingInterval `json:"saving_interval,omitempty" yaml:"saving_interval,omitempty"`
+	//ResetWithDefaultPwd allows or forbids a reset with the default password
+	ResetWithDefaultPwd *bool `json:"reset_with_default_pwd,omitempty" yaml:"reset_with_default_pwd,omitempty"`
+}
+
+/*
+Validate checks the desired state like NetworkSettings.Validate. The returned error is a *FieldError for the first
+invalid field.
+*/
+func (s DesiredState) Validate() error {
+	if s.SavingInterval != "" {
+		if _, err := ParseSavingInterval(string(s.SavingInterval)); err != nil {
+			return &FieldError{Field: "SavingInterval", Value: string(s.SavingInterval), Err: err}
+		}
+	}
+	settings := NetworkSettings{DHCP: s.DHCP, NTPName: s.NTPName, NTPServer: s.NTPServer}
+	var err error
+	if s.IPAddress != "" {
+		if settings.IPAddress, err = parseIPv4("IPAddress", s.IPAddress); err != nil {
+			return err
+		}
+	}
+	if s.SubnetMask != "" {
+		mask, err := parseIPv4("SubnetMask", s.SubnetMask)
+		if err != nil {
+			return err
+		}
+		settings.SubnetMask = net.IPMask(mask.To4())
+	}
+	if s.Gateway != "" {
+		if settings.Gateway, err = parseIPv4("Gateway", s.Gateway); err != nil {
+			return err
+		}
+	}
+	if s.DNSServer != "" {
+		if settings.DNSServer, err = parseIPv4("DNSServer", s.DNSServer); err != nil {
+			return err
+		}
+	}
+	return settings.Validate()
+}
+
+/*
+Plan returns the changes needed to bring an adapter with the given network and system settings into the desired
+state. The changes are sorted in the order they are applied: the system settings first, then the network settings
+which keep the address of the adapter and the address last.
+*/
+func (s DesiredState) Plan(network NetworkInfo, system SystemInfo) []SettingChange {
+	var changes []SettingChange
+	add := func(field, current, desired string) {
+		if desired != "" && !sameSetting(current, desired) {
+			changes = append(changes, SettingChange{Field: field, Current: current, Desired: desired})
+		}
+	}
+
+	interval := string(s.SavingInterval)
+	if parsed, err := ParseSavingInterval(interval); err == nil {
+		interval = string(parsed)
+	}
+	add(SettingSavingInterval, string(system.SavingInterval), interval)
+	add(SettingResetWithDefaultPwd, system.ResetWithDefaultPwd, optionalYesNo(s.ResetWithDefaultPwd))
+	add(SettingDNSServer, network.DNSServer, s.DNSServer)
+	add(SettingNTPServer, network.NTPServer, optionalYesNo(s.NTPServer))
+	add(SettingNTPName, network.NTPName, s.NTPName)
+	add(SettingDHCP, network.DHCPServer, optionalYesNo(s.DHCP))
+	add(SettingIPAddress, network.IPAddress, s.IPAddress)
+	add(SettingSubnetMask, network.SubnetMask, s.SubnetMask)
+	add(SettingGateway, network.Gateway, s.Gateway)
+	return changes
+}
+
+func optionalYesNo(b *bool) string {
+	if b == nil {
+		return ""
+	}
+	return yesNo(*b)
+}
+
+/*
+ReconcileOptions configures Reconcile
+*/
+type ReconcileOptions struct {
+	//DryRun only plans the changes without applying them
+	DryRun bool
+	//Migrate configures how the adapter is followed to its new address if the desired state changes its address.
+	//Locate has to be set if the desired state enables DHCP.
+	Migrate MigrateOptions
+}
+
+/*
+ReconcileReport describes the outcome of Reconcile
+*/
+type ReconcileReport struct {
+	//Plan are the changes needed to reach the desired state in the order they are applied
+	Plan []SettingChange
+	//Applied is true if the changes were applied
+	Applied bool
+	//Verified is true if the adapter reports the desired state, either already or after the changes were applied
+	Verified bool
+	//Remaining are the changes the adapter still needs after the changes were applied
+	Remaining []SettingChange
+	//Migration is the report of MigrateAddress if the address of the adapter was changed
+	Migration *MigrationReport
+}
+
+/*
+Reconcile brings the adapter into the desired state.
+
+The network and system settings are read and a plan of the minimal changes is made. Unless DryRun is set, the plan is
+applied in a safe order with a change of the address last, which follows the adapter with MigrateAddress. Afterwards
+the settings are read again to verify the result. If the adapter doesn't report the desired state, an error wrapping
+ErrNotConverged is returned together with the remaining changes.
+*/
+func (d *DvLIRClient) Reconcile(ctx context.Context, desired DesiredState, opts ReconcileOptions) (*ReconcileReport, error) {
+	if !d.isValid() {
+		return nil, &NotValidError{}
+	}
+	if err := desired.Validate(); err != nil {
+		return nil, errors.Wrap(err, "Invalid desired state")
+	}
+
+	report := &ReconcileReport{}
+	var err error
+	if report.Plan, err = d.planSettings(ctx, desired); err != nil {
+		return nil, err
+	}
+	if len(report.Plan) == 0 {
+		report.Verified = true
+		return report, nil
+	}
+	if opts.DryRun {
+		return report, nil
+	}
+
+	report.Migration, err = d.applySettingChanges(ctx, report.Plan, opts.Migrate)
+	if err != nil {
+		return report, err
+	}
+	report.Applied = true
+
+	if report.Remaining, err = d.planSettings(ctx, desired); err != nil {
+		return report, errors.Wrap(err, "Error while verifying the settings")
+	}
+	if len(report.Remaining) > 0 {
+		fields := make([]string, 0, len(report.Remaining))
+		for _, change := range report.Remaining {
+			fields = append(fields, change.Field+" is "+change.Current+" instead of "+change.Desired)
+		}
+		return report, errors.Wrap(ErrNotConverged, strings.Join(fields, ", "))
+	}
+	report.Verified = true
+	return report, nil
+}
+
+/*
+planSettings reads the network and system settings and plans the changes to reach the desired state
+*/
+func (d *DvLIRClient) planSettings(ctx context.Context, desired DesiredState) ([]SettingChange, error) {
+	network, err := d.GetNetworkInformationContext(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error while reading network information")
+	}
+	system, err := d.GetSystemInformationContext(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error while reading system information")
+	}
+	return desired.Plan(network, system), nil
+}
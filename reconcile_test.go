@@ -0,0 +1,113 @@
+package dvlirclient
+
+import (
+	"context"
+	"github.com/inexio/dvlir-restapi-go-client/dvlirtest"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+/*
+TestDesiredState_Plan covers:
+	- Plan with unmanaged, identical and changed fields
+	- Plan order with the address last
+	- Validate with invalid fields
+*/
+func TestDesiredState_Plan(t *testing.T) {
+	yes, no := true, false
+	network := NetworkInfo{DHCPServer: "yes", IPAddress: "192.168.0.100", SubnetMask: "255.255.255.0",
+		Gateway: "192.168.0.1", DNSServer: "192.168.0.1", NTPServer: "Yes", NTPName: "de.pool.ntp.org"}
+	system := SystemInfo{SavingInterval: SavingInterval15Min, ResetWithDefaultPwd: "no"}
+
+	assert.Empty(t, DesiredState{}.Plan(network, system))
+	assert.Empty(t, DesiredState{NTPServer: &yes, NTPName: "DE.pool.ntp.org", SavingInterval: "15 min"}.Plan(network, system))
+
+	desired := DesiredState{
+		DHCP:                &no,
+		IPAddress:           "192.168.0.101",
+		SubnetMask:          "255.255.255.0",
+		NTPName:             "pool.ntp.org",
+		SavingInterval:      "1min",
+		ResetWithDefaultPwd: &yes,
+	}
+	assert.NoError(t, desired.Validate())
+	assert.Equal(t, []SettingChange{
+		{Field: SettingSavingInterval, Current: "15min", Desired: "min"},
+		{Field: SettingResetWithDefaultPwd, Current: "no", Desired: "yes"},
+		{Field: SettingNTPName, Current: "de.pool.ntp.org", Desired: "pool.ntp.org"},
+		{Field: SettingDHCP, Current: "yes", Desired: "no"},
+		{Field: SettingIPAddress, Current: "192.168.0.100", Desired: "192.168.0.101"},
+	}, desired.Plan(network, system))
+
+	var fieldError *FieldError
+	for _, invalid := range []DesiredState{
+		{SavingInterval: "hourly"},
+		{Gateway: "192.168.0"},
+		{DHCP: &yes, IPAddress: "192.168.0.101"},
+		{IPAddress: "192.168.0.101", SubnetMask: "255.255.255.0", Gateway: "10.0.0.1"},
+	} {
+		err := invalid.Validate()
+		assert.True(t, errors.As(err, &fieldError), "%+v wasn't rejected: %v", invalid, err)
+	}
+}
+
+/*
+TestDvLIRClient_Reconcile covers:
+	- Reconcile with a dry run
+	- Reconcile with verification
+	- Reconcile of an adapter in the desired state
+	- Reconcile of an adapter that doesn't report the desired state
+	- Reconcile with an invalid desired state
+*/
+func TestDvLIRClient_Reconcile(t *testing.T) {
+	dvlirClient, server := newSimulatedClient(t)
+	defer server.Close()
+	ctx := context.Background()
+
+	no := false
+	desired := DesiredState{NTPServer: &no, NTPName: "pool.ntp.org", SavingInterval: SavingIntervalMinute, DNSServer: "192.168.0.1"}
+	report, err := dvlirClient.Reconcile(ctx, desired, ReconcileOptions{DryRun: true})
+	if !assert.NoError(t, err, "Error during dry run") {
+		return
+	}
+	assert.Len(t, report.Plan, 3)
+	assert.False(t, report.Applied)
+	assert.Equal(t, "15min", server.State().SavingInterval, "Dry run changed the adapter")
+
+	report, err = dvlirClient.Reconcile(ctx, desired, ReconcileOptions{})
+	if !assert.NoError(t, err, "Error during Reconcile") {
+		return
+	}
+	assert.True(t, report.Applied)
+	assert.True(t, report.Verified)
+	assert.Empty(t, report.Remaining)
+	state := server.State()
+	assert.Equal(t, "min", state.SavingInterval)
+	assert.Equal(t, "no", state.NTPServer)
+	assert.Equal(t, "pool.ntp.org", state.NTPName)
+
+	report, err = dvlirClient.Reconcile(ctx, desired, ReconcileOptions{})
+	if assert.NoError(t, err, "Error during Reconcile") {
+		assert.Empty(t, report.Plan)
+		assert.False(t, report.Applied)
+		assert.True(t, report.Verified)
+	}
+
+	//The verification reads a cut NTP name
+	desired.NTPName = "time.example.org"
+	body := strings.Join([]string{"no", state.IPAddress, state.SubnetMask, state.Gateway, state.DNSServer, "no", desired.NTPName}, "#")
+	server.Inject(dvlirtest.Rule{Endpoint: "/network.txt", Nth: 2, Fault: dvlirtest.Truncate(len(body) - len(".org"))})
+	report, err = dvlirClient.Reconcile(ctx, desired, ReconcileOptions{})
+	assert.True(t, errors.Is(err, ErrNotConverged), "ErrNotConverged wasn't returned: %v", err)
+	if assert.NotNil(t, report) {
+		assert.True(t, report.Applied)
+		assert.False(t, report.Verified)
+		assert.Equal(t, []SettingChange{{Field: SettingNTPName, Current: "time.example", Desired: "time.example.org"}}, report.Remaining)
+	}
+
+	var fieldError *FieldError
+	_, err = dvlirClient.Reconcile(ctx, DesiredState{DNSServer: "dns"}, ReconcileOptions{})
+	assert.True(t, errors.As(err, &fieldError), "Invalid desired state wasn't rejected: %v", err)
+}
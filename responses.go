@@ -28,55 +28,55 @@ type DataLine struct {
 MomentaryValues contains the response of the api in case of a GetMomentaryValues request
 */
 type MomentaryValues struct {
-	MeterNumber     string    `json:"meter_number"`
-	OBISNum         string    `json:"obis_num"`
-	MomentaryPower  string    `json:"momentary_power"`
-	MeterReadingAP  string    `json:"meter_reading_ap"`
-	MeterReadingAM  string    `json:"meter_reading_am"`
-	MeterReadingsAP [9]string `json:"meter_readings_ap"`
-	MeterReadingsAM [9]string `json:"meter_readings_am"`
-	Status          string    `json:"status"`
-	SavingInterval  string    `json:"saving_interval"`
+	MeterNumber     string         `json:"meter_number"`
+	OBISNum         string         `json:"obis_num"`
+	MomentaryPower  string         `json:"momentary_power"`
+	MeterReadingAP  string         `json:"meter_reading_ap"`
+	MeterReadingAM  string         `json:"meter_reading_am"`
+	MeterReadingsAP [9]string      `json:"meter_readings_ap"`
+	MeterReadingsAM [9]string      `json:"meter_readings_am"`
+	Status          string         `json:"status"`
+	SavingInterval  SavingInterval `json:"saving_interval"`
 }
 
 /*
 GeneralInfo contains the response of the api in case of a GetGeneralInformation request
 */
 type GeneralInfo struct {
-	ServerIDMeter    string `json:"server_id_meter"`
-	MeterNumber      string `json:"meter_number"`
-	ManufacturerCode string `json:"manufacturer_code"`
-	IPAddress        string `json:"ip_address"`
-	Gateway          string `json:"gateway"`
-	DNSServer        string `json:"dns_server"`
-	NetworkName      string `json:"network_name"`
-	MACAddress       string `json:"mac_address"`
-	SavingInterval   string `json:"saving_interval"`
-	Date             string `json:"date"`
-	Time             string `json:"time"`
-	DeviceSn         string `json:"device_sn"`
-	FirmwareVersion  string `json:"firmware_version"`
+	ServerIDMeter    string         `json:"server_id_meter" yaml:"server_id_meter"`
+	MeterNumber      string         `json:"meter_number" yaml:"meter_number"`
+	ManufacturerCode string         `json:"manufacturer_code" yaml:"manufacturer_code"`
+	IPAddress        string         `json:"ip_address" yaml:"ip_address"`
+	Gateway          string         `json:"gateway" yaml:"gateway"`
+	DNSServer        string         `json:"dns_server" yaml:"dns_server"`
+	NetworkName      string         `json:"network_name" yaml:"network_name"`
+	MACAddress       string         `json:"mac_address" yaml:"mac_address"`
+	SavingInterval   SavingInterval `json:"saving_interval" yaml:"saving_interval"`
+	Date             string         `json:"date" yaml:"date"`
+	Time             string         `json:"time" yaml:"time"`
+	DeviceSn         string         `json:"device_sn" yaml:"device_sn"`
+	FirmwareVersion  string         `json:"firmware_version" yaml:"firmware_version"`
 }
 
 /*
 NetworkInfo contains the response of the api in case of a GetNetworkInformation request
 */
 type NetworkInfo struct {
-	DHCPServer string `json:"dhcp_server"`
-	IPAddress  string `json:"ip_address"`
-	SubnetMask string `json:"subnet_mask"`
-	Gateway    string `json:"gateway"`
-	DNSServer  string `json:"dns_server"`
-	NTPServer  string `json:"ntp_server"`
-	NTPName    string `json:"ntp_name"`
+	DHCPServer string `json:"dhcp_server" yaml:"dhcp_server"`
+	IPAddress  string `json:"ip_address" yaml:"ip_address"`
+	SubnetMask string `json:"subnet_mask" yaml:"subnet_mask"`
+	Gateway    string `json:"gateway" yaml:"gateway"`
+	DNSServer  string `json:"dns_server" yaml:"dns_server"`
+	NTPServer  string `json:"ntp_server" yaml:"ntp_server"`
+	NTPName    string `json:"ntp_name" yaml:"ntp_name"`
 }
 
 /*
 SystemInfo contains the response of the api in case of a GetSystemInformation request
 */
 type SystemInfo struct {
-	SavingInterval      string `json:"saving_interval"`
-	ResetCode           string `json:"reset_code"`
-	DeleteCode          string `json:"delete_code"`
-	ResetWithDefaultPwd string `json:"reset_with_default_pwd"`
+	SavingInterval      SavingInterval `json:"saving_interval" yaml:"saving_interval"`
+	ResetCode           string         `json:"reset_code" yaml:"reset_code"`
+	DeleteCode          string         `json:"delete_code" yaml:"delete_code"`
+	ResetWithDefaultPwd string         `json:"reset_with_default_pwd" yaml:"reset_with_default_pwd"`
 }
@@ -0,0 +1,341 @@
+/*
+Package rollout upgrades the firmware of a fleet of DvLIR adapters in stages.
+
+A rollout first upgrades a canary batch and then the remaining devices in waves. Every upgraded device has to come
+back with the target version and deliver momentary values, otherwise it counts as failed. The rollout stops as soon as
+the failure rate exceeds a threshold. Its state is saved after every device, so an interrupted rollout can be resumed.
+*/
+package rollout
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	dvlirclient "github.com/inexio/dvlir-restapi-go-client"
+	"github.com/inexio/dvlir-restapi-go-client/fleet"
+	"github.com/pkg/errors"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Errors returned by Run
+var (
+	//ErrCanaryFailed is returned if a device of the canary batch failed
+	ErrCanaryFailed = errors.New("Canary batch failed")
+	//ErrFailureThreshold is returned if the failure rate exceeded the threshold
+	ErrFailureThreshold = errors.New("Failure rate exceeded the threshold")
+	//ErrStateMismatch is returned if the saved state belongs to a different image or version
+	ErrStateMismatch = errors.New("Saved state belongs to a different rollout")
+)
+
+/*
+Options configures a Rollout
+*/
+type Options struct {
+	//Selector selects the devices of the inventory to upgrade, by default all devices are upgraded
+	Selector fleet.Selector
+	//Canary is the number of devices upgraded first, the rollout stops if any of them fails. The default is 1.
+	Canary int
+	//WaveSize is the number of devices per wave after the canary batch, the default is 10
+	WaveSize int
+	//Concurrency limits the number of devices upgraded in parallel within a wave, the default is WaveSize
+	Concurrency int
+	//MaxFailureRate is the highest tolerated share of failed devices (0..1) after a wave, the default 0 stops at
+	//the first failure
+	MaxFailureRate float64
+	//Timeout limits the upgrade of a single device including the reboot, a device exceeding it counts as failed. The
+	//default is 10 minutes.
+	Timeout time.Duration
+	//Store persists the state of the rollout, by default it is kept in memory
+	Store StateStore
+	//RetryFailed upgrades devices again which failed in an earlier run
+	RetryFailed bool
+	//FirmwareOptions are passed to UploadFirmwareFrom, e.g. WithRebootTimeout
+	FirmwareOptions []dvlirclient.FirmwareOption
+	//ClientOptions and NewAdapter are passed to the fleet, see fleet.Options
+	ClientOptions []dvlirclient.Option
+	NewAdapter    func(device fleet.Device, opts ...dvlirclient.Option) (dvlirclient.Adapter, error)
+}
+
+/*
+Rollout upgrades the devices of an inventory to a firmware image
+*/
+type Rollout struct {
+	name    string
+	image   []byte
+	version string
+	sha256  string
+	opts    Options
+	fleet   *fleet.Fleet
+
+	mu    sync.Mutex
+	state *State
+}
+
+/*
+New creates a rollout of the image with the given file name, which has to bring the devices to the given version
+*/
+func New(inventory *fleet.Inventory, name string, image []byte, version string, opts Options) (*Rollout, error) {
+	if len(image) == 0 {
+		return nil, errors.Wrap(dvlirclient.ErrInvalidFirmware, "image is empty")
+	}
+	if version == "" {
+		return nil, errors.New("No target version given")
+	}
+	if opts.Selector == nil {
+		opts.Selector = fleet.All()
+	}
+	if opts.Canary <= 0 {
+		opts.Canary = 1
+	}
+	if opts.WaveSize <= 0 {
+		opts.WaveSize = 10
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = opts.WaveSize
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Minute
+	}
+	if opts.Store == nil {
+		opts.Store = &MemoryStateStore{}
+	}
+
+	devices, err := fleet.New(inventory, fleet.Options{
+		Concurrency:   opts.Concurrency,
+		Timeout:       opts.Timeout,
+		ClientOptions: opts.ClientOptions,
+		NewAdapter:    opts.NewAdapter,
+	})
+	if err != nil {
+		return nil, err
+	}
+	checksum := sha256.Sum256(image)
+	return &Rollout{
+		name:    name,
+		image:   image,
+		version: version,
+		sha256:  hex.EncodeToString(checksum[:]),
+		opts:    opts,
+		fleet:   devices,
+	}, nil
+}
+
+/*
+Report lists the upgrade state of every selected device in inventory order
+*/
+type Report struct {
+	Devices []DeviceReport
+	//Waves is the number of waves run, including the canary batch
+	Waves int
+}
+
+/*
+DeviceReport is the upgrade state of a device
+*/
+type DeviceReport struct {
+	Name string
+	DeviceState
+}
+
+/*
+Count returns the number of devices with the given status
+*/
+func (r *Report) Count(status Status) int {
+	n := 0
+	for _, device := range r.Devices {
+		if device.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+/*
+Run upgrades the selected devices which weren't upgraded in an earlier run with the same state store. It stops at the
+first failed canary, if the failure rate exceeds the threshold or if ctx is done. The report is returned in every case.
+*/
+func (r *Rollout) Run(ctx context.Context) (*Report, error) {
+	devices := r.fleet.Devices(r.opts.Selector)
+	if err := r.loadState(devices); err != nil {
+		return nil, err
+	}
+	defer r.fleet.Close(context.Background())
+
+	var pending []string
+	for _, device := range devices {
+		state := r.state.Devices[device.Name]
+		if state.Status == StatusPending || (state.Status == StatusFailed && r.opts.RetryFailed) {
+			pending = append(pending, device.Name)
+		}
+	}
+
+	var err error
+	waves, succeeded, failed := 0, 0, 0
+	for len(pending) > 0 {
+		size := r.opts.WaveSize
+		if waves == 0 {
+			size = r.opts.Canary
+		}
+		if size > len(pending) {
+			size = len(pending)
+		}
+		wave := pending[:size]
+		pending = pending[size:]
+		waves++
+
+		report := r.fleet.Run(ctx, fleet.Names(wave...), r.upgrade(ctx, waves))
+		for _, result := range report.Results {
+			if _, ran := result.Value.(DeviceState); !ran && result.Err != nil && ctx.Err() == nil {
+				//The login failed before the upgrade started
+				state := DeviceState{Status: StatusFailed, Error: result.Err.Error(), Wave: waves,
+					Attempts: r.attempts(result.Device.Name) + 1, Duration: result.Duration}
+				if saveErr := r.save(result.Device.Name, state); saveErr != nil {
+					return r.report(devices, waves), saveErr
+				}
+			}
+			switch r.status(result.Device.Name) {
+			case StatusSucceeded, StatusSkipped:
+				succeeded++
+			case StatusFailed:
+				failed++
+			}
+		}
+
+		if ctx.Err() != nil {
+			err = errors.Wrap(ctx.Err(), "Rollout was interrupted")
+			break
+		}
+		if waves == 1 && failed > 0 {
+			err = ErrCanaryFailed
+			break
+		}
+		if failed > 0 && float64(failed)/float64(succeeded+failed) > r.opts.MaxFailureRate {
+			err = errors.Wrap(ErrFailureThreshold, strconv.Itoa(failed)+" of "+strconv.Itoa(succeeded+failed)+
+				" devices failed")
+			break
+		}
+	}
+
+	return r.report(devices, waves), err
+}
+
+/*
+loadState loads the saved state or creates a new one and adds devices which are new in the inventory
+*/
+func (r *Rollout) loadState(devices []fleet.Device) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	state, err := r.opts.Store.LoadState()
+	if err != nil {
+		return err
+	}
+	if state == nil {
+		state = &State{SHA256: r.sha256, Version: r.version}
+	}
+	if state.SHA256 != r.sha256 || state.Version != r.version {
+		return errors.Wrap(ErrStateMismatch, "saved state is for version "+state.Version)
+	}
+	if state.Devices == nil {
+		state.Devices = make(map[string]*DeviceState)
+	}
+	for _, device := range devices {
+		if _, ok := state.Devices[device.Name]; !ok {
+			state.Devices[device.Name] = &DeviceState{Status: StatusPending}
+		}
+	}
+	r.state = state
+	return r.opts.Store.SaveState(state)
+}
+
+/*
+upgrade returns the operation which upgrades a single device. rolloutCtx is the context of Run, the operation gets a
+context limited by Options.Timeout.
+*/
+func (r *Rollout) upgrade(rolloutCtx context.Context, wave int) fleet.Operation {
+	return func(ctx context.Context, device fleet.Device, adapter dvlirclient.Adapter) (interface{}, error) {
+		start := time.Now()
+		state := DeviceState{Status: StatusFailed, Wave: wave, Attempts: r.attempts(device.Name) + 1, StartedAt: start}
+
+		err := r.upgradeDevice(ctx, adapter, &state)
+		state.Duration = time.Since(start)
+		switch {
+		case err == nil:
+		case rolloutCtx.Err() != nil && errors.Is(err, rolloutCtx.Err()):
+			//The device is upgraded again when the rollout is resumed, a device exceeding Options.Timeout failed
+			state.Status = StatusPending
+			state.Error = err.Error()
+		default:
+			state.Error = err.Error()
+		}
+
+		if saveErr := r.save(device.Name, state); saveErr != nil && err == nil {
+			err = saveErr
+		}
+		return state, err
+	}
+}
+
+func (r *Rollout) upgradeDevice(ctx context.Context, adapter dvlirclient.Adapter, state *DeviceState) error {
+	info, err := adapter.GetGeneralInformationContext(ctx)
+	if err != nil {
+		return errors.Wrap(err, "Error while reading the firmware version")
+	}
+	state.PreviousVersion = info.FirmwareVersion
+	status := StatusSucceeded
+	if info.FirmwareVersion == r.version {
+		status, state.Version = StatusSkipped, info.FirmwareVersion
+	} else {
+		opts := append([]dvlirclient.FirmwareOption{dvlirclient.WithExpectedFirmwareVersion(r.version)},
+			r.opts.FirmwareOptions...)
+		report, err := adapter.UploadFirmwareFrom(ctx, r.name, bytes.NewReader(r.image), int64(len(r.image)), opts...)
+		if report != nil {
+			state.Version = report.Version
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	values, err := adapter.GetMomentaryValuesContext(ctx)
+	if err != nil {
+		return errors.Wrap(err, "Health check failed")
+	}
+	if _, err = values.Momentary(); err != nil {
+		return errors.Wrap(err, "Health check failed")
+	}
+	state.Status = status
+	return nil
+}
+
+func (r *Rollout) save(name string, state DeviceState) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.state.Devices[name] = &state
+	return r.opts.Store.SaveState(r.state)
+}
+
+func (r *Rollout) attempts(name string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.state.Devices[name].Attempts
+}
+
+func (r *Rollout) status(name string) Status {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.state.Devices[name].Status
+}
+
+func (r *Rollout) report(devices []fleet.Device, waves int) *Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	report := &Report{Waves: waves}
+	for _, device := range devices {
+		report.Devices = append(report.Devices, DeviceReport{Name: device.Name, DeviceState: *r.state.Devices[device.Name]})
+	}
+	return report
+}
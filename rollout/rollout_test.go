@@ -0,0 +1,208 @@
+package rollout
+
+import (
+	"context"
+	dvlirclient "github.com/inexio/dvlir-restapi-go-client"
+	"github.com/inexio/dvlir-restapi-go-client/dvlirtest"
+	"github.com/inexio/dvlir-restapi-go-client/fleet"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+var testImage = dvlirtest.FirmwareImage("1.22", []byte("payload"))
+
+/*
+startFleet starts n simulated adapters and returns their inventory
+*/
+func startFleet(n int) ([]*dvlirtest.Server, *fleet.Inventory, func()) {
+	var servers []*dvlirtest.Server
+	inventory := &fleet.Inventory{}
+	for i := 0; i < n; i++ {
+		server := dvlirtest.NewServer()
+		servers = append(servers, server)
+		inventory.Devices = append(inventory.Devices, fleet.Device{
+			Name:     "dvlir-" + strconv.Itoa(i),
+			Address:  server.Address(),
+			Password: server.Password(),
+		})
+	}
+	return servers, inventory, func() {
+		for _, server := range servers {
+			server.Close()
+		}
+	}
+}
+
+func testOptions() Options {
+	return Options{
+		Canary:          1,
+		WaveSize:        2,
+		Timeout:         10 * time.Second,
+		FirmwareOptions: []dvlirclient.FirmwareOption{dvlirclient.WithRebootTimeout(5*time.Second, 10*time.Millisecond)},
+	}
+}
+
+/*
+TestRollout_Run covers:
+	- Run with a canary batch and waves
+	- Run with a device that already runs the target version
+	- Run with decorated adapters
+*/
+func TestRollout_Run(t *testing.T) {
+	servers, inventory, done := startFleet(5)
+	defer done()
+	servers[3].Update(func(state *dvlirtest.State) { state.FirmwareVersion = "1.22" })
+
+	metrics := &dvlirclient.OperationMetrics{}
+	opts := testOptions()
+	opts.NewAdapter = func(device fleet.Device, opts ...dvlirclient.Option) (dvlirclient.Adapter, error) {
+		client, err := dvlirclient.NewDvLIRClient(device.Address, device.Credentials(), opts...)
+		if err != nil {
+			return nil, err
+		}
+		return dvlirclient.NewCachingAdapter(dvlirclient.NewMetricsAdapter(client, metrics), time.Minute), nil
+	}
+	rollout, err := New(inventory, "dvlir-1.22.bin", testImage, "1.22", opts)
+	if !assert.NoError(t, err, "Error while creating rollout") {
+		return
+	}
+	report, err := rollout.Run(context.Background())
+	if !assert.NoError(t, err, "Error during rollout") {
+		return
+	}
+	assert.Equal(t, 3, report.Waves)
+	assert.Equal(t, 4, report.Count(StatusSucceeded))
+	assert.Equal(t, 1, report.Count(StatusSkipped))
+	if assert.Len(t, report.Devices, 5) {
+		assert.Equal(t, "1.21", report.Devices[0].PreviousVersion)
+		assert.Equal(t, "1.22", report.Devices[0].Version)
+		assert.Equal(t, 1, report.Devices[0].Wave)
+		assert.Equal(t, 3, report.Devices[4].Wave)
+	}
+	for i, server := range servers {
+		assert.Equal(t, "1.22", server.State().FirmwareVersion, "Device %d wasn't upgraded", i)
+	}
+	assert.Equal(t, 0, servers[3].Reboots(), "Up to date device was upgraded")
+	assert.Equal(t, 4, metrics.Stats("UploadFirmwareFrom").Calls, "Uploads didn't pass the decorators")
+}
+
+/*
+TestRollout_Resume covers:
+	- Run stopping at the failure threshold
+	- Run resuming an interrupted rollout from a file
+	- Run retrying a failed device whose login fails
+	- Run with a state of a different rollout
+	- Run with a failed canary
+*/
+func TestRollout_Resume(t *testing.T) {
+	servers, inventory, done := startFleet(4)
+	defer done()
+	servers[2].Inject(dvlirtest.Rule{Endpoint: "/upload.cmd", Times: 1, Fault: dvlirtest.Drop()})
+
+	dir, err := ioutil.TempDir("", "rollout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	opts := testOptions()
+	opts.Store = NewFileStateStore(filepath.Join(dir, "rollout.json"))
+
+	rollout, err := New(inventory, "dvlir-1.22.bin", testImage, "1.22", opts)
+	if !assert.NoError(t, err, "Error while creating rollout") {
+		return
+	}
+	report, err := rollout.Run(context.Background())
+	assert.True(t, errors.Is(err, ErrFailureThreshold), "ErrFailureThreshold wasn't returned: %v", err)
+	assert.Equal(t, []Status{StatusSucceeded, StatusSucceeded, StatusFailed, StatusPending}, statuses(report))
+	assert.Contains(t, report.Devices[2].Error, "Error during UploadFirmware")
+
+	opts.RetryFailed = true
+	password := servers[2].Password()
+	servers[2].Update(func(state *dvlirtest.State) { state.Password = "changed" })
+	rollout, err = New(inventory, "dvlir-1.22.bin", testImage, "1.22", opts)
+	if !assert.NoError(t, err, "Error while creating rollout") {
+		return
+	}
+	report, err = rollout.Run(context.Background())
+	assert.True(t, errors.Is(err, ErrCanaryFailed), "ErrCanaryFailed wasn't returned: %v", err)
+	assert.Equal(t, []Status{StatusSucceeded, StatusSucceeded, StatusFailed, StatusPending}, statuses(report))
+	assert.Contains(t, report.Devices[2].Error, "Error during login", "Failed login of a retried device wasn't recorded")
+	assert.Equal(t, 2, report.Devices[2].Attempts)
+
+	servers[2].Update(func(state *dvlirtest.State) { state.Password = password })
+	rollout, err = New(inventory, "dvlir-1.22.bin", testImage, "1.22", opts)
+	if !assert.NoError(t, err, "Error while creating rollout") {
+		return
+	}
+	report, err = rollout.Run(context.Background())
+	if assert.NoError(t, err, "Error while resuming rollout") {
+		assert.Equal(t, []Status{StatusSucceeded, StatusSucceeded, StatusSucceeded, StatusSucceeded}, statuses(report))
+		assert.Equal(t, 3, report.Devices[2].Attempts)
+	}
+	assert.Equal(t, 1, servers[0].Reboots(), "Upgraded device was upgraded again")
+
+	rollout, err = New(inventory, "dvlir-1.23.bin", dvlirtest.FirmwareImage("1.23", nil), "1.23", opts)
+	if assert.NoError(t, err, "Error while creating rollout") {
+		_, err = rollout.Run(context.Background())
+		assert.True(t, errors.Is(err, ErrStateMismatch), "ErrStateMismatch wasn't returned: %v", err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unreachable := listener.Addr().String()
+	listener.Close()
+	inventory.Devices[0].Address = unreachable
+	opts = testOptions()
+	opts.Timeout = time.Second
+	rollout, err = New(inventory, "dvlir-1.23.bin", dvlirtest.FirmwareImage("1.23", nil), "1.23", opts)
+	if assert.NoError(t, err, "Error while creating rollout") {
+		report, err = rollout.Run(context.Background())
+		assert.True(t, errors.Is(err, ErrCanaryFailed), "ErrCanaryFailed wasn't returned: %v", err)
+		assert.Equal(t, []Status{StatusFailed, StatusPending, StatusPending, StatusPending}, statuses(report))
+	}
+}
+
+/*
+TestRollout_Timeout covers:
+	- Run with a canary that doesn't answer within Timeout
+	- Run without upgrading the timed out canary again
+*/
+func TestRollout_Timeout(t *testing.T) {
+	servers, inventory, done := startFleet(2)
+	defer done()
+	servers[0].Inject(dvlirtest.Rule{Endpoint: "/upload.cmd", Fault: dvlirtest.Latency(3 * time.Second)})
+
+	opts := testOptions()
+	opts.Timeout = time.Second
+	rollout, err := New(inventory, "dvlir-1.22.bin", testImage, "1.22", opts)
+	if !assert.NoError(t, err, "Error while creating rollout") {
+		return
+	}
+	report, err := rollout.Run(context.Background())
+	assert.True(t, errors.Is(err, ErrCanaryFailed), "ErrCanaryFailed wasn't returned: %v", err)
+	assert.Equal(t, []Status{StatusFailed, StatusPending}, statuses(report))
+	assert.Contains(t, report.Devices[0].Error, context.DeadlineExceeded.Error())
+
+	report, err = rollout.Run(context.Background())
+	assert.NoError(t, err, "Error during second run")
+	assert.Equal(t, []Status{StatusFailed, StatusSucceeded}, statuses(report))
+	assert.Equal(t, 1, report.Devices[0].Attempts)
+	assert.Equal(t, 1, servers[0].Injected())
+}
+
+func statuses(report *Report) []Status {
+	var statuses []Status
+	for _, device := range report.Devices {
+		statuses = append(statuses, device.Status)
+	}
+	return statuses
+}
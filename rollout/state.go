@@ -0,0 +1,164 @@
+package rollout
+
+import (
+	"encoding/json"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+/*
+Status is the upgrade status of a device
+*/
+type Status string
+
+// Upgrade states of a device
+const (
+	//StatusPending means that the device wasn't upgraded yet or the upgrade was interrupted
+	StatusPending Status = "pending"
+	//StatusSucceeded means that the device came back with the target version and passed the health check
+	StatusSucceeded Status = "succeeded"
+	//StatusSkipped means that the device already ran the target version and passed the health check
+	StatusSkipped Status = "skipped"
+	//StatusFailed means that the upgrade or the health check of the device failed
+	StatusFailed Status = "failed"
+)
+
+/*
+DeviceState is the upgrade state of a single device
+*/
+type DeviceState struct {
+	Status          Status        `json:"status"`
+	Error           string        `json:"error,omitempty"`
+	PreviousVersion string        `json:"previous_version,omitempty"`
+	Version         string        `json:"version,omitempty"`
+	Wave            int           `json:"wave"`
+	Attempts        int           `json:"attempts"`
+	StartedAt       time.Time     `json:"started_at,omitempty"`
+	Duration        time.Duration `json:"duration,omitempty"`
+}
+
+/*
+State is the persisted state of a rollout. It identifies the rollout by the checksum of the image and the target
+version, so a different rollout can't resume it by accident.
+*/
+type State struct {
+	SHA256  string                  `json:"sha256"`
+	Version string                  `json:"version"`
+	Devices map[string]*DeviceState `json:"devices"`
+}
+
+/*
+StateStore persists the state of a rollout
+*/
+type StateStore interface {
+	//LoadState returns the saved state, nil is returned if no state was saved yet
+	LoadState() (*State, error)
+	//SaveState stores the state
+	SaveState(state *State) error
+}
+
+/*
+MemoryStateStore keeps the state in memory, it is lost when the process ends
+*/
+type MemoryStateStore struct {
+	mu   sync.Mutex
+	data []byte
+}
+
+/*
+LoadState returns the saved state
+*/
+func (m *MemoryStateStore) LoadState() (*State, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.data == nil {
+		return nil, nil
+	}
+	var state State
+	if err := json.Unmarshal(m.data, &state); err != nil {
+		return nil, errors.Wrap(err, "Error while decoding rollout state")
+	}
+	return &state, nil
+}
+
+/*
+SaveState stores a copy of the state
+*/
+func (m *MemoryStateStore) SaveState(state *State) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return errors.Wrap(err, "Error while encoding rollout state")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.data = data
+	return nil
+}
+
+/*
+FileStateStore keeps the state in a JSON file. The file is replaced atomically on every save, so a crash never leaves
+a partially written file behind.
+*/
+type FileStateStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+/*
+NewFileStateStore returns a state store that uses the file at path. The file is created on the first save.
+*/
+func NewFileStateStore(path string) *FileStateStore {
+	return &FileStateStore{path: path}
+}
+
+/*
+LoadState returns the saved state
+*/
+func (f *FileStateStore) LoadState() (*State, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	data, err := ioutil.ReadFile(f.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "Error while reading rollout state")
+	}
+	var state State
+	if err = json.Unmarshal(data, &state); err != nil {
+		return nil, errors.Wrap(err, "Error while decoding rollout state")
+	}
+	return &state, nil
+}
+
+/*
+SaveState stores the state
+*/
+func (f *FileStateStore) SaveState(state *State) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "Error while encoding rollout state")
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(f.path), filepath.Base(f.path)+".*.tmp")
+	if err != nil {
+		return errors.Wrap(err, "Error while writing rollout state")
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return errors.Wrap(err, "Error while writing rollout state")
+	}
+	if err = os.Rename(tmp.Name(), f.path); err != nil {
+		return errors.Wrap(err, "Error while writing rollout state")
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+package dvlirclient
+
+import (
+	"bufio"
+	"context"
+	"github.com/go-resty/resty/v2"
+	"io"
+	"sync"
+	"time"
+)
+
+/*
+session contains the password of a client and the session ID once it is logged in
+*/
+type session struct {
+	mu          sync.Mutex
+	password    string
+	id          string
+	established time.Time
+	renewals    int
+	//renewing is the renewal in flight, requests which found the same expired session wait for it
+	renewing *renewal
+}
+
+/*
+renewal is a login requested by renew. done is closed once id and err are set.
+*/
+type renewal struct {
+	done chan struct{}
+	id   string
+	err  error
+}
+
+/*
+secret returns the password used for the login
+*/
+func (s *session) secret() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.password
+}
+
+/*
+setPassword replaces the password used for the login, e.g. after the password of the adapter was changed
+*/
+func (s *session) setPassword(password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.password = password
+}
+
+/*
+start stores a new session ID
+*/
+func (s *session) start(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.id = id
+	s.established = time.Now()
+}
+
+/*
+clear removes the session ID after a logout
+*/
+func (s *session) clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.id = ""
+	s.established = time.Time{}
+}
+
+/*
+current returns the current session ID
+*/
+func (s *session) current() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.id
+}
+
+/*
+renew requests a new session ID if the session ID is still the stale one. Concurrent requests that found the same
+expired session wait for the login in flight, so they only cause a single login. The lock isn't held during the login,
+so other requests aren't blocked by a slow login.
+*/
+func (s *session) renew(ctx context.Context, stale string, login func(ctx context.Context) (string, error)) (string, error) {
+	s.mu.Lock()
+	if s.id != stale {
+		id := s.id
+		s.mu.Unlock()
+		return id, nil
+	}
+	if r := s.renewing; r != nil {
+		s.mu.Unlock()
+		select {
+		case <-r.done:
+			return r.id, r.err
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+	r := &renewal{done: make(chan struct{})}
+	s.renewing = r
+	s.mu.Unlock()
+
+	id, err := login(ctx)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.renewing = nil
+	switch {
+	case err != nil:
+		r.err = err
+	case s.id == stale:
+		s.id = id
+		s.established = time.Now()
+		s.renewals++
+		r.id = id
+	default:
+		//A login or logout replaced the session during the renewal
+		r.id = s.id
+	}
+	close(r.done)
+	return r.id, r.err
+}
+
+/*
+SessionAge returns the time since the current session was established. Zero is returned if the client isn't logged in.
+*/
+func (d *DvLIRClient) SessionAge() time.Duration {
+	if !d.isValid() {
+		return 0
+	}
+	d.session.mu.Lock()
+	defer d.session.mu.Unlock()
+	if d.session.id == "" {
+		return 0
+	}
+	return time.Since(d.session.established)
+}
+
+/*
+SessionRenewals returns how often an expired session was renewed automatically
+*/
+func (d *DvLIRClient) SessionRenewals() int {
+	if !d.isValid() {
+		return 0
+	}
+	d.session.mu.Lock()
+	defer d.session.mu.Unlock()
+	return d.session.renewals
+}
+
+/*
+withSession performs a request with the current session ID. If session renewal is enabled and the adapter returned its
+login page, the client logs in again and the request is replayed once with the new session ID.
+*/
+func (d *DvLIRClient) withSession(ctx context.Context, request func(sessionID string) (*resty.Response, error)) (*resty.Response, error) {
+	sessionID := d.session.current()
+	res, err := request(sessionID)
+	if err != nil || !d.renewSession || !isLoginPage(res.String()) {
+		return res, err
+	}
+
+	sessionID, err = d.session.renew(ctx, sessionID, d.requestSessionID)
+	if err != nil {
+		return nil, err
+	}
+	return request(sessionID)
+}
+
+/*
+getSession performs a get request for the endpoint with the session ID and the given query parameters
+*/
+func (d *DvLIRClient) getSession(ctx context.Context, endpoint string, params string) (*resty.Response, error) {
+	return d.withSession(ctx, func(sessionID string) (*resty.Response, error) {
+		return d.get(ctx, endpoint+"?sid="+sessionID+params, "", "")
+	})
+}
+
+/*
+streamSession performs a get request for the endpoint with the session ID like getSession, but returns the response
+body without reading it. The body has to be closed by the caller.
+*/
+func (d *DvLIRClient) streamSession(ctx context.Context, endpoint string, params string) (io.ReadCloser, error) {
+	open := func(sessionID string) (io.ReadCloser, error) {
+		body, err := d.getStream(ctx, endpoint+"?sid="+sessionID+params)
+		if err != nil {
+			return nil, err
+		}
+		reader := bufio.NewReader(body)
+		start, _ := reader.Peek(len("<!DOCTYPE"))
+		if isLoginPage(string(start)) {
+			body.Close()
+			return nil, ErrSessionExpired
+		}
+		return &bufferedBody{Reader: reader, Closer: body}, nil
+	}
+
+	sessionID := d.session.current()
+	body, err := open(sessionID)
+	if err != ErrSessionExpired || !d.renewSession {
+		return body, err
+	}
+
+	sessionID, err = d.session.renew(ctx, sessionID, d.requestSessionID)
+	if err != nil {
+		return nil, err
+	}
+	return open(sessionID)
+}
+
+/*
+bufferedBody reads a response body through a buffer and closes the original body
+*/
+type bufferedBody struct {
+	io.Reader
+	io.Closer
+}
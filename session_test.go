@@ -0,0 +1,145 @@
+package dvlirclient
+
+import (
+	"context"
+	"github.com/inexio/dvlir-restapi-go-client/dvlirtest"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+/*
+TestDvLIRClient_SessionRenewal covers:
+	- GetMomentaryValues with an expired session and without session renewal
+	- concurrent GetMomentaryValues with an expired session and session renewal
+	- ChangeSavingInterval with an expired session and session renewal
+	- SessionAge and SessionRenewals
+	- Logout
+*/
+func TestDvLIRClient_SessionRenewal(t *testing.T) {
+	dvlirClient, server := newSimulatedClient(t)
+	defer server.Close()
+
+	server.ExpireSession()
+	_, err := dvlirClient.GetMomentaryValues()
+	assert.EqualError(t, err, "Login page was returned")
+	assert.Equal(t, 0, dvlirClient.SessionRenewals())
+
+	dvlirClient, err = NewDvLIRClient(server.Address(), server.Password(), WithSessionRenewal())
+	if !assert.NoError(t, err, "Error while creating client") {
+		return
+	}
+	assert.Equal(t, int64(0), int64(dvlirClient.SessionAge()), "Session age without login")
+	err = dvlirClient.Login()
+	if !assert.NoError(t, err, "Error during Login") {
+		return
+	}
+	assert.True(t, dvlirClient.SessionAge() > 0, "Session age wasn't tracked")
+
+	server.ExpireSession()
+	logins := server.Logins()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := dvlirClient.GetMomentaryValues()
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.NoError(t, err, "Expired session wasn't renewed")
+	}
+	assert.Equal(t, logins+1, server.Logins(), "Expired session was renewed more than once")
+	assert.Equal(t, 1, dvlirClient.SessionRenewals())
+
+	server.ExpireSession()
+	_, err = dvlirClient.ChangeSavingInterval("min")
+	if assert.NoError(t, err, "Expired session wasn't renewed") {
+		assert.Equal(t, "min", server.State().SavingInterval)
+	}
+	assert.Equal(t, 2, dvlirClient.SessionRenewals())
+
+	err = dvlirClient.Logout()
+	if assert.NoError(t, err, "Error during Logout") {
+		assert.Equal(t, int64(0), int64(dvlirClient.SessionAge()), "Session age after logout")
+	}
+}
+
+/*
+TestDvLIRClient_SessionRenewalAfterPasswordChange covers:
+	- renewal of an expired session with the password set by ChangePassword
+*/
+func TestDvLIRClient_SessionRenewalAfterPasswordChange(t *testing.T) {
+	server := dvlirtest.NewServer()
+	defer server.Close()
+	dvlirClient, err := NewDvLIRClient(server.Address(), server.Password(), WithSessionRenewal())
+	if !assert.NoError(t, err, "Error while creating client") {
+		return
+	}
+	if !assert.NoError(t, dvlirClient.Login(), "Error during Login") {
+		return
+	}
+
+	_, err = dvlirClient.ChangePassword(server.Password(), "changed", "changed")
+	if !assert.NoError(t, err, "Error during ChangePassword") {
+		return
+	}
+	server.ExpireSession()
+	_, err = dvlirClient.GetMomentaryValues()
+	assert.NoError(t, err, "Expired session wasn't renewed with the new password")
+	assert.Equal(t, 1, dvlirClient.SessionRenewals())
+}
+
+/*
+TestSession_Renew covers:
+	- current isn't blocked by a renewal in flight
+	- concurrent renewals of the same session wait for the login in flight
+	- a waiting renewal returns when its context is done
+*/
+func TestSession_Renew(t *testing.T) {
+	s := &session{id: "stale"}
+	release := make(chan struct{})
+	logins := 0
+	login := func(ctx context.Context) (string, error) {
+		logins++
+		<-release
+		return "fresh", nil
+	}
+
+	results := make(chan string, 2)
+	go func() {
+		id, _ := s.renew(context.Background(), "stale", login)
+		results <- id
+	}()
+	for {
+		s.mu.Lock()
+		started := s.renewing != nil
+		s.mu.Unlock()
+		if started {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, "stale", s.current(), "current was blocked or changed by the renewal")
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := s.renew(canceled, "stale", login)
+	assert.Equal(t, context.Canceled, err)
+
+	go func() {
+		id, _ := s.renew(context.Background(), "stale", login)
+		results <- id
+	}()
+	close(release)
+	assert.Equal(t, "fresh", <-results)
+	assert.Equal(t, "fresh", <-results)
+	assert.Equal(t, 1, logins, "Session was renewed more than once")
+	assert.Equal(t, "fresh", s.current())
+}
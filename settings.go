@@ -0,0 +1,129 @@
+package dvlirclient
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"net"
+	"strings"
+)
+
+// Names of the settings that can be changed, as used in SettingChange.Field
+const (
+	SettingSavingInterval      = "system.saving_interval"
+	SettingResetWithDefaultPwd = "system.reset_with_default_pwd"
+	SettingDNSServer           = "network.dns_server"
+	SettingNTPServer           = "network.ntp_server"
+	SettingNTPName             = "network.ntp_name"
+	SettingDHCP                = "network.dhcp_server"
+	SettingIPAddress           = "network.ip_address"
+	SettingSubnetMask          = "network.subnet_mask"
+	SettingGateway             = "network.gateway"
+)
+
+/*
+SettingChange is the change of a single setting of the adapter
+*/
+type SettingChange struct {
+	//Field is the name of the setting, e.g. SettingNTPName
+	Field string `json:"field" yaml:"field"`
+	//Current is the value reported by the adapter
+	Current string `json:"current" yaml:"current"`
+	//Desired is the value the setting is changed to
+	Desired string `json:"desired" yaml:"desired"`
+}
+
+/*
+applySettingChanges applies the changes in a safe order: the system settings first, then the network settings which
+keep the address of the adapter in a single request and the address last. If the address changes, the adapter is
+followed to its new address with MigrateAddress and the report of the migration is returned.
+*/
+func (d *DvLIRClient) applySettingChanges(ctx context.Context, changes []SettingChange, migrate MigrateOptions) (*MigrationReport, error) {
+	var network, address NetworkSettings
+	var networkChanged, addressChanged bool
+	for _, change := range changes {
+		var err error
+		switch change.Field {
+		case SettingSavingInterval:
+			var interval SavingInterval
+			if interval, err = ParseSavingInterval(change.Desired); err == nil {
+				_, err = d.ChangeSavingIntervalContext(ctx, interval)
+			}
+		case SettingResetWithDefaultPwd:
+			var allow bool
+			if allow, err = parseYesNo(change.Field, change.Desired); err == nil {
+				_, err = d.AllowResetWithPwdContext(ctx, yesNo(allow))
+			}
+		case SettingDNSServer:
+			network.DNSServer, err = parseIPv4(change.Field, change.Desired)
+			networkChanged = true
+		case SettingNTPServer:
+			var enabled bool
+			enabled, err = parseYesNo(change.Field, change.Desired)
+			network.NTPServer = &enabled
+			networkChanged = true
+		case SettingNTPName:
+			network.NTPName = change.Desired
+			networkChanged = true
+		case SettingDHCP:
+			var enabled bool
+			enabled, err = parseYesNo(change.Field, change.Desired)
+			address.DHCP = &enabled
+			addressChanged = true
+		case SettingIPAddress:
+			address.IPAddress, err = parseIPv4(change.Field, change.Desired)
+			addressChanged = true
+		case SettingSubnetMask:
+			var mask net.IP
+			mask, err = parseIPv4(change.Field, change.Desired)
+			address.SubnetMask = net.IPMask(mask.To4())
+			addressChanged = true
+		case SettingGateway:
+			address.Gateway, err = parseIPv4(change.Field, change.Desired)
+			addressChanged = true
+		default:
+			err = errors.New("Unknown setting " + change.Field)
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "Error while changing "+change.Field)
+		}
+	}
+
+	if networkChanged {
+		if _, err := d.ApplyNetworkSettings(ctx, network); err != nil {
+			return nil, errors.Wrap(err, "Error while changing network settings")
+		}
+	}
+	if !addressChanged {
+		return nil, nil
+	}
+	if address.IPAddress == nil && (address.DHCP == nil || !*address.DHCP) {
+		//The adapter stays at its address, e.g. if only the subnet mask or gateway changes
+		if _, err := d.ApplyNetworkSettings(ctx, address); err != nil {
+			return nil, errors.Wrap(err, "Error while changing network settings")
+		}
+		return nil, nil
+	}
+	report, err := d.MigrateAddress(ctx, address, migrate)
+	if err != nil {
+		return report, errors.Wrap(err, "Error while changing the address")
+	}
+	return report, nil
+}
+
+func parseYesNo(field, value string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "yes":
+		return true, nil
+	case "no":
+		return false, nil
+	}
+	return false, &FieldError{Field: field, Value: value, Err: errors.New("expected yes or no")}
+}
+
+func parseIPv4(field, value string) (net.IP, error) {
+	ip := net.ParseIP(strings.TrimSpace(value))
+	if ip == nil || ip.To4() == nil {
+		return nil, &FieldError{Field: field, Value: value, Err: errors.New("invalid IPv4 address")}
+	}
+	return ip, nil
+}
@@ -0,0 +1,104 @@
+package dvlirclient
+
+import (
+	"bufio"
+	"context"
+	"github.com/pkg/errors"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+/*
+DataFileScanner reads the lines of the daten.csv file one at a time from an io.Reader, so the whole file never has to
+be held in memory.
+
+Scan advances to the next line, which is returned by Line. If the current line couldn't be parsed, Err returns its
+ParseError and Scan can be called again to continue with the next line. Scan returns false at the end of the input or
+if reading fails, the read error is returned by Err afterwards. Blank lines are skipped.
+*/
+type DataFileScanner struct {
+	scanner *bufio.Scanner
+	number  int
+	line    DataLine
+	err     error
+}
+
+/*
+NewDataFileScanner returns a scanner that reads the daten.csv file from r
+*/
+func NewDataFileScanner(r io.Reader) *DataFileScanner {
+	return &DataFileScanner{scanner: bufio.NewScanner(r)}
+}
+
+/*
+Scan advances the scanner to the next line
+*/
+func (s *DataFileScanner) Scan() bool {
+	s.line, s.err = DataLine{}, nil
+	for s.scanner.Scan() {
+		s.number++
+		text := s.scanner.Text()
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		s.line, s.err = parseDataLine(text, s.number)
+		return true
+	}
+	s.err = s.scanner.Err()
+	return false
+}
+
+/*
+Line returns the current line
+*/
+func (s *DataFileScanner) Line() DataLine {
+	return s.line
+}
+
+/*
+Err returns the parse error of the current line or, after Scan returned false, the read error
+*/
+func (s *DataFileScanner) Err() error {
+	return s.err
+}
+
+/*
+ParseDataFileFunc reads the daten.csv file from r and calls fn for every line. If a line couldn't be parsed, fn is
+called with its ParseError. Returning an error from fn stops the parsing, the error is returned by ParseDataFileFunc.
+*/
+func ParseDataFileFunc(r io.Reader, fn func(line DataLine, err error) error) error {
+	scanner := NewDataFileScanner(r)
+	for scanner.Scan() {
+		if err := fn(scanner.Line(), scanner.Err()); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+/*
+GetDataFileStream performs a GetDataFile operation and parses the lines as they arrive from the adapter. fn is called
+for every line as described for ParseDataFileFunc.
+*/
+func (d *DvLIRClient) GetDataFileStream(ctx context.Context, lines int, fn func(line DataLine, err error) error) error {
+	if !d.isValid() {
+		return &NotValidError{}
+	}
+
+	if lines < 1 || lines > maxDataLines {
+		err := errors.New("Line number has to be either <0 or >14400")
+		return errors.Wrap(err, "Invalid line number: "+strconv.Itoa(lines))
+	}
+
+	linesE := url.QueryEscape(strconv.Itoa(lines))
+
+	body, err := d.streamSession(ctx, "/daten.csv", "&lines="+linesE)
+	if err != nil {
+		return errors.Wrap(err, "Error during GetDataFile request")
+	}
+	defer body.Close()
+
+	return ParseDataFileFunc(body, fn)
+}
@@ -0,0 +1,163 @@
+package dvlirclient
+
+import (
+	"context"
+	"github.com/inexio/dvlir-restapi-go-client/dvlirtest"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+/*
+TestDataFileScanner covers:
+	- DataFileScanner with valid, invalid and blank lines
+	- ParseDataFileFunc stopping on an error of the callback
+*/
+func TestDataFileScanner(t *testing.T) {
+	input := "1;01.01.2020;00:15:00;20190001;01234567;15873.4521;12100.2874;3773.1647;421.3370;421.3370;0.0000;150;0000\r\n" +
+		"2;01.01.2020;00:30:00;20190001\r\n" +
+		"\r\n" +
+		"3;01.01.2020;00:45:00;20190001;01234567;15873.4896;12100.3249;3773.1647;421.3370;421.3370;0.0000;150;0000\r\n"
+
+	scanner := NewDataFileScanner(strings.NewReader(input))
+	var indexes []string
+	var parseErrors []*ParseError
+	for scanner.Scan() {
+		var parseError *ParseError
+		if errors.As(scanner.Err(), &parseError) {
+			parseErrors = append(parseErrors, parseError)
+			continue
+		}
+		indexes = append(indexes, scanner.Line().Index)
+	}
+	assert.NoError(t, scanner.Err())
+	assert.Equal(t, []string{"1", "3"}, indexes)
+	if assert.Len(t, parseErrors, 1) {
+		assert.Equal(t, 2, parseErrors[0].Line)
+		assert.Equal(t, 4, parseErrors[0].Actual)
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+	err := ParseDataFileFunc(strings.NewReader(input), func(line DataLine, err error) error {
+		calls++
+		return err
+	})
+	assert.Error(t, err, "Invalid line wasn't reported")
+	assert.Equal(t, 2, calls)
+
+	err = ParseDataFileFunc(strings.NewReader(input), func(line DataLine, err error) error {
+		return stop
+	})
+	assert.Equal(t, stop, err)
+}
+
+/*
+TestDvLIRClient_GetDataFileStream covers:
+	- GetDataFileStream compared to GetDataFile
+	- GetDataFileStream with the login page instead of data
+	- GetDataFileStream with a non-200 status
+*/
+func TestDvLIRClient_GetDataFileStream(t *testing.T) {
+	dvlirClient, server := newSimulatedClient(t)
+	defer server.Close()
+
+	file, err := dvlirClient.GetDataFile(50)
+	if !assert.NoError(t, err, "Error during GetDataFile request") {
+		return
+	}
+	var streamed DataLines
+	err = dvlirClient.GetDataFileStream(context.Background(), 50, func(line DataLine, err error) error {
+		streamed = append(streamed, line)
+		return err
+	})
+	if assert.NoError(t, err, "Error during GetDataFileStream request") {
+		assert.Equal(t, file, streamed)
+	}
+
+	server.Inject(dvlirtest.Rule{Endpoint: "/daten.csv", Times: 1, Fault: dvlirtest.ServeLoginPage()})
+	err = dvlirClient.GetDataFileStream(context.Background(), 50, func(line DataLine, err error) error {
+		return err
+	})
+	assert.True(t, errors.Is(err, ErrSessionExpired), "ErrSessionExpired wasn't returned: %v", err)
+
+	server.Inject(dvlirtest.Rule{Endpoint: "/daten.csv", Times: 1, Fault: dvlirtest.Status(500, "flash error")})
+	err = dvlirClient.GetDataFileStream(context.Background(), 50, func(line DataLine, err error) error {
+		return err
+	})
+	var httpError HTTPError
+	if assert.True(t, errors.As(err, &httpError), "HTTPError wasn't returned: %v", err) && assert.NotNil(t, httpError.Body) {
+		assert.Equal(t, "flash error", httpError.Body.Message)
+	}
+}
+
+/*
+benchmarkDataFile returns a daten.csv file with the maximum number of lines
+*/
+func benchmarkDataFile() string {
+	var b strings.Builder
+	for i := 1; i <= 14400; i++ {
+		b.WriteString(strconv.Itoa(i))
+		b.WriteString(";01.01.2020;00:15:00;20190001;01234567;15873.4521;12100.2874;3773.1647;421.3370;421.3370;0.0000;150;0000\r\n")
+	}
+	return b.String()
+}
+
+func BenchmarkParseDataFile(b *testing.B) {
+	input := benchmarkDataFile()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := ParseDataFile(input); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseDataFileFunc(b *testing.B) {
+	input := benchmarkDataFile()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err := ParseDataFileFunc(strings.NewReader(input), func(line DataLine, err error) error {
+			return err
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDvLIRClient_GetDataFile(b *testing.B) {
+	server := dvlirtest.NewServer(dvlirtest.WithHistory(14400))
+	defer server.Close()
+	dvlirClient, err := NewDvLIRClient(server.Address(), server.Password())
+	if err == nil {
+		err = dvlirClient.Login()
+	}
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("GetDataFile", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := dvlirClient.GetDataFile(14400); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("GetDataFileStream", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			err := dvlirClient.GetDataFileStream(context.Background(), 14400, func(line DataLine, err error) error {
+				return err
+			})
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
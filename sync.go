@@ -0,0 +1,202 @@
+package dvlirclient
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"strconv"
+	"time"
+)
+
+const (
+	//maxDataLines is the number of lines the ring buffer behind daten.csv holds
+	maxDataLines = 14400
+	//syncMargin is the number of additional lines a Syncer requests to cover clock drift
+	syncMargin = 2
+)
+
+/*
+Syncer incrementally synchronizes daten.csv from an adapter. It remembers the last synchronized line per device in a
+CheckpointStore and only returns lines that were saved since then.
+*/
+type Syncer struct {
+	//Adapter is the adapter the lines are read from, it has to be logged in
+	Adapter Adapter
+	//Device identifies the adapter in the checkpoint store, e.g. its serial number or address
+	Device string
+	//Store persists the checkpoints
+	Store CheckpointStore
+	//Location is the time zone the adapter's clock is set to, the local time zone is used if it is nil
+	Location *time.Location
+	//InitialLines is the number of lines requested if no checkpoint exists yet, the whole ring buffer if it is 0
+	InitialLines int
+}
+
+/*
+NewSyncer returns a Syncer for the device that stores its checkpoints in store
+*/
+func NewSyncer(adapter Adapter, device string, store CheckpointStore) *Syncer {
+	return &Syncer{Adapter: adapter, Device: device, Store: store}
+}
+
+/*
+SyncResult contains the outcome of a synchronization
+*/
+type SyncResult struct {
+	//Lines are the lines saved since the last synchronization, the oldest line first
+	Lines DataLines
+	//Requested is the number of lines that was requested from the adapter
+	Requested int
+	//Gap is set if lines were lost, e.g. because they fell off the ring buffer before they could be synchronized
+	Gap *Gap
+	//Reset is true if the adapter restarted its line index, e.g. after its data was deleted
+	Reset bool
+	//Checkpoint is the checkpoint after the synchronization
+	Checkpoint Checkpoint
+}
+
+/*
+Gap describes lines of daten.csv that were lost between two synchronizations
+*/
+type Gap struct {
+	//First is the index of the first lost line
+	First int
+	//Last is the index of the last lost line
+	Last int
+	//Since is the timestamp of the last synchronized line before the gap
+	Since time.Time
+	//Until is the timestamp of the first line after the gap
+	Until time.Time
+}
+
+/*
+Missing returns the number of lost lines
+*/
+func (g Gap) Missing() int {
+	return g.Last - g.First + 1
+}
+
+/*
+Sync reads the lines saved since the last checkpoint and stores the new checkpoint.
+
+The number of requested lines is derived from the time that passed on the adapter's clock since the checkpoint and
+the current saving interval. If the checkpoint already fell off the ring buffer, the whole ring buffer is requested
+and the lost lines are reported as Gap.
+*/
+func (s *Syncer) Sync(ctx context.Context) (*SyncResult, error) {
+	checkpoint, ok, err := s.Store.LoadCheckpoint(s.Device)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error while loading checkpoint of "+s.Device)
+	}
+
+	info, err := s.Adapter.GetGeneralInformationContext(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error during Sync request")
+	}
+	interval := info.SavingInterval.Duration()
+	if interval == 0 {
+		return nil, errors.New("Unknown saving interval: " + info.SavingInterval.String())
+	}
+	now, err := time.ParseInLocation(dateLayout+" "+timeLayout, info.Date+" "+info.Time, s.location())
+	if err != nil {
+		return nil, errors.Wrap(err, "Error while parsing the clock of the adapter")
+	}
+
+	lines := s.InitialLines
+	if lines < 1 || lines > maxDataLines {
+		lines = maxDataLines
+	}
+	if ok {
+		lines = linesSince(checkpoint.Timestamp, now, interval)
+	}
+
+	result, err := s.fetch(ctx, lines, checkpoint, ok)
+	if err != nil {
+		return nil, err
+	}
+	if result.Gap != nil && lines < maxDataLines {
+		//The estimate was too small, e.g. because the clock of the adapter was changed
+		result, err = s.fetch(ctx, maxDataLines, checkpoint, ok)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if result.Checkpoint != checkpoint {
+		if err = s.Store.SaveCheckpoint(s.Device, result.Checkpoint); err != nil {
+			return nil, errors.Wrap(err, "Error while saving checkpoint of "+s.Device)
+		}
+	}
+	return result, nil
+}
+
+/*
+fetch requests the given number of lines and returns the ones after the checkpoint
+*/
+func (s *Syncer) fetch(ctx context.Context, lines int, checkpoint Checkpoint, ok bool) (*SyncResult, error) {
+	data, err := s.Adapter.GetDataFileContext(ctx, lines)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error during Sync request")
+	}
+
+	result := &SyncResult{Requested: lines, Checkpoint: checkpoint}
+	indexes := make([]int, len(data))
+	timestamps := make([]time.Time, len(data))
+	for i, line := range data {
+		indexes[i], err = strconv.Atoi(line.Index)
+		if err != nil {
+			return nil, errors.Wrap(err, "Error while parsing index of line "+strconv.Itoa(i+1))
+		}
+		timestamps[i], err = time.ParseInLocation(dateLayout+" "+timeLayout, line.Date+" "+line.Time, s.location())
+		if err != nil {
+			return nil, errors.Wrap(err, "Error while parsing timestamp of line "+strconv.Itoa(i+1))
+		}
+	}
+	if len(data) == 0 {
+		return result, nil
+	}
+
+	result.Reset = ok && indexes[len(data)-1] < checkpoint.Index
+	for i, line := range data {
+		isNew := !ok || indexes[i] > checkpoint.Index
+		if result.Reset {
+			//The index restarted, so only the timestamp tells which lines are new
+			isNew = timestamps[i].After(checkpoint.Timestamp)
+		}
+		if !isNew {
+			continue
+		}
+		if len(result.Lines) == 0 && ok && !result.Reset && indexes[i] > checkpoint.Index+1 {
+			result.Gap = &Gap{
+				First: checkpoint.Index + 1,
+				Last:  indexes[i] - 1,
+				Since: checkpoint.Timestamp,
+				Until: timestamps[i],
+			}
+		}
+		result.Lines = append(result.Lines, line)
+		result.Checkpoint = Checkpoint{Index: indexes[i], Timestamp: timestamps[i]}
+	}
+	return result, nil
+}
+
+func (s *Syncer) location() *time.Location {
+	if s.Location == nil {
+		return time.Local
+	}
+	return s.Location
+}
+
+/*
+linesSince returns the number of lines saved between the two points in time
+*/
+func linesSince(since, now time.Time, interval time.Duration) int {
+	elapsed := now.Sub(since)
+	if elapsed < 0 || elapsed/interval >= maxDataLines {
+		return maxDataLines
+	}
+	lines := int(elapsed/interval) + syncMargin
+	if lines > maxDataLines {
+		return maxDataLines
+	}
+	return lines
+}
@@ -0,0 +1,146 @@
+package dvlirclient
+
+import (
+	"context"
+	"github.com/inexio/dvlir-restapi-go-client/dvlirtest"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+/*
+TestSyncer_Sync covers:
+	- Sync without a checkpoint
+	- Sync with new lines
+	- Sync without new lines
+	- Sync after the checkpoint fell off the ring buffer
+	- Sync after the data of the adapter was deleted
+	- FileCheckpointStore
+*/
+func TestSyncer_Sync(t *testing.T) {
+	dvlirClient, server := newSimulatedClient(t, dvlirtest.WithHistory(200))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "dvlir")
+	if !assert.NoError(t, err, "Error while creating temp dir") {
+		return
+	}
+	defer os.RemoveAll(dir)
+	store := NewFileCheckpointStore(filepath.Join(dir, "checkpoints.json"))
+
+	syncer := NewSyncer(dvlirClient, "adapter", store)
+	syncer.Location = time.UTC
+	ctx := context.Background()
+
+	result, err := syncer.Sync(ctx)
+	if !assert.NoError(t, err, "Error during Sync") {
+		return
+	}
+	assert.Len(t, result.Lines, 200)
+	assert.Equal(t, 14400, result.Requested)
+	assert.Nil(t, result.Gap)
+	assert.Equal(t, 200, result.Checkpoint.Index)
+	assert.Equal(t, server.Now(), result.Checkpoint.Timestamp)
+
+	server.Advance(time.Hour)
+	result, err = syncer.Sync(ctx)
+	if !assert.NoError(t, err, "Error during Sync") {
+		return
+	}
+	if assert.Len(t, result.Lines, 4) {
+		assert.Equal(t, "201", result.Lines[0].Index)
+		assert.Equal(t, "204", result.Lines[3].Index)
+	}
+	assert.Equal(t, 6, result.Requested)
+	assert.Nil(t, result.Gap)
+
+	result, err = syncer.Sync(ctx)
+	if !assert.NoError(t, err, "Error during Sync") {
+		return
+	}
+	assert.Len(t, result.Lines, 0)
+	assert.Equal(t, 204, result.Checkpoint.Index)
+
+	checkpoint, ok, err := NewFileCheckpointStore(filepath.Join(dir, "checkpoints.json")).LoadCheckpoint("adapter")
+	if assert.NoError(t, err, "Error while loading checkpoint") && assert.True(t, ok, "Checkpoint wasn't saved") {
+		assert.Equal(t, 204, checkpoint.Index)
+		assert.True(t, server.Now().Equal(checkpoint.Timestamp))
+	}
+
+	server.Update(func(state *dvlirtest.State) {
+		state.SavingInterval = "sec"
+	})
+	server.Advance(5 * time.Hour)
+	result, err = syncer.Sync(ctx)
+	if !assert.NoError(t, err, "Error during Sync") {
+		return
+	}
+	assert.Len(t, result.Lines, 14400)
+	if assert.NotNil(t, result.Gap, "Gap wasn't reported") {
+		assert.Equal(t, 205, result.Gap.First)
+		assert.Equal(t, 5*3600-14400, result.Gap.Missing())
+	}
+
+	_, err = dvlirClient.DeleteData(server.State().DeleteCode)
+	if !assert.NoError(t, err, "Error during DeleteData request") {
+		return
+	}
+	result, err = syncer.Sync(ctx)
+	if assert.NoError(t, err, "Error during Sync") {
+		assert.Len(t, result.Lines, 0)
+	}
+}
+
+/*
+dataFileAdapter is a mock of the Adapter interface that serves a fixed daten.csv
+*/
+type dataFileAdapter struct {
+	Adapter
+	info  GeneralInfo
+	lines DataLines
+}
+
+func (d *dataFileAdapter) GetGeneralInformationContext(ctx context.Context) (GeneralInfo, error) {
+	return d.info, nil
+}
+
+func (d *dataFileAdapter) GetDataFileContext(ctx context.Context, lines int) (DataLines, error) {
+	if lines > len(d.lines) {
+		lines = len(d.lines)
+	}
+	return d.lines[len(d.lines)-lines:], nil
+}
+
+/*
+TestSyncer_Reset covers:
+	- Sync after the adapter restarted its line index
+*/
+func TestSyncer_Reset(t *testing.T) {
+	adapter := &dataFileAdapter{
+		info: GeneralInfo{SavingInterval: "15min", Date: "01.01.2020", Time: "12:00:00"},
+		lines: DataLines{
+			{Index: "1", Date: "01.01.2020", Time: "11:30:00"},
+			{Index: "2", Date: "01.01.2020", Time: "11:45:00"},
+			{Index: "3", Date: "01.01.2020", Time: "12:00:00"},
+		},
+	}
+	store := &MemoryCheckpointStore{}
+	checkpoint := Checkpoint{Index: 500, Timestamp: time.Date(2020, time.January, 1, 11, 40, 0, 0, time.UTC)}
+	_ = store.SaveCheckpoint("adapter", checkpoint)
+
+	syncer := NewSyncer(adapter, "adapter", store)
+	syncer.Location = time.UTC
+	result, err := syncer.Sync(context.Background())
+	if !assert.NoError(t, err, "Error during Sync") {
+		return
+	}
+	assert.True(t, result.Reset, "Reset wasn't reported")
+	assert.Nil(t, result.Gap)
+	if assert.Len(t, result.Lines, 2) {
+		assert.Equal(t, "2", result.Lines[0].Index)
+	}
+	assert.Equal(t, 3, result.Checkpoint.Index)
+}